@@ -9,7 +9,7 @@ var cpCmd = &cobra.Command{
 	Short: "Copy files into a destination directory",
 	Args:  cobra.ExactArgs(2),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return moveOrCopy(args[0], args[1], client.Copy)
+		return moveOrCopy(cmd.Context(), args[0], args[1], client.CopyCtx)
 	},
 }
 
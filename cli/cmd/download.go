@@ -1,9 +1,8 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
-	"io"
-	"net/http"
 	"os"
 	"path/filepath"
 
@@ -13,6 +12,11 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var (
+	downloadConnections int
+	downloadResume      bool
+)
+
 var downloadCmd = &cobra.Command{
 	Use:   "download <remote_path> <local_path>",
 	Short: "Download a file from remote to a local directory or file path",
@@ -26,7 +30,7 @@ var downloadCmd = &cobra.Command{
 			return &exitError{code: output.ExitNotFound, msg: err.Error()}
 		}
 
-		fileInfo, err := client.GetFile(fileID)
+		fileInfo, err := client.GetFileCtx(cmd.Context(), fileID)
 		if err != nil {
 			return &exitError{code: output.ExitError, msg: err.Error()}
 		}
@@ -34,25 +38,20 @@ var downloadCmd = &cobra.Command{
 			return &exitError{code: output.ExitArgs, msg: "Cannot download a directory."}
 		}
 
-		dlInfo, err := client.Download(fileInfo.PickCode)
-		if err != nil {
-			return &exitError{code: output.ExitError, msg: fmt.Sprintf("Failed to get download URL: %v", err)}
-		}
-
-		localPath := resolver.ResolveLocalDownloadPath(localTarget, dlInfo.FileName)
+		localPath := resolver.ResolveLocalDownloadPath(localTarget, fileInfo.Name)
 
 		if !jsonOutput {
-			fmt.Printf("Downloading %s (%s)...\n", dlInfo.FileName, output.FormatFileSize(int64(dlInfo.FileSize)))
+			fmt.Printf("Downloading %s (%s)...\n", fileInfo.Name, output.FormatFileSize(fileInfo.Size))
 		}
 
-		if err := downloadFile(dlInfo, localPath); err != nil {
+		if err := downloadFile(cmd.Context(), fileInfo, localPath); err != nil {
 			return &exitError{code: output.ExitError, msg: fmt.Sprintf("Download failed: %v", err)}
 		}
 
 		printer.PrintSuccess(map[string]interface{}{
 			"remote_path": remotePath,
 			"local_path":  localPath,
-			"size":        int64(dlInfo.FileSize),
+			"size":        fileInfo.Size,
 		})
 		if !jsonOutput {
 			fmt.Printf("Download complete: %s\n", localPath)
@@ -61,39 +60,25 @@ var downloadCmd = &cobra.Command{
 	},
 }
 
-func downloadFile(dlInfo *driver.DownloadInfo, localPath string) error {
+func downloadFile(ctx context.Context, file *driver.File, localPath string) error {
 	if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
 		return err
 	}
 
-	req, err := http.NewRequest("GET", dlInfo.Url.Url, nil)
-	if err != nil {
-		return fmt.Errorf("create request: %w", err)
+	opts := []driver.DownloaderOption{driver.WithDownloaderConnections(downloadConnections)}
+	if downloadResume {
+		opts = append(opts, driver.WithDownloaderResume())
 	}
-	for k, vals := range dlInfo.Header {
-		for _, v := range vals {
-			req.Header.Add(k, v)
+	if !jsonOutput {
+		bar := output.CreateProgressBar(file.Size)
+		defer output.FinishProgress(bar)
+		if bar != nil {
+			opts = append(opts, driver.WithDownloaderProgress(func(p driver.DownloadProgress) {
+				bar.SetCurrent(p.Done)
+			}))
 		}
 	}
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("download request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("download failed: HTTP %d", resp.StatusCode)
-	}
-
-	out, err := os.Create(localPath)
-	if err != nil {
-		return err
-	}
-	defer out.Close()
-
-	_, err = io.Copy(out, resp.Body)
-	return err
+	return driver.NewDownloader(client, opts...).DownloadCtx(ctx, file, localPath)
 }
 
 func resolveDownloadTargetPath(localTarget, fileName string) string {
@@ -101,5 +86,7 @@ func resolveDownloadTargetPath(localTarget, fileName string) string {
 }
 
 func init() {
+	downloadCmd.Flags().IntVarP(&downloadConnections, "connections", "c", driver.DefaultDownloadConnections, "Number of parallel connections")
+	downloadCmd.Flags().BoolVar(&downloadResume, "resume", false, "Resume an interrupted download from its .part file")
 	rootCmd.AddCommand(downloadCmd)
 }
@@ -14,11 +14,11 @@ var infoCmd = &cobra.Command{
 	Short: "Show current account and storage info",
 	Args:  cobra.NoArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		userInfo, err := client.GetUser()
+		userInfo, err := client.GetUserCtx(cmd.Context())
 		if err != nil {
 			return &exitError{code: output.ExitAuth, msg: fmt.Sprintf("Failed to get user info: %v", err)}
 		}
-		info, err := client.GetInfo()
+		info, err := client.GetInfoCtx(cmd.Context())
 		if err != nil {
 			return &exitError{code: output.ExitError, msg: fmt.Sprintf("Failed to get account info: %v", err)}
 		}
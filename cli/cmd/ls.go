@@ -23,7 +23,7 @@ var lsCmd = &cobra.Command{
 			return &exitError{code: output.ExitNotFound, msg: err.Error()}
 		}
 
-		files, err := client.List(dirID)
+		files, err := client.ListCtx(cmd.Context(), dirID)
 		if err != nil {
 			return &exitError{code: output.ExitError, msg: err.Error()}
 		}
@@ -1,6 +1,8 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"path"
 	"strings"
@@ -29,7 +31,7 @@ var mkdirCmd = &cobra.Command{
 		}
 
 		if mkdirParents {
-			return mkdirP(parentPath, dirName, remotePath)
+			return mkdirP(cmd.Context(), parentPath, dirName, remotePath)
 		}
 
 		parentID, err := resolver.ResolveDir(client, parentPath)
@@ -37,7 +39,7 @@ var mkdirCmd = &cobra.Command{
 			return &exitError{code: output.ExitNotFound, msg: fmt.Sprintf("Parent directory not found: %s", parentPath)}
 		}
 
-		dirID, err := client.Mkdir(parentID, dirName)
+		dirID, err := client.MkdirCtx(cmd.Context(), parentID, dirName)
 		if err != nil {
 			return &exitError{code: output.ExitError, msg: err.Error()}
 		}
@@ -54,7 +56,7 @@ var mkdirCmd = &cobra.Command{
 	},
 }
 
-func mkdirP(parentPath, dirName, fullPath string) error {
+func mkdirP(ctx context.Context, parentPath, dirName, fullPath string) error {
 	parts := strings.Split(strings.Trim(parentPath+"/"+dirName, "/"), "/")
 	currentID := resolver.RootID
 	createdPath := ""
@@ -71,9 +73,9 @@ func mkdirP(parentPath, dirName, fullPath string) error {
 			continue
 		}
 
-		newID, err := client.Mkdir(currentID, part)
+		newID, err := client.MkdirCtx(ctx, currentID, part)
 		if err != nil {
-			if err == driver.ErrExist {
+			if errors.Is(err, driver.ErrExist) {
 				existingID, _ := resolver.ResolveDir(client, createdPath)
 				if existingID != "" {
 					currentID = existingID
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/SheltonZhu/115driver/cli/internal/output"
@@ -13,7 +14,7 @@ var mvCmd = &cobra.Command{
 	Short: "Move files into a destination directory",
 	Args:  cobra.ExactArgs(2),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return moveOrCopy(args[0], args[1], client.Move)
+		return moveOrCopy(cmd.Context(), args[0], args[1], client.MoveCtx)
 	},
 }
 
@@ -21,9 +22,9 @@ func init() {
 	rootCmd.AddCommand(mvCmd)
 }
 
-type transferFunc func(dirID string, fileIDs ...string) error
+type transferFunc func(ctx context.Context, dirID string, fileIDs ...string) error
 
-func moveOrCopy(srcPath, dstDir string, fn transferFunc) error {
+func moveOrCopy(ctx context.Context, srcPath, dstDir string, fn transferFunc) error {
 	fileID, _, err := resolver.ResolvePath(client, srcPath)
 	if err != nil {
 		return &exitError{code: output.ExitNotFound, msg: err.Error()}
@@ -34,7 +35,7 @@ func moveOrCopy(srcPath, dstDir string, fn transferFunc) error {
 		return &exitError{code: output.ExitNotFound, msg: fmt.Sprintf("Destination directory not found: %s", dstDir)}
 	}
 
-	if err := fn(dirID, fileID); err != nil {
+	if err := fn(ctx, dirID, fileID); err != nil {
 		return &exitError{code: output.ExitError, msg: err.Error()}
 	}
 
@@ -32,7 +32,7 @@ var offlineAddCmd = &cobra.Command{
 			saveDirID = id
 		}
 
-		hashes, err := client.AddOfflineTaskURIs([]string{url}, saveDirID)
+		hashes, err := client.AddOfflineTaskURIsCtx(cmd.Context(), []string{url}, saveDirID)
 		if err != nil {
 			return &exitError{code: output.ExitError, msg: err.Error()}
 		}
@@ -57,7 +57,7 @@ var offlineListCmd = &cobra.Command{
 		var total int64
 
 		for page := int64(1); ; page++ {
-			result, err := client.ListOfflineTask(page)
+			result, err := client.ListOfflineTaskCtx(cmd.Context(), page)
 			if err != nil {
 				return &exitError{code: output.ExitError, msg: err.Error()}
 			}
@@ -99,7 +99,7 @@ var offlineRmCmd = &cobra.Command{
 	RunE: func(cmd *cobra.Command, args []string) error {
 		hash := args[0]
 
-		if err := client.DeleteOfflineTasks([]string{hash}, false); err != nil {
+		if err := client.DeleteOfflineTasksCtx(cmd.Context(), []string{hash}, false); err != nil {
 			return &exitError{code: output.ExitError, msg: err.Error()}
 		}
 
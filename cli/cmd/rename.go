@@ -22,7 +22,7 @@ var renameCmd = &cobra.Command{
 			return &exitError{code: output.ExitNotFound, msg: err.Error()}
 		}
 
-		if err := client.Rename(fileID, newName); err != nil {
+		if err := client.RenameCtx(cmd.Context(), fileID, newName); err != nil {
 			return &exitError{code: output.ExitError, msg: err.Error()}
 		}
 
@@ -36,7 +36,7 @@ var rmCmd = &cobra.Command{
 			}
 		}
 
-		if err := client.Delete(fileID); err != nil {
+		if err := client.DeleteCtx(cmd.Context(), fileID); err != nil {
 			return &exitError{code: output.ExitError, msg: err.Error()}
 		}
 
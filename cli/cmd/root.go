@@ -1,8 +1,11 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"os"
+	"os/signal"
 	"strings"
 
 	"github.com/SheltonZhu/115driver/cli/internal/auth"
@@ -54,11 +57,12 @@ var rootCmd = &cobra.Command{
 
 		opts := []driver.Option{driver.UA(driver.UA115Browser)}
 		if debugMode {
-			opts = append(opts, driver.WithDebug())
+			logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))
+			opts = append(opts, driver.WithLogger(logger))
 		}
 		client = driver.New(opts...).ImportCredential(cr)
 
-		if _, err := client.GetUser(); err != nil {
+		if _, err := client.GetUserCtx(cmd.Context()); err != nil {
 			return &exitError{code: output.ExitAuth, msg: fmt.Sprintf("Authentication failed: %v\nRun '115driver login' to re-authenticate.", err)}
 		}
 		return nil
@@ -79,7 +83,7 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&configPath, "config", "", "Config file path (default ~/.115driver/config.toml)")
 	rootCmd.PersistentFlags().StringVar(&profile, "profile", "", "Config profile name (default 'main')")
 	rootCmd.PersistentFlags().BoolVar(&jsonOutput, "json", false, "Output in JSON format (for AI agents)")
-	rootCmd.PersistentFlags().BoolVar(&debugMode, "debug", false, "Enable debug mode")
+	rootCmd.PersistentFlags().BoolVar(&debugMode, "debug", false, "Log every API call to stderr")
 	rootCmd.CompletionOptions.HiddenDefaultCmd = true
 }
 
@@ -96,7 +100,11 @@ func Execute() int {
 		printer = output.NewPrinter(jsonOutput)
 	}
 
-	if err := rootCmd.Execute(); err != nil {
+	// Cancel in-flight requests on Ctrl-C
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
 		if ee, ok := err.(*exitError); ok {
 			return printer.PrintError(ee.msg, ee.code)
 		}
@@ -42,7 +42,7 @@ var searchCmd = &cobra.Command{
 			opts.Order = searchSort
 		}
 
-		result, err := client.Search(opts)
+		result, err := client.SearchCtx(cmd.Context(), opts)
 		if err != nil {
 			return &exitError{code: output.ExitError, msg: err.Error()}
 		}
@@ -17,7 +17,7 @@ var statCmd = &cobra.Command{
 			return &exitError{code: output.ExitNotFound, msg: err.Error()}
 		}
 
-		statInfo, err := client.Stat(fileID)
+		statInfo, err := client.StatCtx(cmd.Context(), fileID)
 		if err != nil {
 			return &exitError{code: output.ExitError, msg: err.Error()}
 		}
@@ -36,7 +36,7 @@ var statCmd = &cobra.Command{
 		}
 
 		if !statInfo.IsDirectory {
-			f, err := client.GetFile(fileID)
+			f, err := client.GetFileCtx(cmd.Context(), fileID)
 			if err != nil {
 				return &exitError{code: output.ExitError, msg: "Failed to get file details: " + err.Error()}
 			}
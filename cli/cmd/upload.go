@@ -2,27 +2,40 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 
 	"github.com/SheltonZhu/115driver/cli/internal/output"
 	"github.com/SheltonZhu/115driver/cli/internal/resolver"
+	"github.com/SheltonZhu/115driver/pkg/driver"
 	"github.com/spf13/cobra"
 )
 
+var uploadName string
+
 var uploadCmd = &cobra.Command{
 	Use:   "upload <local_path> <remote_dir>",
 	Short: "Upload a file to remote directory",
+	Long:  "Upload a file to remote directory. A local_path of - uploads the standard input, named by --name.",
 	Args:  cobra.ExactArgs(2),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		localPath := args[0]
 		remoteDir := args[1]
 
+		if localPath == "-" && uploadName == "" {
+			return &exitError{code: output.ExitArgs, msg: "--name is required to upload the standard input"}
+		}
+
 		dirID, err := resolver.ResolveDir(client, remoteDir)
 		if err != nil {
 			return &exitError{code: output.ExitNotFound, msg: fmt.Sprintf("Remote directory not found: %s", remoteDir)}
 		}
 
+		if localPath == "-" {
+			return uploadStdin(cmd, dirID, remoteDir)
+		}
+
 		f, err := os.Open(localPath)
 		if err != nil {
 			return &exitError{code: output.ExitArgs, msg: fmt.Sprintf("Cannot open local file: %v", err)}
@@ -39,12 +52,17 @@ var uploadCmd = &cobra.Command{
 		}
 
 		fileName := filepath.Base(localPath)
+		if uploadName != "" {
+			fileName = uploadName
+		}
 
 		if !jsonOutput {
 			fmt.Printf("Uploading %s (%s)...\n", fileName, output.FormatFileSize(stat.Size()))
 		}
 
-		err = client.RapidUploadOrByOSS(dirID, fileName, stat.Size(), f)
+		progress, finish := uploadProgressBar(stat.Size())
+		err = client.RapidUploadOrByOSSCtx(cmd.Context(), dirID, fileName, stat.Size(), f, driver.UploadWithProgress(progress))
+		finish(err)
 		if err != nil {
 			return &exitError{code: output.ExitError, msg: fmt.Sprintf("Upload failed: %v", err)}
 		}
@@ -61,6 +79,76 @@ var uploadCmd = &cobra.Command{
 	},
 }
 
+// uploadStdin uploads the standard input as --name.
+func uploadStdin(cmd *cobra.Command, dirID, remoteDir string) error {
+	if !jsonOutput {
+		fmt.Printf("Uploading %s from standard input...\n", uploadName)
+	}
+
+	r := &countingReader{r: os.Stdin}
+	progress, finish := uploadProgressBar(0)
+	err := client.UploadStreamCtx(cmd.Context(), dirID, uploadName, r, driver.UploadStreamWithProgress(progress))
+	finish(err)
+	if err != nil {
+		return &exitError{code: output.ExitError, msg: fmt.Sprintf("Upload failed: %v", err)}
+	}
+
+	printer.PrintSuccess(map[string]interface{}{
+		"local_path": "-",
+		"remote_dir": remoteDir,
+		"name":       uploadName,
+		"size":       r.n,
+	})
+	if !jsonOutput {
+		fmt.Printf("Upload complete: %s (%s) -> %s\n", uploadName, output.FormatFileSize(r.n), remoteDir)
+	}
+	return nil
+}
+
+// uploadProgressBar returns a progress func drawing the hashing, then the
+// upload of a file of total bytes, 0 when unknown, and the func to call once
+// the upload ends. The func is nil when no bar is drawn.
+func uploadProgressBar(total int64) (func(p driver.UploadProgress), func(err error)) {
+	if jsonOutput {
+		return nil, func(error) {}
+	}
+	bar := output.CreateProgressBar(total)
+	if bar == nil {
+		return nil, func(error) {}
+	}
+	progress := func(p driver.UploadProgress) {
+		if p.Total > 0 {
+			bar.SetTotal(p.Total)
+		}
+		if p.Phase == driver.UploadPhaseHashing {
+			bar.SetCurrent(p.Hashed)
+		} else {
+			bar.SetCurrent(p.Sent)
+		}
+	}
+	finish := func(err error) {
+		if err == nil {
+			// rapid uploads send nothing
+			bar.SetCurrent(bar.Total())
+		}
+		output.FinishProgress(bar)
+	}
+	return progress, finish
+}
+
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
 func init() {
+	uploadCmd.Flags().StringVar(&uploadName, "name", "", "Remote file name, required when uploading the standard input")
 	rootCmd.AddCommand(uploadCmd)
 }
@@ -11,7 +11,7 @@ var whoamiCmd = &cobra.Command{
 	Use:   "whoami",
 	Short: "Show current authenticated user info",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		userInfo, err := client.GetUser()
+		userInfo, err := client.GetUserCtx(cmd.Context())
 		if err != nil {
 			return &exitError{code: output.ExitAuth, msg: fmt.Sprintf("Failed to get user info: %v", err)}
 		}
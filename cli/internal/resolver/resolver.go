@@ -12,13 +12,17 @@ import (
 
 const RootID = "0"
 
-// TODO: add LRU cache for path resolution
-
 type pathResolverClient interface {
 	DirName2CID(dir string) (*driver.APIGetDirIDResp, error)
 	List(dirID string, opts ...driver.ListOption) (*[]driver.File, error)
 }
 
+// cachingResolverClient is implemented by *driver.Pan115Client, which caches
+// the resolved paths.
+type cachingResolverClient interface {
+	ResolvePath(p string) (string, bool, error)
+}
+
 func ResolveDir(client pathResolverClient, remotePath string) (string, error) {
 	if remotePath == "" || remotePath == "/" {
 		return RootID, nil
@@ -31,6 +35,17 @@ func ResolveDir(client pathResolverClient, remotePath string) (string, error) {
 		return RootID, nil
 	}
 
+	if c, ok := client.(cachingResolverClient); ok {
+		id, isDir, err := c.ResolvePath(cleaned)
+		if err != nil {
+			return "", fmt.Errorf("directory not found: %s (%w)", remotePath, err)
+		}
+		if !isDir {
+			return "", fmt.Errorf("directory not found: %s", remotePath)
+		}
+		return id, nil
+	}
+
 	resp, err := client.DirName2CID(cleaned)
 	if err != nil {
 		return "", fmt.Errorf("directory not found: %s (%w)", remotePath, err)
@@ -45,6 +60,14 @@ func ResolveFile(client pathResolverClient, remotePath string) (string, error) {
 	cleaned := strings.TrimPrefix(remotePath, "/")
 	cleaned = strings.TrimSuffix(cleaned, "/")
 
+	if c, ok := client.(cachingResolverClient); ok {
+		id, isDir, err := c.ResolvePath(cleaned)
+		if err != nil || isDir {
+			return "", fmt.Errorf("file not found: %s", remotePath)
+		}
+		return id, nil
+	}
+
 	dir := path.Dir(cleaned)
 	fileName := path.Base(cleaned)
 
@@ -77,6 +100,14 @@ func ResolvePath(client pathResolverClient, remotePath string) (string, bool, er
 		return RootID, true, nil
 	}
 
+	if c, ok := client.(cachingResolverClient); ok {
+		id, isDir, err := c.ResolvePath(remotePath)
+		if err != nil {
+			return "", false, fmt.Errorf("path not found: %s (%w)", remotePath, err)
+		}
+		return id, isDir, nil
+	}
+
 	// Try as directory first
 	dirID, err := ResolveDir(client, remotePath)
 	if err == nil && dirID != "" {
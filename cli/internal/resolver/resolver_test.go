@@ -1,6 +1,7 @@
 package resolver
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/SheltonZhu/115driver/pkg/driver"
@@ -47,6 +48,28 @@ func TestResolvePath_RootStillResolvesToDirectory(t *testing.T) {
 	}
 }
 
+func TestResolvePath_UsesCachingClient(t *testing.T) {
+	client := fakeCachingClient{"a/b": {id: "42", isDir: true}, "a/b/c.txt": {id: "43"}}
+
+	dirID, err := ResolveDir(client, "/a/b/")
+	if err != nil || dirID != "42" {
+		t.Fatalf("ResolveDir = %q, %v", dirID, err)
+	}
+	if _, err := ResolveDir(client, "/a/b/c.txt"); err == nil {
+		t.Fatal("ResolveDir resolved a file")
+	}
+	fileID, err := ResolveFile(client, "/a/b/c.txt")
+	if err != nil || fileID != "43" {
+		t.Fatalf("ResolveFile = %q, %v", fileID, err)
+	}
+	if _, err := ResolveFile(client, "/a/b"); err == nil {
+		t.Fatal("ResolveFile resolved a directory")
+	}
+	if _, _, err := ResolvePath(client, "/missing"); err == nil {
+		t.Fatal("ResolvePath resolved a missing path")
+	}
+}
+
 type fakeResolverClient struct {
 	dirIDs     map[string]string
 	filesByDir map[string][]driver.File
@@ -63,3 +86,27 @@ func (f fakeResolverClient) List(dirID string, _ ...driver.ListOption) (*[]drive
 	files := f.filesByDir[dirID]
 	return &files, nil
 }
+
+type fakeCachingEntry struct {
+	id    string
+	isDir bool
+}
+
+// fakeCachingClient resolves the paths it maps, without the leading slash.
+type fakeCachingClient map[string]fakeCachingEntry
+
+func (f fakeCachingClient) DirName2CID(string) (*driver.APIGetDirIDResp, error) {
+	panic("resolved without the cache")
+}
+
+func (f fakeCachingClient) List(string, ...driver.ListOption) (*[]driver.File, error) {
+	panic("resolved without the cache")
+}
+
+func (f fakeCachingClient) ResolvePath(p string) (string, bool, error) {
+	e, ok := f[strings.Trim(p, "/")]
+	if !ok {
+		return "", false, driver.ErrNotExist
+	}
+	return e.id, e.isDir, nil
+}
@@ -28,7 +28,7 @@ func (at *AccountTools) RegisterTools(server *mcp.Server) {
 }
 
 func (at *AccountTools) getAccountInfo(ctx context.Context, req *mcp.CallToolRequest, args struct{}) (*mcp.CallToolResult, any, error) {
-	userInfo, err := at.client.GetUser()
+	userInfo, err := at.client.GetUserCtx(ctx)
 	if err != nil {
 		return &mcp.CallToolResult{
 			Content: []mcp.Content{
@@ -39,7 +39,7 @@ func (at *AccountTools) getAccountInfo(ctx context.Context, req *mcp.CallToolReq
 			IsError: true,
 		}, nil, nil
 	}
-	info, err := at.client.GetInfo()
+	info, err := at.client.GetInfoCtx(ctx)
 	if err != nil {
 		return &mcp.CallToolResult{
 			Content: []mcp.Content{
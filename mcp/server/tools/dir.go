@@ -44,10 +44,10 @@ func (dt *DirTools) listDirectory(ctx context.Context, req *mcp.CallToolRequest,
 
 	// If offset and limit are specified, use pagination
 	if args.Limit > 0 {
-		files, err = dt.client.ListPage(args.DirID, args.Offset, args.Limit)
+		files, err = dt.client.ListPageCtx(ctx, args.DirID, args.Offset, args.Limit)
 	} else {
 		// Otherwise, list all files (existing behavior)
-		files, err = dt.client.List(args.DirID)
+		files, err = dt.client.ListCtx(ctx, args.DirID)
 	}
 
 	if err != nil {
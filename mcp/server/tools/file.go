@@ -74,9 +74,11 @@ type UploadFromLocalArgs struct {
 
 // DownloadFileArgs defines arguments for downloading a file
 type DownloadFileArgs struct {
-	PickCode  string `json:"pick_code" jsonschema:"pick code of the file to download"`
+	FileID    string `json:"file_id,omitempty" jsonschema:"ID of the file to download, its size and SHA1 are checked against the downloaded content"`
+	PickCode  string `json:"pick_code,omitempty" jsonschema:"pick code of the file to download, used when file_id is not specified"`
 	LocalPath string `json:"local_path" jsonschema:"local path where the downloaded file will be saved"`
 	UserAgent string `json:"user_agent,omitempty" jsonschema:"optional user agent for the download request, uses 115 browser UA if not specified"`
+	Resume    bool   `json:"resume,omitempty" jsonschema:"resume an interrupted download from the .part file next to local_path instead of starting over"`
 }
 
 // GetDownloadInfoArgs defines arguments for getting download information
@@ -141,7 +143,7 @@ func (ft *FileTools) RegisterTools(server *mcp.Server) {
 
 	mcp.AddTool(server, &mcp.Tool{
 		Name:        "download_file",
-		Description: "Download a file from 115 cloud storage to local path",
+		Description: "Download a file from 115 cloud storage to local path over several ranged connections. The download URL is resolved with the client's download strategies, the web API by default, like get_download_info",
 	}, ft.downloadFile)
 
 	mcp.AddTool(server, &mcp.Tool{
@@ -151,7 +153,7 @@ func (ft *FileTools) RegisterTools(server *mcp.Server) {
 }
 
 func (ft *FileTools) mkdir(ctx context.Context, req *mcp.CallToolRequest, args MkdirArgs) (*mcp.CallToolResult, any, error) {
-	dirID, err := ft.client.Mkdir(args.ParentID, args.Name)
+	dirID, err := ft.client.MkdirCtx(ctx, args.ParentID, args.Name)
 	if err != nil {
 		return &mcp.CallToolResult{
 			Content: []mcp.Content{
@@ -201,7 +203,7 @@ func (ft *FileTools) delete(ctx context.Context, req *mcp.CallToolRequest, args
 		}, nil, nil
 	}
 
-	err := ft.client.Delete(args.FileIDs...)
+	err := ft.client.DeleteCtx(ctx, args.FileIDs...)
 	if err != nil {
 		return &mcp.CallToolResult{
 			Content: []mcp.Content{
@@ -223,7 +225,7 @@ func (ft *FileTools) delete(ctx context.Context, req *mcp.CallToolRequest, args
 }
 
 func (ft *FileTools) rename(ctx context.Context, req *mcp.CallToolRequest, args RenameArgs) (*mcp.CallToolResult, any, error) {
-	err := ft.client.Rename(args.FileID, args.NewName)
+	err := ft.client.RenameCtx(ctx, args.FileID, args.NewName)
 	if err != nil {
 		return &mcp.CallToolResult{
 			Content: []mcp.Content{
@@ -256,7 +258,7 @@ func (ft *FileTools) move(ctx context.Context, req *mcp.CallToolRequest, args Mo
 		}, nil, nil
 	}
 
-	err := ft.client.Move(args.DirID, args.FileIDs...)
+	err := ft.client.MoveCtx(ctx, args.DirID, args.FileIDs...)
 	if err != nil {
 		return &mcp.CallToolResult{
 			Content: []mcp.Content{
@@ -289,7 +291,7 @@ func (ft *FileTools) copy(ctx context.Context, req *mcp.CallToolRequest, args Co
 		}, nil, nil
 	}
 
-	err := ft.client.Copy(args.DirID, args.FileIDs...)
+	err := ft.client.CopyCtx(ctx, args.DirID, args.FileIDs...)
 	if err != nil {
 		return &mcp.CallToolResult{
 			Content: []mcp.Content{
@@ -311,7 +313,7 @@ func (ft *FileTools) copy(ctx context.Context, req *mcp.CallToolRequest, args Co
 }
 
 func (ft *FileTools) stat(ctx context.Context, req *mcp.CallToolRequest, args StatArgs) (*mcp.CallToolResult, any, error) {
-	info, err := ft.client.Stat(args.FileID)
+	info, err := ft.client.StatCtx(ctx, args.FileID)
 	if err != nil {
 		return &mcp.CallToolResult{
 			Content: []mcp.Content{
@@ -358,7 +360,7 @@ func (ft *FileTools) stat(ctx context.Context, req *mcp.CallToolRequest, args St
 
 func (ft *FileTools) uploadFromURL(ctx context.Context, req *mcp.CallToolRequest, args UploadFromURLArgs) (*mcp.CallToolResult, any, error) {
 	// Download the file from the URL
-	resp, err := ft.client.Client.R().SetDoNotParseResponse(true).Get(args.URL)
+	resp, err := ft.client.Client.R().SetContext(ctx).SetDoNotParseResponse(true).Get(args.URL)
 	if err != nil {
 		return &mcp.CallToolResult{
 			Content: []mcp.Content{
@@ -392,63 +394,8 @@ func (ft *FileTools) uploadFromURL(ctx context.Context, req *mcp.CallToolRequest
 		}
 	}
 
-	// Create a temporary file to store the downloaded content
-	tempFile, err := os.CreateTemp("", "115_mcp_upload_*")
-	if err != nil {
-		return &mcp.CallToolResult{
-			Content: []mcp.Content{
-				&mcp.TextContent{
-					Text: fmt.Sprintf("Failed to create temporary file: %v", err),
-				},
-			},
-			IsError: true,
-		}, nil, nil
-	}
-	defer os.Remove(tempFile.Name()) // Clean up the temp file afterwards
-	defer tempFile.Close()
-
-	// Copy the response body to the temporary file
-	_, err = io.Copy(tempFile, resp.RawBody())
-	if err != nil {
-		return &mcp.CallToolResult{
-			Content: []mcp.Content{
-				&mcp.TextContent{
-					Text: fmt.Sprintf("Failed to save downloaded content to temporary file: %v", err),
-				},
-			},
-			IsError: true,
-		}, nil, nil
-	}
-
-	// Get the file size
-	fileInfo, err := tempFile.Stat()
-	if err != nil {
-		return &mcp.CallToolResult{
-			Content: []mcp.Content{
-				&mcp.TextContent{
-					Text: fmt.Sprintf("Failed to get file info: %v", err),
-				},
-			},
-			IsError: true,
-		}, nil, nil
-	}
-	fileSize := fileInfo.Size()
-
-	// Seek back to the beginning of the file
-	_, err = tempFile.Seek(0, io.SeekStart)
-	if err != nil {
-		return &mcp.CallToolResult{
-			Content: []mcp.Content{
-				&mcp.TextContent{
-					Text: fmt.Sprintf("Failed to seek to beginning of file: %v", err),
-				},
-			},
-			IsError: true,
-		}, nil, nil
-	}
-
-	// Upload the downloaded content to 115 using the existing method
-	err = ft.client.RapidUploadOrByOSS(args.DirID, fileName, fileSize, tempFile)
+	// Stream the downloaded content to 115, it is spooled and hashed on the way
+	err = ft.client.UploadStreamCtx(ctx, args.DirID, fileName, resp.RawBody())
 	if err != nil {
 		return &mcp.CallToolResult{
 			Content: []mcp.Content{
@@ -536,7 +483,7 @@ func (ft *FileTools) uploadFromLocal(ctx context.Context, req *mcp.CallToolReque
 	}
 
 	// Upload the file using the existing method
-	err = ft.client.RapidUploadOrByOSS(args.DirID, fileName, fileSize, file)
+	err = ft.client.RapidUploadOrByOSSCtx(ctx, args.DirID, fileName, fileSize, file)
 	if err != nil {
 		return &mcp.CallToolResult{
 			Content: []mcp.Content{
@@ -574,59 +521,42 @@ func (ft *FileTools) uploadFromLocal(ctx context.Context, req *mcp.CallToolReque
 }
 
 func (ft *FileTools) downloadFile(ctx context.Context, req *mcp.CallToolRequest, args DownloadFileArgs) (*mcp.CallToolResult, any, error) {
-	// Get download info with the specified User-Agent
-	downloadInfo, err := ft.client.DownloadWithUA(args.PickCode, args.UserAgent)
-	if err != nil {
-		return &mcp.CallToolResult{
-			Content: []mcp.Content{
-				&mcp.TextContent{
-					Text: fmt.Sprintf("Failed to get download info: %v", err),
+	file := &driver.File{PickCode: args.PickCode}
+	if args.FileID != "" {
+		// Look the file up so its size and SHA1 can be verified
+		var err error
+		file, err = ft.client.GetFileCtx(ctx, args.FileID)
+		if err != nil {
+			return &mcp.CallToolResult{
+				Content: []mcp.Content{
+					&mcp.TextContent{
+						Text: fmt.Sprintf("Failed to get file info: %v", err),
+					},
 				},
-			},
-			IsError: true,
-		}, nil, nil
-	}
-
-	// Perform the actual download using the same User-Agent
-	reqDownload := ft.client.Client.R()
-	if args.UserAgent != "" {
-		reqDownload = reqDownload.SetHeader("User-Agent", args.UserAgent)
-	}
-
-	resp, err := reqDownload.SetDoNotParseResponse(true).Get(downloadInfo.Url.Url)
-	if err != nil {
+				IsError: true,
+			}, nil, nil
+		}
+	} else if args.PickCode == "" {
 		return &mcp.CallToolResult{
 			Content: []mcp.Content{
 				&mcp.TextContent{
-					Text: fmt.Sprintf("Failed to download file: %v", err),
+					Text: "Either file_id or pick_code must be specified",
 				},
 			},
 			IsError: true,
 		}, nil, nil
 	}
-	defer resp.RawBody().Close()
 
-	// Create the local file
-	localFile, err := os.Create(args.LocalPath)
-	if err != nil {
-		return &mcp.CallToolResult{
-			Content: []mcp.Content{
-				&mcp.TextContent{
-					Text: fmt.Sprintf("Failed to create local file: %v", err),
-				},
-			},
-			IsError: true,
-		}, nil, nil
+	opts := []driver.DownloaderOption{driver.WithDownloaderUA(args.UserAgent)}
+	if args.Resume {
+		opts = append(opts, driver.WithDownloaderResume())
 	}
-	defer localFile.Close()
-
-	// Copy the downloaded content to the local file
-	_, err = io.Copy(localFile, resp.RawBody())
-	if err != nil {
+	d := driver.NewDownloader(ft.client, opts...)
+	if err := d.DownloadCtx(ctx, file, args.LocalPath); err != nil {
 		return &mcp.CallToolResult{
 			Content: []mcp.Content{
 				&mcp.TextContent{
-					Text: fmt.Sprintf("Failed to save file locally: %v", err),
+					Text: fmt.Sprintf("Failed to download file: %v", err),
 				},
 			},
 			IsError: true,
@@ -660,7 +590,7 @@ func (ft *FileTools) downloadFile(ctx context.Context, req *mcp.CallToolRequest,
 
 func (ft *FileTools) getDownloadInfo(ctx context.Context, req *mcp.CallToolRequest, args GetDownloadInfoArgs) (*mcp.CallToolResult, any, error) {
 	// Get download info with the specified User-Agent
-	downloadInfo, err := ft.client.DownloadWithUA(args.PickCode, args.UserAgent)
+	downloadInfo, err := ft.client.DownloadWithUACtx(ctx, args.PickCode, args.UserAgent)
 	if err != nil {
 		return &mcp.CallToolResult{
 			Content: []mcp.Content{
@@ -72,7 +72,7 @@ func (ot *OfflineTools) listOfflineTasks(ctx context.Context, req *mcp.CallToolR
 		page = 1
 	}
 
-	result, err := ot.client.ListOfflineTask(page)
+	result, err := ot.client.ListOfflineTaskCtx(ctx, page)
 	if err != nil {
 		return &mcp.CallToolResult{
 			Content: []mcp.Content{
@@ -150,7 +150,7 @@ func (ot *OfflineTools) addOfflineTaskURIs(ctx context.Context, req *mcp.CallToo
 		}, nil, nil
 	}
 
-	hashes, err := ot.client.AddOfflineTaskURIs(args.URIs, args.SaveDirID)
+	hashes, err := ot.client.AddOfflineTaskURIsCtx(ctx, args.URIs, args.SaveDirID)
 	if err != nil {
 		return &mcp.CallToolResult{
 			Content: []mcp.Content{
@@ -199,7 +199,7 @@ func (ot *OfflineTools) deleteOfflineTasks(ctx context.Context, req *mcp.CallToo
 		}, nil, nil
 	}
 
-	err := ot.client.DeleteOfflineTasks(args.Hashes, args.DeleteFiles)
+	err := ot.client.DeleteOfflineTasksCtx(ctx, args.Hashes, args.DeleteFiles)
 	if err != nil {
 		return &mcp.CallToolResult{
 			Content: []mcp.Content{
@@ -221,7 +221,7 @@ func (ot *OfflineTools) deleteOfflineTasks(ctx context.Context, req *mcp.CallToo
 }
 
 func (ot *OfflineTools) clearOfflineTasks(ctx context.Context, req *mcp.CallToolRequest, args ClearOfflineTasksArgs) (*mcp.CallToolResult, any, error) {
-	err := ot.client.ClearOfflineTasks(args.ClearFlag)
+	err := ot.client.ClearOfflineTasksCtx(ctx, args.ClearFlag)
 	if err != nil {
 		return &mcp.CallToolResult{
 			Content: []mcp.Content{
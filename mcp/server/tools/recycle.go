@@ -68,7 +68,7 @@ func (rt *RecycleTools) listRecycleBin(ctx context.Context, req *mcp.CallToolReq
 		limit = 40
 	}
 
-	items, err := rt.client.ListRecycleBin(offset, limit)
+	items, err := rt.client.ListRecycleBinCtx(ctx, offset, limit)
 	if err != nil {
 		return &mcp.CallToolResult{
 			Content: []mcp.Content{
@@ -113,7 +113,7 @@ func (rt *RecycleTools) revertRecycleBin(ctx context.Context, req *mcp.CallToolR
 		}, nil, nil
 	}
 
-	err := rt.client.RevertRecycleBin(args.ItemIDs...)
+	err := rt.client.RevertRecycleBinCtx(ctx, args.ItemIDs...)
 	if err != nil {
 		return &mcp.CallToolResult{
 			Content: []mcp.Content{
@@ -146,7 +146,7 @@ func (rt *RecycleTools) cleanRecycleBin(ctx context.Context, req *mcp.CallToolRe
 		}, nil, nil
 	}
 
-	err := rt.client.CleanRecycleBin(args.Password, args.ItemIDs...)
+	err := rt.client.CleanRecycleBinCtx(ctx, args.Password, args.ItemIDs...)
 	if err != nil {
 		return &mcp.CallToolResult{
 			Content: []mcp.Content{
@@ -49,7 +49,7 @@ func (st *SearchTools) search(ctx context.Context, req *mcp.CallToolRequest, arg
 		Asc:         args.Asc,
 	}
 
-	result, err := st.client.Search(opts)
+	result, err := st.client.SearchCtx(ctx, opts)
 	if err != nil {
 		return &mcp.CallToolResult{
 			Content: []mcp.Content{
@@ -53,7 +53,7 @@ func (st *ShareTools) getShareSnap(ctx context.Context, req *mcp.CallToolRequest
 		queries = append(queries, driver.QueryOffset(args.Offset))
 	}
 
-	result, err = st.client.GetShareSnap(args.ShareCode, args.ReceiveCode, args.DirID, queries...)
+	result, err = st.client.GetShareSnapCtx(ctx, args.ShareCode, args.ReceiveCode, args.DirID, queries...)
 	if err != nil {
 		return &mcp.CallToolResult{
 			Content: []mcp.Content{
@@ -64,8 +64,18 @@ type EcdhCipher struct {
 
 // NewEcdhCipher 新建EcdhCipher
 func NewEcdhCipher() (*EcdhCipher, error) {
-	x := big.NewInt(0).SetBytes(remotePubKey[:p224BaseLen])
-	y := big.NewInt(0).SetBytes(remotePubKey[p224BaseLen:])
+	return NewEcdhCipherWithKey(remotePubKey)
+}
+
+// NewEcdhCipherWithKey is like NewEcdhCipher but exchanges keys with the
+// P-224 public key remote, its X and Y coordinates of 28 bytes each,
+// instead of the 115 one.
+func NewEcdhCipherWithKey(remote []byte) (*EcdhCipher, error) {
+	if len(remote) != 2*p224BaseLen {
+		return nil, fmt.Errorf("错误的remote public key长度: %d", len(remote))
+	}
+	x := big.NewInt(0).SetBytes(remote[:p224BaseLen])
+	y := big.NewInt(0).SetBytes(remote[p224BaseLen:])
 	remotePublic := ecdh.Point{X: x, Y: y}
 
 	p224 := ecdh.Generic(elliptic.P224())
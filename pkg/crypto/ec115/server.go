@@ -0,0 +1,141 @@
+package ec115
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+
+	"github.com/aead/ecdh"
+	"github.com/andreburgaud/crypt2go/padding"
+)
+
+// tokenLen is the size of a decoded token, see EncodeToken.
+const tokenLen = 48
+
+// GenerateServerKey returns a P-224 key pair for test servers standing in
+// for 115: the private key, and the public key to pass to
+// NewEcdhCipherWithKey.
+func GenerateServerKey() (priv, pub []byte, err error) {
+	private, public, err := ecdh.Generic(elliptic.P224()).GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	p := public.(ecdh.Point)
+	pub = make([]byte, 2*p224BaseLen)
+	p.X.FillBytes(pub[:p224BaseLen])
+	p.Y.FillBytes(pub[p224BaseLen:])
+	return private.([]byte), pub, nil
+}
+
+// ServerCipher is the server side of an EcdhCipher. It is meant for test
+// servers standing in for 115.
+type ServerCipher struct {
+	key []byte
+	iv  []byte
+}
+
+// NewServerCipher derives, with the server private key priv, the cipher of
+// the client which sent token, see EncodeToken.
+func NewServerCipher(priv []byte, token string) (*ServerCipher, error) {
+	tmp, err := base64.StdEncoding.DecodeString(token)
+	if err != nil {
+		return nil, err
+	}
+	if len(tmp) != tokenLen {
+		return nil, fmt.Errorf("错误的token长度: %d", len(tmp))
+	}
+	// the client public key is split in two halves, masked with r1 and r2
+	r1, r2 := tmp[15], tmp[39]
+	pubKey := make([]byte, 0, p224BaseLen+2)
+	for i := 0; i < 15; i++ {
+		pubKey = append(pubKey, tmp[i]^r1)
+	}
+	for i := 24; i < 39; i++ {
+		pubKey = append(pubKey, tmp[i]^r2)
+	}
+	if pubKey[0] != p224BaseLen+1 || (pubKey[1] != 0x02 && pubKey[1] != 0x03) {
+		return nil, fmt.Errorf("错误的public key")
+	}
+	x := new(big.Int).SetBytes(pubKey[2:])
+	y, err := p224Y(x, pubKey[1] == 0x03)
+	if err != nil {
+		return nil, err
+	}
+
+	secret := ecdh.Generic(elliptic.P224()).ComputeSecret(priv, ecdh.Point{X: x, Y: y})
+	return &ServerCipher{key: secret[:aes.BlockSize], iv: secret[len(secret)-aes.BlockSize:]}, nil
+}
+
+// p224Y returns the Y coordinate of the P-224 point of coordinate x, odd or
+// even.
+func p224Y(x *big.Int, odd bool) (*big.Int, error) {
+	params := elliptic.P224().Params()
+	// y² = x³ - 3x + b
+	rhs := new(big.Int).Exp(x, big.NewInt(3), params.P)
+	rhs.Sub(rhs, new(big.Int).Mul(x, big.NewInt(3)))
+	rhs.Add(rhs, params.B)
+	rhs.Mod(rhs, params.P)
+	y := new(big.Int).ModSqrt(rhs, params.P)
+	if y == nil {
+		return nil, fmt.Errorf("public key不在曲线上")
+	}
+	if (y.Bit(0) == 1) != odd {
+		y.Sub(params.P, y)
+	}
+	return y, nil
+}
+
+// Decrypt decrypts a request payload encrypted by EcdhCipher.Encrypt.
+func (c *ServerCipher) Decrypt(cipherText []byte) ([]byte, error) {
+	if len(cipherText) == 0 || len(cipherText)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("错误的密文长度: %d", len(cipherText))
+	}
+	block, err := aes.NewCipher(c.key)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, len(cipherText))
+	cipher.NewCBCDecrypter(block, c.iv).CryptBlocks(data, cipherText)
+	return padding.NewPkcs7Padding(aes.BlockSize).Unpad(data)
+}
+
+// Encrypt encrypts a response payload for EcdhCipher.Decrypt, which
+// accepts up to 8KB.
+func (c *ServerCipher) Encrypt(plainText []byte) ([]byte, error) {
+	if len(plainText) > 0x2000 {
+		return nil, fmt.Errorf("明文过长: %d", len(plainText))
+	}
+	// an lz4 block made of literals only
+	lz4Block := &bytes.Buffer{}
+	n := len(plainText)
+	token := byte(min(n, 15) << 4)
+	lz4Block.WriteByte(token)
+	if n >= 15 {
+		for rest := n - 15; ; rest -= 255 {
+			if rest < 255 {
+				lz4Block.WriteByte(byte(rest))
+				break
+			}
+			lz4Block.WriteByte(255)
+		}
+	}
+	lz4Block.Write(plainText)
+
+	length := lz4Block.Len()
+	data := append([]byte{byte(length), byte(length >> 8)}, lz4Block.Bytes()...)
+	if pad := len(data) % aes.BlockSize; pad > 0 {
+		data = append(data, make([]byte, aes.BlockSize-pad)...)
+	}
+	block, err := aes.NewCipher(c.key)
+	if err != nil {
+		return nil, err
+	}
+	cipherText := make([]byte, len(data))
+	cipher.NewCBCEncrypter(block, c.iv).CryptBlocks(cipherText, data)
+	return cipherText, nil
+}
@@ -2,12 +2,17 @@ package m115
 
 import (
 	"crypto/rand"
+	"crypto/rsa"
 	"encoding/base64"
+	"errors"
 	"io"
+	"math/big"
 )
 
 type Key [16]byte
 
+var errShortData = errors.New("m115: data too short")
+
 func GenerateKey() Key {
 	key := Key{}
 	_, _ = io.ReadFull(rand.Reader, key[:])
@@ -15,6 +20,15 @@ func GenerateKey() Key {
 }
 
 func Encode(input []byte, key Key) (output string) {
+	return EncodeWith(DefaultPublicKey, input, key)
+}
+
+func Decode(input string, key Key) (output []byte, err error) {
+	return DecodeWith(DefaultPublicKey, input, key)
+}
+
+// EncodeWith is like Encode but encrypts with pub instead of the 115 public key.
+func EncodeWith(pub *rsa.PublicKey, input []byte, key Key) (output string) {
 	// Prepare buffer
 	buf := make([]byte, 16+len(input))
 	// Copy key and data to buffer
@@ -25,18 +39,22 @@ func Encode(input []byte, key Key) (output string) {
 	reverseBytes(buf[16:])
 	xorTransform(buf[16:], xorClientKey)
 	// Encrypt and encode
-	output = base64.StdEncoding.EncodeToString(rsaEncrypt(buf))
+	output = base64.StdEncoding.EncodeToString(rsaEncrypt(buf, big.NewInt(int64(pub.E)), pub.N))
 	return
 }
 
-func Decode(input string, key Key) (output []byte, err error) {
+// DecodeWith is like Decode but decrypts with pub instead of the 115 public key.
+func DecodeWith(pub *rsa.PublicKey, input string, key Key) (output []byte, err error) {
 	// Base64 decode
 	data, err := base64.StdEncoding.DecodeString(input)
 	if err != nil {
 		return
 	}
 	// RSA decrypt
-	data = rsaDecrypt(data)
+	data = rsaDecrypt(data, big.NewInt(int64(pub.E)), pub.N)
+	if len(data) < 16 {
+		return nil, errShortData
+	}
 	// XOR decode
 	output = make([]byte, len(data)-16)
 	copy(output, data[16:])
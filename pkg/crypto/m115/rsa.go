@@ -3,6 +3,7 @@ package m115
 import (
 	"bytes"
 	"crypto/rand"
+	"crypto/rsa"
 	"io"
 	"math/big"
 )
@@ -15,17 +16,20 @@ var (
 			"0a6f1eda4f7262f136420c07c331b871bf139f74f3010e3c4fe57df3afb71683", 16)
 	_E, _ = big.NewInt(0).SetString("10001", 16)
 
-	_KeyLength = _N.BitLen() / 8
+	// DefaultPublicKey is the RSA public key of the 115 service.
+	DefaultPublicKey = &rsa.PublicKey{N: _N, E: int(_E.Int64())}
 )
 
-func rsaEncrypt(input []byte) []byte {
+// rsaEncrypt pads and encrypts input slice by slice with exponent exp and modulus n.
+func rsaEncrypt(input []byte, exp, n *big.Int) []byte {
+	keyLength := n.BitLen() / 8
 	buf := &bytes.Buffer{}
 	for remainSize := len(input); remainSize > 0; {
-		sliceSize := _KeyLength - 11
+		sliceSize := keyLength - 11
 		if sliceSize > remainSize {
 			sliceSize = remainSize
 		}
-		rsaEncryptSlice(input[:sliceSize], buf)
+		rsaEncryptSlice(input[:sliceSize], exp, n, buf)
 
 		input = input[sliceSize:]
 		remainSize -= sliceSize
@@ -33,13 +37,14 @@ func rsaEncrypt(input []byte) []byte {
 	return buf.Bytes()
 }
 
-func rsaEncryptSlice(input []byte, w io.Writer) {
+func rsaEncryptSlice(input []byte, exp, n *big.Int, w io.Writer) {
+	keyLength := n.BitLen() / 8
 	// Padding
-	padSize := _KeyLength - len(input) - 3
+	padSize := keyLength - len(input) - 3
 	padData := make([]byte, padSize)
 	_, _ = rand.Read(padData)
 	// Prepare message
-	buf := make([]byte, _KeyLength)
+	buf := make([]byte, keyLength)
 	buf[0], buf[1] = 0, 2
 	for i, b := range padData {
 		buf[2+i] = b%0xff + 0x01
@@ -48,23 +53,25 @@ func rsaEncryptSlice(input []byte, w io.Writer) {
 	copy(buf[padSize+3:], input)
 	msg := big.NewInt(0).SetBytes(buf)
 	// RSA Encrypt
-	ret := big.NewInt(0).Exp(msg, _E, _N).Bytes()
+	ret := big.NewInt(0).Exp(msg, exp, n).Bytes()
 	// Fill zeros at beginning
-	if fillSize := _KeyLength - len(ret); fillSize > 0 {
+	if fillSize := keyLength - len(ret); fillSize > 0 {
 		zeros := make([]byte, fillSize)
 		_, _ = w.Write(zeros)
 	}
 	_, _ = w.Write(ret)
 }
 
-func rsaDecrypt(input []byte) []byte {
+// rsaDecrypt decrypts and un-pads input slice by slice with exponent exp and modulus n.
+func rsaDecrypt(input []byte, exp, n *big.Int) []byte {
+	keyLength := n.BitLen() / 8
 	buf := &bytes.Buffer{}
 	for remainSize := len(input); remainSize > 0; {
-		sliceSize := _KeyLength
+		sliceSize := keyLength
 		if sliceSize > remainSize {
 			sliceSize = remainSize
 		}
-		rsaDecryptSlice(input[:sliceSize], buf)
+		rsaDecryptSlice(input[:sliceSize], exp, n, buf)
 
 		input = input[sliceSize:]
 		remainSize -= sliceSize
@@ -72,10 +79,10 @@ func rsaDecrypt(input []byte) []byte {
 	return buf.Bytes()
 }
 
-func rsaDecryptSlice(input []byte, w io.Writer) {
+func rsaDecryptSlice(input []byte, exp, n *big.Int, w io.Writer) {
 	// RSA Decrypt
 	msg := big.NewInt(0).SetBytes(input)
-	ret := big.NewInt(0).Exp(msg, _E, _N).Bytes()
+	ret := big.NewInt(0).Exp(msg, exp, n).Bytes()
 	// Un-padding
 	for i, b := range ret {
 		// Find the beginning of plaintext
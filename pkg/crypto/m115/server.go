@@ -0,0 +1,41 @@
+package m115
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+)
+
+// ServerDecode is the server side of EncodeWith: it decrypts a request payload
+// with priv and returns the data and the key chosen by the client.
+// It is meant for test servers standing in for 115.
+func ServerDecode(priv *rsa.PrivateKey, input string) (output []byte, key Key, err error) {
+	data, err := base64.StdEncoding.DecodeString(input)
+	if err != nil {
+		return
+	}
+	data = rsaDecrypt(data, priv.D, priv.N)
+	if len(data) < 16 {
+		return nil, key, errShortData
+	}
+	copy(key[:], data[:16])
+	output = make([]byte, len(data)-16)
+	copy(output, data[16:])
+	xorTransform(output, xorClientKey)
+	reverseBytes(output)
+	xorTransform(output, xorDeriveKey(key[:], 4))
+	return
+}
+
+// ServerEncode is the server side of DecodeWith: it encodes a response payload
+// for a client which sent key, signing it with priv.
+func ServerEncode(priv *rsa.PrivateKey, input []byte, key Key) (output string) {
+	serverKey := GenerateKey()
+	buf := make([]byte, 16+len(input))
+	copy(buf, serverKey[:])
+	copy(buf[16:], input)
+	xorTransform(buf[16:], xorDeriveKey(key[:], 4))
+	reverseBytes(buf[16:])
+	xorTransform(buf[16:], xorDeriveKey(serverKey[:], 12))
+	output = base64.StdEncoding.EncodeToString(rsaEncrypt(buf, priv.D, priv.N))
+	return
+}
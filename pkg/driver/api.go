@@ -1,5 +1,10 @@
 package driver
 
+import (
+	"reflect"
+	"strings"
+)
+
 const (
 	ApiGetVersion = "https://appversion.115.com/1/web/1.0/api/chrome"
 
@@ -8,7 +13,7 @@ const (
 	ApiUserInfo    = "https://my.115.com/?ct=ajax&ac=nav"
 	ApiStatusCheck = "https://my.115.com/?ct=guide&ac=status"
 	// dir
-	ApiDirAdd = "https://webapi.115.com/files/add"
+	ApiDirAdd      = "https://webapi.115.com/files/add"
 	ApiDirName2CID = "https://webapi.115.com/files/getid"
 
 	// file
@@ -18,14 +23,14 @@ const (
 	ApiFileRename    = "https://webapi.115.com/files/batch_rename"
 	ApiFileIndexInfo = "https://webapi.115.com/files/index_info"
 
-	ApiFileList       = "https://webapi.115.com/files"
-	ApiFileList1       = "http://web.api.115.com/files"
+	ApiFileList  = "https://webapi.115.com/files"
+	ApiFileList1 = "http://web.api.115.com/files"
 	// ApiFileList2       = "http://anxia.com/webapi/files"
 	// ApiFileList3       = "http://v.anxia.com/webapi/files"
 	ApiFileListByName = "https://aps.115.com/natsort/files.php"
 
-	ApiFileStat = "https://webapi.115.com/category/get"
-	ApiFileInfo = "https://webapi.115.com/files/get_info"
+	ApiFileStat   = "https://webapi.115.com/category/get"
+	ApiFileInfo   = "https://webapi.115.com/files/get_info"
 	ApiFileSearch = "https://webapi.115.com/files/search"
 
 	// share
@@ -61,4 +66,173 @@ const (
 	ApiRecycleList   = "https://webapi.115.com/rb"
 	ApiRecycleClean  = "https://webapi.115.com/rb/clean"
 	ApiRecycleRevert = "https://webapi.115.com/rb/revert"
-)
\ No newline at end of file
+)
+
+// Endpoints holds the URLs of the APIs called by a Pan115Client, so a client
+// can be pointed at a mirror, a proxy or a local stand-in server.
+type Endpoints struct {
+	GetVersion string
+
+	// login
+	LoginCheck  string
+	UserInfo    string
+	StatusCheck string
+	// dir
+	DirAdd      string
+	DirName2CID string
+
+	// file
+	FileDelete    string
+	FileMove      string
+	FileCopy      string
+	FileRename    string
+	FileIndexInfo string
+	FileList      string
+	FileList1     string
+	FileStat      string
+	FileInfo      string
+	FileSearch    string
+
+	// share
+	ShareSnap string
+
+	// download
+	DownloadGetUrl        string
+	DownloadGetShareUrl   string
+	AndroidDownloadGetUrl string
+
+	// offline download
+	AddOfflineUrl   string
+	DelOfflineUrl   string
+	ListOfflineUrl  string
+	ClearOfflineUrl string
+
+	// upload
+	UploadInfo        string
+	GetUploadEndpoint string
+	UploadInit        string
+	UploadOSSToken    string
+
+	// qrcode
+	QrcodeToken        string
+	QrcodeStatus       string
+	QrcodeLoginWithApp string
+
+	// recycle
+	RecycleList   string
+	RecycleClean  string
+	RecycleRevert string
+
+	// OSS is the Aliyun OSS endpoint used for uploads, a host with an optional scheme.
+	OSS string
+}
+
+// DefaultEndpoints returns the endpoints of the 115 service.
+func DefaultEndpoints() *Endpoints {
+	return &Endpoints{
+		GetVersion: ApiGetVersion,
+
+		LoginCheck:  ApiLoginCheck,
+		UserInfo:    ApiUserInfo,
+		StatusCheck: ApiStatusCheck,
+
+		DirAdd:      ApiDirAdd,
+		DirName2CID: ApiDirName2CID,
+
+		FileDelete:    ApiFileDelete,
+		FileMove:      ApiFileMove,
+		FileCopy:      ApiFileCopy,
+		FileRename:    ApiFileRename,
+		FileIndexInfo: ApiFileIndexInfo,
+		FileList:      ApiFileList,
+		FileList1:     ApiFileList1,
+		FileStat:      ApiFileStat,
+		FileInfo:      ApiFileInfo,
+		FileSearch:    ApiFileSearch,
+
+		ShareSnap: ApiShareSnap,
+
+		DownloadGetUrl:        ApiDownloadGetUrl,
+		DownloadGetShareUrl:   ApiDownloadGetShareUrl,
+		AndroidDownloadGetUrl: AndroidApiDownloadGetUrl,
+
+		AddOfflineUrl:   ApiAddOfflineUrl,
+		DelOfflineUrl:   ApiDelOfflineUrl,
+		ListOfflineUrl:  ApiListOfflineUrl,
+		ClearOfflineUrl: ApiClearOfflineUrl,
+
+		UploadInfo:        ApiUploadInfo,
+		GetUploadEndpoint: ApiGetUploadEndpoint,
+		UploadInit:        ApiUploadInit,
+		UploadOSSToken:    ApiUploadOSSToken,
+
+		QrcodeToken:        ApiQrcodeToken,
+		QrcodeStatus:       ApiQrcodeStatus,
+		QrcodeLoginWithApp: ApiQrcodeLoginWithApp,
+
+		RecycleList:   ApiRecycleList,
+		RecycleClean:  ApiRecycleClean,
+		RecycleRevert: ApiRecycleRevert,
+
+		OSS: OSSEndpoint,
+	}
+}
+
+// WithBaseHost returns a copy of e where the scheme and host of every 115 API
+// URL are replaced by base, e.g. "http://127.0.0.1:8080". The OSS endpoint is
+// left untouched.
+func (e *Endpoints) WithBaseHost(base string) *Endpoints {
+	base = strings.TrimSuffix(base, "/")
+	return e.rewrite(func(origin string) (string, bool) {
+		return base, true
+	})
+}
+
+// WithHost returns a copy of e where the URLs on host, e.g. "lixian.115.com",
+// are rerouted to base. The OSS endpoint is rerouted as well when it equals host.
+func (e *Endpoints) WithHost(host, base string) *Endpoints {
+	base = strings.TrimSuffix(base, "/")
+	n := e.rewrite(func(origin string) (string, bool) {
+		_, h, _ := strings.Cut(origin, "://")
+		return base, h == host
+	})
+	if strings.TrimPrefix(strings.TrimPrefix(n.OSS, "https://"), "http://") == host {
+		n.OSS = base
+	}
+	return n
+}
+
+// rewrite returns a copy of e with the origin (scheme://host) of each API URL
+// replaced by the result of fn when it reports ok.
+func (e *Endpoints) rewrite(fn func(origin string) (string, bool)) *Endpoints {
+	n := *e
+	v := reflect.ValueOf(&n).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Field(i)
+		if v.Type().Field(i).Name == "OSS" || f.Kind() != reflect.String {
+			continue
+		}
+		origin, rest := splitOrigin(f.String())
+		if origin == "" {
+			continue
+		}
+		if newOrigin, ok := fn(origin); ok {
+			f.SetString(newOrigin + rest)
+		}
+	}
+	return &n
+}
+
+// splitOrigin splits a raw URL into "scheme://host" and the remaining path and
+// query. It does not use url.Parse because some endpoints are format strings.
+func splitOrigin(raw string) (origin, rest string) {
+	i := strings.Index(raw, "://")
+	if i < 0 {
+		return "", raw
+	}
+	j := strings.IndexAny(raw[i+3:], "/?")
+	if j < 0 {
+		return raw, ""
+	}
+	return raw[:i+3+j], raw[i+3+j:]
+}
@@ -1,13 +1,20 @@
 package driver
 
+import "context"
+
 // GetAppVersion get app version (win, android, mac, mac_arc, etc...)
 func (c *Pan115Client) GetAppVersion() ([]AppVersion, error) {
+	return c.GetAppVersionCtx(context.Background())
+}
+
+// GetAppVersionCtx is like GetAppVersion but uses ctx for the request.
+func (c *Pan115Client) GetAppVersionCtx(ctx context.Context) ([]AppVersion, error) {
 	result := VersionResp{}
-	req := c.NewRequest().
+	req := c.newRequest(ctx).
 		SetResult(&result).
 		ForceContentType("application/json;charset=UTF-8")
 
-	resp, err := req.Get(ApiGetVersion)
+	resp, err := c.get(req, c.endpoints().GetVersion)
 
 	err = CheckErr(err, &result, resp)
 	if err != nil {
@@ -40,10 +47,7 @@ func (resp *VersionResp) Err(respBody ...string) error {
 	if resp.State {
 		return nil
 	}
-	if len(respBody) > 0 {
-		return GetErr(resp.ErrCode, respBody[0])
-	}
-	return GetErr(resp.ErrCode)
+	return responseErr(resp.ErrCode, resp.Error, respBody...)
 }
 
 type AppVersion struct {
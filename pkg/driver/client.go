@@ -1,25 +1,66 @@
 package driver
 
 import (
+	"context"
+	"crypto/rsa"
+	"log/slog"
 	"net/http"
+	"sync"
 
+	cipher "github.com/SheltonZhu/115driver/pkg/crypto/ec115"
+	crypto "github.com/SheltonZhu/115driver/pkg/crypto/m115"
 	"github.com/go-resty/resty/v2"
 )
 
 // Pan115Client driver client
+//
+// A Pan115Client is safe for concurrent use by multiple goroutines once it is
+// configured: options and the Set* methods must be applied before the client is
+// shared. Every API call builds its own request, and the upload metadata
+// (UserID, Userkey, UploadMetaInfo) is fetched lazily under a lock, so those
+// fields should not be read or written directly while calls are in flight.
 type Pan115Client struct {
-	Client            *resty.Client
+	Client *resty.Client
+	// Deprecated: requests are created per call and are no longer stored here.
 	Request           *resty.Request
 	UserID            int64
 	Userkey           string
 	UploadMetaInfo    *UploadMetaInfo
 	UseInternalUpload bool
+	// Endpoints are the API URLs used by the client, see WithEndpoints.
+	Endpoints *Endpoints
+
+	// retryPolicy retries the failed API calls, see WithRetryPolicy.
+	retryPolicy *RetryPolicy
+	// rateLimiter limits the rate of the API calls, see WithRateLimiter.
+	rateLimiter *RateLimiter
+	// logger receives a record per API call, see WithLogger.
+	logger *slog.Logger
+	// downloadStrategies resolve the download URLs, see WithDownloadStrategies.
+	downloadStrategies []DownloadStrategy
+	downloadFailover   []error
+	// downloadCache caches the download URLs, see WithDownloadCache.
+	downloadCache *DownloadCache
+	// paths caches the IDs of the resolved paths, see WithPathCacheSize.
+	paths *pathCache
+	// instrumentation observes the API calls and transfers, see WithInstrumentation.
+	instrumentation Instrumentation
+	// m115Key encodes the payloads of the download and offline APIs.
+	m115Key *rsa.PublicKey
+	// ec115Key encrypts the payloads of the upload init API, the 115 key when nil.
+	ec115Key []byte
+	// mu guards UserID, Userkey and UploadMetaInfo.
+	mu sync.RWMutex
+	// uploadInfoMu serialises the lazy fetch of the upload metadata.
+	uploadInfoMu sync.Mutex
 }
 
 // New creates Client with customized options.
 func New(opts ...Option) *Pan115Client {
 	c := &Pan115Client{
-		Client: resty.New(),
+		Client:    resty.New(),
+		Endpoints: DefaultEndpoints(),
+		paths:     newPathCache(DefaultPathCacheSize),
 	}
 	if len(opts) > 0 {
 		for _, optFunc := range opts {
@@ -69,14 +110,79 @@ func (c *Pan115Client) SetProxy(proxy string) *Pan115Client {
 	return c
 }
 
+// NewRequest creates a new request on the underlying resty client.
 func (c *Pan115Client) NewRequest() *resty.Request {
-	c.Request = c.Client.R()
-	return c.Request
+	return c.Client.R()
+}
+
+// newRequest creates a request bound to ctx, so cancellation and deadlines of
+// ctx abort the underlying HTTP call.
+func (c *Pan115Client) newRequest(ctx context.Context) *resty.Request {
+	return c.NewRequest().SetContext(ctx)
 }
 
+// GetRequest returns the request stored in c.Request or a new one.
+//
+// Deprecated: sharing a request between calls is not safe for concurrent use,
+// use NewRequest instead.
 func (c *Pan115Client) GetRequest() *resty.Request {
 	if c.Request != nil {
 		return c.Request
 	}
 	return c.NewRequest()
 }
+
+// endpoints returns the client endpoints, falling back to the defaults.
+func (c *Pan115Client) endpoints() *Endpoints {
+	if c.Endpoints == nil {
+		return DefaultEndpoints()
+	}
+	return c.Endpoints
+}
+
+// m115PublicKey returns the RSA key used to encode m115 payloads.
+func (c *Pan115Client) m115PublicKey() *rsa.PublicKey {
+	if c.m115Key == nil {
+		return crypto.DefaultPublicKey
+	}
+	return c.m115Key
+}
+
+// ecdhCipher returns a cipher for the payloads of the upload init API.
+func (c *Pan115Client) ecdhCipher() (*cipher.EcdhCipher, error) {
+	if c.ec115Key == nil {
+		return cipher.NewEcdhCipher()
+	}
+	return cipher.NewEcdhCipherWithKey(c.ec115Key)
+}
+
+// listURLs returns the file list URLs selected by o.
+func (c *Pan115Client) listURLs(o *ListOptions) []string {
+	if len(o.ApiURLs) > 0 {
+		return o.ApiURLs
+	}
+	e := c.endpoints()
+	if o.multiUrls {
+		return []string{e.FileList, e.FileList1}
+	}
+	return []string{e.FileList}
+}
+
+func (c *Pan115Client) getUserID() int64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.UserID
+}
+
+func (c *Pan115Client) setUserID(userID int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.UserID = userID
+}
+
+// uploadMeta returns a consistent snapshot of the upload metadata.
+func (c *Pan115Client) uploadMeta() (userID int64, userkey string, meta *UploadMetaInfo) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.UserID, c.Userkey, c.UploadMetaInfo
+}
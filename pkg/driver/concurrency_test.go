@@ -0,0 +1,119 @@
+package driver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newConcurrencyTestServer(t *testing.T, uploadInfoCalls *int32) *httptest.Server {
+	const fileCount = 120
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/files", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		offset, _ := strconv.Atoi(q.Get("offset"))
+		limit, _ := strconv.Atoi(q.Get("limit"))
+		var data []map[string]any
+		for i := offset; i < offset+limit && i < fileCount; i++ {
+			data = append(data, map[string]any{
+				"fid": strconv.Itoa(1000 + i),
+				"cid": q.Get("cid"),
+				"n":   "file" + strconv.Itoa(i),
+				"s":   i,
+			})
+		}
+		writeJSON(w, map[string]any{
+			"state":  true,
+			"cid":    q.Get("cid"),
+			"count":  fileCount,
+			"offset": offset,
+			"limit":  limit,
+			"data":   data,
+		})
+	})
+	mux.HandleFunc("/app/chrome/downurl", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{"state": false, "errno": 50003, "error": "pickcode does not exist"})
+	})
+	mux.HandleFunc("/app/uploadinfo", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(uploadInfoCalls, 1)
+		writeJSON(w, map[string]any{
+			"state":      true,
+			"user_id":    42,
+			"userkey":    "KEY",
+			"size_limit": 5 * GB,
+		})
+	})
+	mux.HandleFunc("/3.0/gettoken.php", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{
+			"StatusCode":      "200",
+			"AccessKeyID":     "id",
+			"AccessKeySecret": "secret",
+			"SecurityToken":   "token",
+		})
+	})
+	mux.HandleFunc("/app/1.0/web/1.0/check/sso", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{"state": 0, "data": map[string]any{"user_id": 42}})
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func TestConcurrentListDownloadUpload(t *testing.T) {
+	var uploadInfoCalls int32
+	srv := newConcurrencyTestServer(t, &uploadInfoCalls)
+	c := New(UA(), WithBaseHost(srv.URL))
+
+	const workers = 16
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(4)
+		go func() {
+			defer wg.Done()
+			files, err := c.ListWithLimit("0", 50)
+			if assert.NoError(t, err) {
+				assert.Len(t, *files, 120)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := c.Download("pickcode")
+			assert.ErrorIs(t, err, ErrPickCodeNotExist)
+		}()
+		go func() {
+			defer wg.Done()
+			ok, err := c.UploadAvailable()
+			assert.NoError(t, err)
+			assert.True(t, ok)
+			assert.NotEmpty(t, c.GenerateSignature("SHA1", "U_1_0"))
+			assert.NotEmpty(t, c.GenerateToken("SHA1", "PRE", "1", "10", "", ""))
+			_, err = c.GetOSSToken()
+			assert.NoError(t, err)
+		}()
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, c.LoginCheck())
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&uploadInfoCalls))
+	userID, userkey, meta := c.uploadMeta()
+	assert.Equal(t, int64(42), userID)
+	assert.Equal(t, "KEY", userkey)
+	require.NotNil(t, meta)
+	assert.Equal(t, int64(5*GB), meta.SizeLimit)
+}
@@ -0,0 +1,24 @@
+package driver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCtxMethodsHonourCanceledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	c := New()
+
+	_, err := c.ListCtx(ctx, "0")
+	assert.ErrorIs(t, err, context.Canceled)
+
+	_, err = c.DownloadCtx(ctx, "pickcode")
+	assert.ErrorIs(t, err, context.Canceled)
+
+	err = c.DeleteCtx(ctx, "1")
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -1,6 +1,8 @@
 package driver
 
 import (
+	"context"
+	"path"
 	"strings"
 
 	"github.com/go-resty/resty/v2"
@@ -8,73 +10,71 @@ import (
 
 // Mkdir make a new directory which name and parent directory id, return directory id
 func (c *Pan115Client) Mkdir(parentID string, name string) (string, error) {
+	return c.MkdirCtx(context.Background(), parentID, name)
+}
+
+// MkdirCtx is like Mkdir but uses ctx for the request.
+func (c *Pan115Client) MkdirCtx(ctx context.Context, parentID string, name string) (string, error) {
 	result := MkdirResp{}
 	form := map[string]string{
 		"pid":   parentID,
 		"cname": name,
 	}
-	req := c.NewRequest().
+	req := c.newRequest(ctx).
 		SetFormData(form).
 		SetResult(&result).
 		ForceContentType("application/json;charset=UTF-8")
 
-	resp, err := req.Post(ApiDirAdd)
+	resp, err := c.post(req, c.endpoints().DirAdd)
 
 	err = CheckErr(err, &result, resp)
 	if err != nil {
 		return "", err
 	}
+	if parentPath, ok := c.paths.pathOf(parentID); ok {
+		c.paths.put(pathEntry{path: path.Join(parentPath, name), id: string(result.CategoryID), isDir: true})
+	}
 	return string(result.CategoryID), nil
 }
 
 // List list all files and directories
 func (c *Pan115Client) List(dirID string, opts ...ListOption) (*[]File, error) {
-	return c.ListWithLimit(dirID, FileListLimit, opts...)
+	return c.ListCtx(context.Background(), dirID, opts...)
+}
+
+// ListCtx is like List but uses ctx for the requests.
+func (c *Pan115Client) ListCtx(ctx context.Context, dirID string, opts ...ListOption) (*[]File, error) {
+	return c.ListWithLimitCtx(ctx, dirID, FileListLimit, opts...)
 }
 
 const MaxDirPageLimit = 1150
 
 // ListWithLimit list all files and directories with limit
 func (c *Pan115Client) ListWithLimit(dirID string, limit int64, opts ...ListOption) (*[]File, error) {
-	if limit > MaxDirPageLimit {
-		limit = MaxDirPageLimit
-	}
-
-	o := DefaultListOptions()
-	if len(opts) > 0 {
-		for _, opt := range opts {
-			opt(o)
-		}
-	}
+	return c.ListWithLimitCtx(context.Background(), dirID, limit, opts...)
+}
 
-	apiURLs := o.ApiURLs
+// ListWithLimitCtx is like ListWithLimit but uses ctx for the requests.
+func (c *Pan115Client) ListWithLimitCtx(ctx context.Context, dirID string, limit int64, opts ...ListOption) (*[]File, error) {
+	l := c.newLister(ctx, dirID, limit, opts...)
+	defer l.Close()
 	var files []File
-	offset := int64(0)
-	for i := 0; ; i++ {
-		apiURL := apiURLs[i%len(apiURLs)]
-		req := c.NewRequest().ForceContentType("application/json;charset=UTF-8")
-		getFilesOpts := []GetFileOptions{
-			WithApiURL(apiURL),
-			WithLimit(limit),
-			WithOffset(offset),
-		}
-		result, err := GetFiles(req, dirID, getFilesOpts...)
-		if err != nil {
-			return nil, err
-		}
-		for _, fileInfo := range result.Files {
-			files = append(files, *(&File{}).from(&fileInfo))
-		}
-		offset = int64(result.Offset) + limit
-		if offset >= int64(result.Count) {
-			break
-		}
+	for l.Next() {
+		files = append(files, l.File())
+	}
+	if err := l.Err(); err != nil {
+		return nil, err
 	}
 	return &files, nil
 }
 
 // ListPage list files and directories with page
 func (c *Pan115Client) ListPage(dirID string, offset, limit int64, opts ...ListOption) (*[]File, error) {
+	return c.ListPageCtx(context.Background(), dirID, offset, limit, opts...)
+}
+
+// ListPageCtx is like ListPage but uses ctx for the request.
+func (c *Pan115Client) ListPageCtx(ctx context.Context, dirID string, offset, limit int64, opts ...ListOption) (*[]File, error) {
 	o := DefaultListOptions()
 	if len(opts) > 0 {
 		for _, opt := range opts {
@@ -82,15 +82,14 @@ func (c *Pan115Client) ListPage(dirID string, offset, limit int64, opts ...ListO
 		}
 	}
 
-	apiURLs := o.ApiURLs
+	apiURLs := c.listURLs(o)
 	var files []File
-	req := c.NewRequest().ForceContentType("application/json;charset=UTF-8")
-	getFilesOpts := []GetFileOptions{
+	getFilesOpts := append(o.fileOpts,
 		WithApiURL(apiURLs[0]),
 		WithLimit(limit),
 		WithOffset(offset),
-	}
-	result, err := GetFiles(req, dirID, getFilesOpts...)
+	)
+	result, err := c.getFiles(ctx, dirID, getFilesOpts...)
 	if err != nil {
 		return nil, err
 	}
@@ -104,6 +103,19 @@ func (c *Pan115Client) ListPage(dirID string, offset, limit int64, opts ...ListO
 }
 
 func GetFiles(req *resty.Request, dirID string, opts ...GetFileOptions) (*FileListResp, error) {
+	return getFiles(req, req.Get, dirID, opts...)
+}
+
+// getFiles is like GetFiles but sends the request through the client, so the
+// client retry policy applies.
+func (c *Pan115Client) getFiles(ctx context.Context, dirID string, opts ...GetFileOptions) (*FileListResp, error) {
+	req := c.newRequest(ctx).ForceContentType("application/json;charset=UTF-8")
+	return getFiles(req, func(url string) (*resty.Response, error) {
+		return c.get(req, url)
+	}, dirID, opts...)
+}
+
+func getFiles(req *resty.Request, send func(url string) (*resty.Response, error), dirID string, opts ...GetFileOptions) (*FileListResp, error) {
 	if dirID == "" {
 		dirID = "0"
 	}
@@ -123,14 +135,17 @@ func GetFiles(req *resty.Request, dirID string, opts ...GetFileOptions) (*FileLi
 		"show_dir":         o.GetshowDir(),
 		"limit":            o.GetPageSize(),
 		"snap":             "0",
-		"natsort":          "0",
+		"natsort":          o.GetNatSort(),
 		"record_open_time": "1",
 		"format":           "json",
-		"fc_mix":           "0",
+		"fc_mix":           o.GetFcMix(),
+	}
+	for k, v := range o.params() {
+		params[k] = v
 	}
 	req = req.SetQueryParams(params).
 		SetResult(&result)
-	resp, err := req.Get(o.GetApiURL())
+	resp, err := send(o.GetApiURL())
 	if err = CheckErr(err, &result, resp); err != nil {
 		return &FileListResp{}, err
 	}
@@ -141,11 +156,16 @@ func GetFiles(req *resty.Request, dirID string, opts ...GetFileOptions) (*FileLi
 }
 
 func (c *Pan115Client) DirName2CID(dir string) (*APIGetDirIDResp, error) {
+	return c.DirName2CIDCtx(context.Background(), dir)
+}
+
+// DirName2CIDCtx is like DirName2CID but uses ctx for the request.
+func (c *Pan115Client) DirName2CIDCtx(ctx context.Context, dir string) (*APIGetDirIDResp, error) {
 	result := APIGetDirIDResp{}
 	dir = strings.TrimPrefix(dir, "/")
-	req := c.NewRequest().ForceContentType("application/json;charset=UTF-8")
+	req := c.newRequest(ctx).ForceContentType("application/json;charset=UTF-8")
 	req.SetQueryParam("path", dir).SetResult(&result)
-	resp, err := req.Get(ApiDirName2CID)
+	resp, err := c.get(req, c.endpoints().DirName2CID)
 	if err = CheckErr(err, &result, resp); err != nil {
 		return nil, err
 	}
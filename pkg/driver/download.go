@@ -2,13 +2,18 @@ package driver
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"io"
+	"mime"
 	"net/http"
+	"net/url"
+	"path"
+	"slices"
 	"strings"
 
 	crypto "github.com/SheltonZhu/115driver/pkg/crypto/m115"
-	"github.com/go-resty/resty/v2"
+	"github.com/pkg/errors"
 )
 
 type FileDownloadUrl struct {
@@ -23,23 +28,48 @@ type DownloadInfo struct {
 	PickCode string          `json:"pick_code"`
 	Url      FileDownloadUrl `json:"url"`
 	Header   http.Header
+	// Strategy is the API which resolved the URL.
+	Strategy DownloadStrategy `json:"-"`
+
+	// resolve gets a new download URL for the file, when the client resolved it.
+	resolve    func(ctx context.Context) (*DownloadInfo, error)
+	httpClient *http.Client
 }
 
-// Get Download file from download info url
+// Get Download file from download info url. The whole content is read into
+// memory before Get returns, use Open to stream it instead.
 func (info *DownloadInfo) Get() (io.ReadSeeker, error) {
-	req := resty.New().R().SetHeaderMultiValues(info.Header)
-	resp, err := req.Get(info.Url.Url)
+	return info.GetCtx(context.Background())
+}
+
+// GetCtx is like Get but uses ctx for the requests.
+func (info *DownloadInfo) GetCtx(ctx context.Context) (io.ReadSeeker, error) {
+	r, err := info.OpenCtx(ctx)
 	if err != nil {
 		return nil, err
 	}
-
-	return bytes.NewReader(resp.Body()), nil
+	defer r.Close()
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(content), nil
 }
 
 type DownloadData map[string]*DownloadInfo
 
 // DownloadWithUA get download info with pickcode and user agent
 func (c *Pan115Client) DownloadWithUA(pickCode, ua string) (*DownloadInfo, error) {
+	return c.DownloadWithUACtx(context.Background(), pickCode, ua)
+}
+
+// DownloadWithUACtx is like DownloadWithUA but uses ctx for the request.
+func (c *Pan115Client) DownloadWithUACtx(ctx context.Context, pickCode, ua string) (*DownloadInfo, error) {
+	return c.download(ctx, DownloadStrategyWeb, pickCode, ua)
+}
+
+// downloadWeb resolves a download URL with the web API.
+func (c *Pan115Client) downloadWeb(ctx context.Context, pickCode, ua string) (*DownloadInfo, error) {
 	key := crypto.GenerateKey()
 
 	result := DownloadResp{}
@@ -48,8 +78,8 @@ func (c *Pan115Client) DownloadWithUA(pickCode, ua string) (*DownloadInfo, error
 		return nil, err
 	}
 
-	data := crypto.Encode(params, key)
-	req := c.NewRequest().
+	data := crypto.EncodeWith(c.m115PublicKey(), params, key)
+	req := c.newRequest(ctx).
 		SetQueryParam("t", Now().String()).
 		SetFormData(map[string]string{"data": data}).
 		ForceContentType("application/json").
@@ -57,12 +87,12 @@ func (c *Pan115Client) DownloadWithUA(pickCode, ua string) (*DownloadInfo, error
 	if len(ua) > 0 {
 		req = req.SetHeader("User-Agent", ua)
 	}
-	resp, err := req.Post(ApiDownloadGetUrl)
+	resp, err := c.post(req, c.endpoints().DownloadGetUrl)
 
 	if err := CheckErr(err, &result, resp); err != nil {
 		return nil, err
 	}
-	bytes, err := crypto.Decode(string(result.EncodedData), key)
+	bytes, err := crypto.DecodeWith(c.m115PublicKey(), string(result.EncodedData), key)
 	if err != nil {
 		return nil, err
 	}
@@ -84,6 +114,16 @@ func (c *Pan115Client) DownloadWithUA(pickCode, ua string) (*DownloadInfo, error
 
 // DownloadWithUAByAndroidAPI get download info with pickcode and user agent
 func (c *Pan115Client) DownloadWithUAByAndroidAPI(pickCode string, ua string) (*DownloadInfo, error) {
+	return c.DownloadWithUAByAndroidAPICtx(context.Background(), pickCode, ua)
+}
+
+// DownloadWithUAByAndroidAPICtx is like DownloadWithUAByAndroidAPI but uses ctx for the request.
+func (c *Pan115Client) DownloadWithUAByAndroidAPICtx(ctx context.Context, pickCode string, ua string) (*DownloadInfo, error) {
+	return c.download(ctx, DownloadStrategyAndroid, pickCode, ua)
+}
+
+// downloadAndroid resolves a download URL with the Android API.
+func (c *Pan115Client) downloadAndroid(ctx context.Context, pickCode string, ua string) (*DownloadInfo, error) {
 	key := crypto.GenerateKey()
 
 	result := DownloadResp{}
@@ -92,8 +132,8 @@ func (c *Pan115Client) DownloadWithUAByAndroidAPI(pickCode string, ua string) (*
 		return nil, err
 	}
 
-	data := crypto.Encode(params, key)
-	req := c.NewRequest().
+	data := crypto.EncodeWith(c.m115PublicKey(), params, key)
+	req := c.newRequest(ctx).
 		SetQueryParam("t", Now().String()).
 		SetFormData(map[string]string{"data": data}).
 		ForceContentType("application/json").
@@ -101,37 +141,166 @@ func (c *Pan115Client) DownloadWithUAByAndroidAPI(pickCode string, ua string) (*
 	if len(ua) > 0 {
 		req = req.SetHeader("User-Agent", ua)
 	}
-	resp, err := req.Post(AndroidApiDownloadGetUrl)
+	resp, err := c.post(req, c.endpoints().AndroidDownloadGetUrl)
 
 	if err := CheckErr(err, &result, resp); err != nil {
 		return nil, err
 	}
-	bytes, err := crypto.Decode(string(result.EncodedData), key)
+	bytes, err := crypto.DecodeWith(c.m115PublicKey(), string(result.EncodedData), key)
 	if err != nil {
 		return nil, err
 	}
 
 	infoResp := struct {
-		URL string `json:"url"`
+		URL      string      `json:"url"`
+		FileName string      `json:"file_name"`
+		FileSize StringInt64 `json:"file_size"`
 	}{}
 	if err := json.Unmarshal(bytes, &infoResp); err != nil {
 		return nil, err
 	}
 
 	info := DownloadInfo{
+		FileName: infoResp.FileName,
+		FileSize: infoResp.FileSize,
 		Url: FileDownloadUrl{
 			Url: infoResp.URL,
 		},
 		PickCode: pickCode,
 		Header:   buildDownloadHeaders(resp.Request.Header, resp.Cookies()),
 	}
-
+	if info.FileName == "" || info.FileSize == 0 {
+		if err := c.probeDownload(ctx, &info); err != nil {
+			c.logWarn(ctx, "can not get the name and size of a download", err, "pickcode", pickCode)
+		}
+	}
 	return &info, nil
 }
 
-// Download get download info with pickcode
+// probeDownload fills the name and size of info from the response to a
+// request of its first byte.
+func (c *Pan115Client) probeDownload(ctx context.Context, info *DownloadInfo) error {
+	r, err := info.OpenCtx(ctx, WithReaderHTTPClient(c.Client.GetClient()))
+	if err != nil {
+		return err
+	}
+	resp, err := r.request(ctx, 0, 0)
+	if errors.Is(err, io.EOF) {
+		// empty file
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if size := r.knownSize(); size >= 0 && info.FileSize == 0 {
+		info.FileSize = StringInt64(size)
+	}
+	if info.FileName == "" {
+		if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil {
+			info.FileName = params["filename"]
+		}
+	}
+	if info.FileName == "" {
+		if u, err := url.Parse(info.Url.Url); err == nil && path.Ext(u.Path) != "" {
+			info.FileName = path.Base(u.Path)
+		}
+	}
+	return nil
+}
+
+// DownloadStrategy is an API resolving download URLs, see WithDownloadStrategies.
+type DownloadStrategy string
+
+const (
+	// DownloadStrategyWeb uses the API of the web site, like DownloadWithUA.
+	DownloadStrategyWeb DownloadStrategy = "web"
+	// DownloadStrategyAndroid uses the API of the Android app, like DownloadWithUAByAndroidAPI.
+	DownloadStrategyAndroid DownloadStrategy = "android"
+)
+
+// defaultDownloadFailover are the errors of a strategy on which the next one is tried.
+var defaultDownloadFailover = []error{ErrDownloadFileTooBig, ErrDownloadEmpty}
+
+// ResolveDownload resolves a download URL of the file with pickCode and the
+// user agent ua, trying the strategies of the client in order until one
+// succeeds, see WithDownloadStrategies. The Strategy of the returned info
+// tells which one did.
+func (c *Pan115Client) ResolveDownload(pickCode, ua string) (*DownloadInfo, error) {
+	return c.ResolveDownloadCtx(context.Background(), pickCode, ua)
+}
+
+// ResolveDownloadCtx is like ResolveDownload but uses ctx for the requests.
+func (c *Pan115Client) ResolveDownloadCtx(ctx context.Context, pickCode, ua string) (*DownloadInfo, error) {
+	strategies := c.downloadStrategies
+	if len(strategies) == 0 {
+		strategies = []DownloadStrategy{DownloadStrategyWeb}
+	}
+	failover := c.downloadFailover
+	if failover == nil {
+		failover = defaultDownloadFailover
+	}
+	var err error
+	for i, strategy := range strategies {
+		var info *DownloadInfo
+		if info, err = c.download(ctx, strategy, pickCode, ua); err == nil {
+			return info, nil
+		}
+		if i == len(strategies)-1 || !slices.ContainsFunc(failover, func(target error) bool {
+			return errors.Is(err, target)
+		}) {
+			break
+		}
+		c.logWarn(ctx, "download strategy failed, trying the next one", err,
+			"strategy", strategy, "next", strategies[i+1], "pickcode", pickCode)
+	}
+	return nil, err
+}
+
+// download resolves a download URL with strategy, or returns it from the
+// download cache.
+func (c *Pan115Client) download(ctx context.Context, strategy DownloadStrategy, pickCode, ua string) (*DownloadInfo, error) {
+	key := downloadKey{strategy: strategy, pickCode: pickCode, ua: ua}
+	if c.downloadCache != nil {
+		// hashing the credential is only worth it to look up the cache
+		key.account = c.downloadAccount()
+	}
+	info, ok := c.downloadCache.get(key)
+	if !ok {
+		var resolve func(ctx context.Context, pickCode, ua string) (*DownloadInfo, error)
+		switch strategy {
+		case DownloadStrategyWeb:
+			resolve = c.downloadWeb
+		case DownloadStrategyAndroid:
+			resolve = c.downloadAndroid
+		default:
+			return nil, errors.Wrapf(ErrWrongParams, "unknown download strategy %q", strategy)
+		}
+		var err error
+		if info, err = resolve(ctx, pickCode, ua); err != nil {
+			return nil, err
+		}
+		c.downloadResolved(ctx, string(strategy), pickCode, info)
+		c.downloadCache.put(key, info)
+	}
+	info.Strategy = strategy
+	info.resolve = func(ctx context.Context) (*DownloadInfo, error) {
+		// the URL was refused, do not get it back from the cache
+		c.downloadCache.remove(key)
+		return c.download(ctx, strategy, pickCode, ua)
+	}
+	info.httpClient = c.Client.GetClient()
+	return info, nil
+}
+
+// Download get download info with pickcode, using the download strategies of the client
 func (c *Pan115Client) Download(pickCode string) (*DownloadInfo, error) {
-	return c.DownloadWithUA(pickCode, "")
+	return c.ResolveDownload(pickCode, "")
+}
+
+// DownloadCtx is like Download but uses ctx for the request.
+func (c *Pan115Client) DownloadCtx(ctx context.Context, pickCode string) (*DownloadInfo, error) {
+	return c.ResolveDownloadCtx(ctx, pickCode, "")
 }
 
 func buildDownloadHeaders(requestHeaders http.Header, responseCookies []*http.Cookie) http.Header {
@@ -175,7 +344,17 @@ func (c *Pan115Client) DownloadByShareCode(shareCode, receiveCode, fileID string
 	return c.DownloadByShareCodeWithUA("", shareCode, receiveCode, fileID)
 }
 
+// DownloadByShareCodeCtx is like DownloadByShareCode but uses ctx for the request.
+func (c *Pan115Client) DownloadByShareCodeCtx(ctx context.Context, shareCode, receiveCode, fileID string) (*SharedDownloadInfo, error) {
+	return c.DownloadByShareCodeWithUACtx(ctx, "", shareCode, receiveCode, fileID)
+}
+
 func (c *Pan115Client) DownloadByShareCodeWithUA(ua, shareCode, receiveCode, fileID string) (*SharedDownloadInfo, error) {
+	return c.DownloadByShareCodeWithUACtx(context.Background(), ua, shareCode, receiveCode, fileID)
+}
+
+// DownloadByShareCodeWithUACtx is like DownloadByShareCodeWithUA but uses ctx for the request.
+func (c *Pan115Client) DownloadByShareCodeWithUACtx(ctx context.Context, ua, shareCode, receiveCode, fileID string) (*SharedDownloadInfo, error) {
 	result := DownloadShareResp{}
 	params := map[string]string{
 		"share_code":   shareCode,
@@ -184,7 +363,7 @@ func (c *Pan115Client) DownloadByShareCodeWithUA(ua, shareCode, receiveCode, fil
 		"dl":           "1",
 	}
 
-	req := c.NewRequest().
+	req := c.newRequest(ctx).
 		SetQueryParams(params).
 		ForceContentType("application/json").
 		SetHeader("referer", BuildShareReferer(shareCode, receiveCode)).
@@ -193,7 +372,7 @@ func (c *Pan115Client) DownloadByShareCodeWithUA(ua, shareCode, receiveCode, fil
 	if len(ua) > 0 {
 		req = req.SetHeader("User-Agent", ua)
 	}
-	resp, err := req.Get(ApiDownloadGetShareUrl)
+	resp, err := c.get(req, c.endpoints().DownloadGetShareUrl)
 
 	if err := CheckErr(err, &result, resp); err != nil {
 		return nil, err
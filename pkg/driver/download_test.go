@@ -0,0 +1,54 @@
+package driver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownloadStrategies(t *testing.T) {
+	srv, c, f, _ := newReaderTestClient(t, 2000)
+	srv.SetWebDownloadLimit(1000)
+	small := srv.AddFile("0", "small.txt", []byte("small"))
+
+	_, err := c.Download(f.PickCode)
+	assert.ErrorIs(t, err, ErrDownloadFileTooBig)
+
+	WithDownloadStrategies(DownloadStrategyWeb, DownloadStrategyAndroid)(c)
+	info, err := c.Download(f.PickCode)
+	require.NoError(t, err)
+	assert.Equal(t, DownloadStrategyAndroid, info.Strategy)
+	assert.Equal(t, "video.mp4", info.FileName)
+	assert.EqualValues(t, 2000, info.FileSize)
+
+	info, err = c.Download(small.PickCode)
+	require.NoError(t, err)
+	assert.Equal(t, DownloadStrategyWeb, info.Strategy)
+
+	_, err = c.Download("missing")
+	assert.ErrorIs(t, err, ErrPickCodeNotExist, "no failover")
+
+	WithDownloadFailover(ErrPickCodeNotExist)(c)
+	_, err = c.Download(f.PickCode)
+	assert.ErrorIs(t, err, ErrDownloadFileTooBig, "not a failover error anymore")
+
+	WithDownloadStrategies("ftp")(c)
+	_, err = c.Download(f.PickCode)
+	assert.ErrorIs(t, err, ErrWrongParams)
+}
+
+func TestDownloadByAndroidAPIFillsInfo(t *testing.T) {
+	srv, c, f, _ := newReaderTestClient(t, 1234)
+	empty := srv.AddFile("0", "empty.txt", nil)
+
+	info, err := c.DownloadWithUAByAndroidAPI(f.PickCode, "")
+	require.NoError(t, err)
+	assert.Equal(t, "video.mp4", info.FileName)
+	assert.EqualValues(t, 1234, info.FileSize)
+	assert.Equal(t, DownloadStrategyAndroid, info.Strategy)
+
+	info, err = c.DownloadWithUAByAndroidAPI(empty.PickCode, "")
+	require.NoError(t, err)
+	assert.Zero(t, info.FileSize)
+}
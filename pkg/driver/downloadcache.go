@@ -0,0 +1,224 @@
+package driver
+
+import (
+	"container/list"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultDownloadCacheSize is the number of download URLs kept by a
+	// DownloadCache created with a size of 0.
+	DefaultDownloadCacheSize = 1024
+
+	// downloadExpiryMargin is how long before their expiry the cached URLs
+	// are dropped, so a returned URL stays valid for a while.
+	downloadExpiryMargin = time.Minute
+)
+
+// downloadKey identifies a cached download URL, the URLs being bound to the
+// account and the user agent which resolved them. The cached headers carry
+// the cookies of the account, so the URLs of an account are never handed to
+// another one.
+type downloadKey struct {
+	account  string
+	strategy DownloadStrategy
+	pickCode string
+	ua       string
+}
+
+type downloadEntry struct {
+	key     downloadKey
+	info    *DownloadInfo
+	expires time.Time
+}
+
+// DownloadCacheStats are the counters of a DownloadCache.
+type DownloadCacheStats struct {
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Evictions int64 `json:"evictions"`
+	Entries   int   `json:"entries"`
+}
+
+// HitRate returns the fraction of the lookups served from the cache.
+func (s DownloadCacheStats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// DownloadCache keeps the download URLs resolved by a client until they
+// expire, as found in their t query parameter, so streaming the same file
+// again does not resolve a new URL. URLs without an expiry are not cached.
+// It is safe for concurrent use and may be shared by several clients, each
+// account getting only the URLs it resolved.
+type DownloadCache struct {
+	mu    sync.Mutex
+	size  int
+	ll    *list.List
+	items map[downloadKey]*list.Element
+	stats DownloadCacheStats
+	now   func() time.Time
+}
+
+// NewDownloadCache creates a DownloadCache holding up to size URLs, the least
+// recently used ones being evicted first. Zero means DefaultDownloadCacheSize.
+func NewDownloadCache(size int) *DownloadCache {
+	if size <= 0 {
+		size = DefaultDownloadCacheSize
+	}
+	return &DownloadCache{
+		size:  size,
+		ll:    list.New(),
+		items: map[downloadKey]*list.Element{},
+		now:   time.Now,
+	}
+}
+
+// Invalidate removes the URLs of the file with pickCode, for every user agent.
+func (dc *DownloadCache) Invalidate(pickCode string) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	for key, el := range dc.items {
+		if key.pickCode == pickCode {
+			dc.removeElement(el)
+		}
+	}
+}
+
+// Purge removes all the URLs.
+func (dc *DownloadCache) Purge() {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	dc.ll.Init()
+	clear(dc.items)
+}
+
+// Stats returns the counters of the cache.
+func (dc *DownloadCache) Stats() DownloadCacheStats {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	stats := dc.stats
+	stats.Entries = dc.ll.Len()
+	return stats
+}
+
+// String implements expvar.Var, returning the stats as JSON.
+func (dc *DownloadCache) String() string {
+	b, err := json.Marshal(dc.Stats())
+	if err != nil {
+		return "{}"
+	}
+	return string(b)
+}
+
+// get returns a copy of the cached info of key. A nil *DownloadCache caches
+// nothing.
+func (dc *DownloadCache) get(key downloadKey) (*DownloadInfo, bool) {
+	if dc == nil {
+		return nil, false
+	}
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	el, ok := dc.items[key]
+	if ok && dc.now().After(el.Value.(*downloadEntry).expires) {
+		dc.removeElement(el)
+		ok = false
+	}
+	if !ok {
+		dc.stats.Misses++
+		return nil, false
+	}
+	dc.stats.Hits++
+	dc.ll.MoveToFront(el)
+	return copyDownloadInfo(el.Value.(*downloadEntry).info), true
+}
+
+func (dc *DownloadCache) put(key downloadKey, info *DownloadInfo) {
+	if dc == nil {
+		return
+	}
+	expires, ok := downloadURLExpiry(info.Url.Url)
+	if !ok {
+		return
+	}
+	expires = expires.Add(-downloadExpiryMargin)
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	if !dc.now().Before(expires) {
+		return
+	}
+	e := &downloadEntry{key: key, info: copyDownloadInfo(info), expires: expires}
+	if el, ok := dc.items[key]; ok {
+		el.Value = e
+		dc.ll.MoveToFront(el)
+		return
+	}
+	dc.items[key] = dc.ll.PushFront(e)
+	for dc.ll.Len() > dc.size {
+		dc.removeElement(dc.ll.Back())
+		dc.stats.Evictions++
+	}
+}
+
+func (dc *DownloadCache) remove(key downloadKey) {
+	if dc == nil {
+		return
+	}
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	if el, ok := dc.items[key]; ok {
+		dc.removeElement(el)
+	}
+}
+
+// removeElement must be called with dc.mu held.
+func (dc *DownloadCache) removeElement(el *list.Element) {
+	dc.ll.Remove(el)
+	delete(dc.items, el.Value.(*downloadEntry).key)
+}
+
+// downloadURLExpiry returns the expiry of a signed download URL, a Unix time
+// in its t query parameter.
+func downloadURLExpiry(rawURL string) (time.Time, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return time.Time{}, false
+	}
+	t, err := strconv.ParseInt(u.Query().Get("t"), 10, 64)
+	if err != nil || t <= 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(t, 0), true
+}
+
+// downloadAccount identifies the account of c in the download cache keys, a
+// hash of its user ID and cookies.
+func (c *Pan115Client) downloadAccount() string {
+	cookies := make([]string, 0, len(c.Client.Cookies))
+	for _, cookie := range c.Client.Cookies {
+		cookies = append(cookies, cookie.Name+"="+cookie.Value)
+	}
+	sort.Strings(cookies)
+	h := sha1.New()
+	h.Write([]byte(strconv.FormatInt(c.getUserID(), 10)))
+	for _, cookie := range cookies {
+		h.Write([]byte{0})
+		h.Write([]byte(cookie))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func copyDownloadInfo(info *DownloadInfo) *DownloadInfo {
+	cp := *info
+	cp.Header = info.Header.Clone()
+	return &cp
+}
@@ -0,0 +1,123 @@
+package driver
+
+import (
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/SheltonZhu/115driver/pkg/driver/fake115"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newDownloadCacheTestClient(t *testing.T, size int) (*fake115.Server, *Pan115Client, *DownloadCache, *countingInstrumentation) {
+	srv := fake115.NewServer()
+	t.Cleanup(srv.Close)
+	cache := NewDownloadCache(size)
+	inst := &countingInstrumentation{}
+	c := New(UA(), WithBaseHost(srv.URL), WithM115PublicKey(srv.PublicKey()),
+		WithDownloadCache(cache), WithInstrumentation(inst))
+	return srv, c, cache, inst
+}
+
+func TestDownloadCache(t *testing.T) {
+	srv, c, cache, inst := newDownloadCacheTestClient(t, 0)
+	f := srv.AddFile("0", "a.mp4", []byte("a"))
+
+	first, err := c.DownloadWithUA(f.PickCode, "vlc")
+	require.NoError(t, err)
+	second, err := c.DownloadWithUA(f.PickCode, "vlc")
+	require.NoError(t, err)
+	assert.Equal(t, first.Url.Url, second.Url.Url)
+	assert.Equal(t, 1, inst.count("DownloadGetUrl"))
+
+	second.Header.Set("X-Test", "1")
+	third, err := c.DownloadWithUA(f.PickCode, "vlc")
+	require.NoError(t, err)
+	assert.Empty(t, third.Header.Get("X-Test"), "a copy is returned")
+
+	_, err = c.DownloadWithUA(f.PickCode, "mpv")
+	require.NoError(t, err)
+	assert.Equal(t, 2, inst.count("DownloadGetUrl"), "bound to the user agent")
+	_, err = c.DownloadWithUAByAndroidAPI(f.PickCode, "vlc")
+	require.NoError(t, err)
+	_, err = c.DownloadWithUAByAndroidAPI(f.PickCode, "vlc")
+	require.NoError(t, err)
+	assert.Equal(t, 1, inst.count("AndroidDownloadGetUrl"))
+
+	stats := cache.Stats()
+	assert.Equal(t, DownloadCacheStats{Hits: 3, Misses: 3, Entries: 3}, stats)
+	assert.InDelta(t, 0.5, stats.HitRate(), 1e-9)
+	assert.JSONEq(t, `{"hits":3,"misses":3,"evictions":0,"entries":3}`, cache.String())
+
+	cache.Invalidate(f.PickCode)
+	assert.Zero(t, cache.Stats().Entries)
+}
+
+func TestDownloadCacheExpiryAndEviction(t *testing.T) {
+	srv, c, cache, inst := newDownloadCacheTestClient(t, 1)
+	a := srv.AddFile("0", "a.mp4", []byte("a"))
+	b := srv.AddFile("0", "b.mp4", []byte("b"))
+
+	_, err := c.Download(a.PickCode)
+	require.NoError(t, err)
+	cache.now = func() time.Time { return time.Now().Add(fake115.DefaultDownloadURLTTL) }
+	_, err = c.Download(a.PickCode)
+	require.NoError(t, err)
+	assert.Equal(t, 2, inst.count("DownloadGetUrl"), "expired")
+
+	cache.now = time.Now
+	srv.SetDownloadURLTTL(30 * time.Second)
+	_, err = c.Download(b.PickCode)
+	require.NoError(t, err)
+	assert.Zero(t, cache.Stats().Entries, "expires within the margin")
+
+	srv.SetDownloadURLTTL(time.Hour)
+	_, err = c.Download(b.PickCode)
+	require.NoError(t, err)
+	_, err = c.Download(a.PickCode)
+	require.NoError(t, err)
+	assert.Equal(t, DownloadCacheStats{Misses: 5, Evictions: 1, Entries: 1}, cache.Stats())
+
+	_, ok := downloadURLExpiry("https://cdn.115.com/file?u=1")
+	assert.False(t, ok)
+}
+
+func TestDownloadCacheRefusedURL(t *testing.T) {
+	srv, c, cache, inst := newDownloadCacheTestClient(t, 0)
+	f := srv.AddFile("0", "a.mp4", []byte("0123456789"))
+	_, err := c.Download(f.PickCode)
+	require.NoError(t, err)
+
+	srv.ExpireDownloadURLs()
+	r, err := c.OpenFile(f.PickCode)
+	require.NoError(t, err)
+	defer r.Close()
+	content, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789", string(content))
+	assert.Equal(t, 2, inst.count("DownloadGetUrl"), "resolved again")
+	assert.Equal(t, 1, cache.Stats().Entries)
+}
+
+func TestDownloadCacheSharedByAccounts(t *testing.T) {
+	srv, a, cache, inst := newDownloadCacheTestClient(t, 0)
+	a.SetCookies(&http.Cookie{Name: "UID", Value: "a"})
+	b := New(UA(), WithBaseHost(srv.URL), WithM115PublicKey(srv.PublicKey()),
+		WithDownloadCache(cache), WithInstrumentation(inst))
+	b.SetCookies(&http.Cookie{Name: "UID", Value: "b"})
+	f := srv.AddFile("0", "a.mp4", []byte("a"))
+
+	_, err := a.DownloadWithUA(f.PickCode, "vlc")
+	require.NoError(t, err)
+	info, err := b.DownloadWithUA(f.PickCode, "vlc")
+	require.NoError(t, err)
+	assert.Equal(t, 2, inst.count("DownloadGetUrl"), "the URL of an account is not handed to another one")
+	assert.Contains(t, info.Header.Get("Cookie"), "UID=b")
+	assert.NotContains(t, info.Header.Get("Cookie"), "UID=a")
+
+	_, err = b.DownloadWithUA(f.PickCode, "vlc")
+	require.NoError(t, err)
+	assert.Equal(t, 2, inst.count("DownloadGetUrl"))
+}
@@ -0,0 +1,505 @@
+package driver
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultDownloadConnections is the number of connections of a Downloader.
+	DefaultDownloadConnections = 4
+	// DefaultDownloadSegmentSize is the size of the ranges fetched by a Downloader.
+	DefaultDownloadSegmentSize = 16 << 20
+
+	// partSuffix is appended to the local path while a file is downloaded,
+	// stateSuffix to the path of the .part file for its sidecar state.
+	partSuffix  = ".part"
+	stateSuffix = ".state"
+
+	// maxSegmentAttempts bounds the attempts to fetch a segment, each resuming
+	// where the previous one stopped.
+	maxSegmentAttempts = 3
+
+	// stateSaveInterval is the period at which the written ranges are saved
+	// while the segments are fetched, bounding what a crash loses.
+	stateSaveInterval = time.Second
+	// fingerprintSize is the number of bytes at the start of each segment
+	// hashed into the fingerprint of a .part file.
+	fingerprintSize = 4 << 10
+)
+
+// DownloadProgress is reported by a Downloader while a file is downloaded.
+type DownloadProgress struct {
+	// FileName is the name of the downloaded file.
+	FileName string
+	// Total is the size of the file.
+	Total int64
+	// Done is the number of bytes on disk, including the resumed ones.
+	Done int64
+	// Segments is the number of ranges the file is split into.
+	Segments int
+	// SegmentsDone is the number of ranges fully on disk.
+	SegmentsDone int
+}
+
+type DownloaderOptions struct {
+	connections int
+	segmentSize int64
+	resume      bool
+	ua          string
+	progress    func(p DownloadProgress)
+	// saveInterval is the period at which the state file is saved.
+	saveInterval time.Duration
+}
+
+func DefaultDownloaderOptions() *DownloaderOptions {
+	return &DownloaderOptions{
+		connections:  DefaultDownloadConnections,
+		segmentSize:  DefaultDownloadSegmentSize,
+		saveInterval: stateSaveInterval,
+	}
+}
+
+type DownloaderOption func(o *DownloaderOptions)
+
+// WithDownloaderConnections fetches up to n segments of a file in parallel.
+func WithDownloaderConnections(n int) DownloaderOption {
+	return func(o *DownloaderOptions) {
+		o.connections = n
+	}
+}
+
+// WithDownloaderSegmentSize splits the files into ranges of size bytes.
+func WithDownloaderSegmentSize(size int64) DownloaderOption {
+	return func(o *DownloaderOptions) {
+		o.segmentSize = size
+	}
+}
+
+// WithDownloaderResume continues the download of a previous attempt from its
+// .part and state files, when they belong to the same file. Otherwise the
+// download starts over.
+func WithDownloaderResume() DownloaderOption {
+	return func(o *DownloaderOptions) {
+		o.resume = true
+	}
+}
+
+// WithDownloaderUA resolves the download URLs with the user agent ua, using
+// the download strategies of the client.
+func WithDownloaderUA(ua string) DownloaderOption {
+	return func(o *DownloaderOptions) {
+		o.ua = ua
+	}
+}
+
+// WithDownloaderProgress calls fn as the download progresses. The calls are
+// serialized.
+func WithDownloaderProgress(fn func(p DownloadProgress)) DownloaderOption {
+	return func(o *DownloaderOptions) {
+		o.progress = fn
+	}
+}
+
+// Downloader downloads files to the local disk over several connections, each
+// fetching a range of the file. The content is written to a .part file next
+// to the target, along with a sidecar state file recording the fetched ranges,
+// so an interrupted download can be resumed. Once complete, the size and SHA1
+// of the file are verified and the .part file is renamed to the target.
+type Downloader struct {
+	c *Pan115Client
+	o *DownloaderOptions
+}
+
+// NewDownloader creates a Downloader using c to resolve the download URLs.
+func NewDownloader(c *Pan115Client, opts ...DownloaderOption) *Downloader {
+	o := DefaultDownloaderOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.connections <= 0 {
+		o.connections = 1
+	}
+	if o.segmentSize <= 0 {
+		o.segmentSize = DefaultDownloadSegmentSize
+	}
+	if o.saveInterval <= 0 {
+		o.saveInterval = stateSaveInterval
+	}
+	return &Downloader{c: c, o: o}
+}
+
+// downloadState is the sidecar state of a .part file.
+type downloadState struct {
+	PickCode    string  `json:"pick_code"`
+	Size        int64   `json:"size"`
+	Sha1        string  `json:"sha1,omitempty"`
+	SegmentSize int64   `json:"segment_size"`
+	Written     []int64 `json:"written"`
+	// PartModTime is the modification time of the .part file when the state
+	// was saved, in nanoseconds since the epoch.
+	PartModTime int64 `json:"part_mod_time"`
+	// Fingerprint is the SHA1 of the start of the written ranges, see
+	// fingerprint.
+	Fingerprint string `json:"fingerprint"`
+}
+
+func (s *downloadState) matches(o *downloadState) bool {
+	return s.PickCode == o.PickCode && s.Size == o.Size && strings.EqualFold(s.Sha1, o.Sha1) &&
+		s.SegmentSize == o.SegmentSize && len(s.Written) == len(o.Written)
+}
+
+// segment returns the range of the segment i.
+func (s *downloadState) segment(i int) (start, end int64) {
+	start = int64(i) * s.SegmentSize
+	return start, min(start+s.SegmentSize, s.Size)
+}
+
+// Download downloads file to localPath. The file needs a PickCode, its Size
+// and Sha1 are verified when set.
+func (d *Downloader) Download(file *File, localPath string) error {
+	return d.DownloadCtx(context.Background(), file, localPath)
+}
+
+// DownloadCtx is like Download but uses ctx for the requests.
+func (d *Downloader) DownloadCtx(ctx context.Context, file *File, localPath string) error {
+	if file.IsDirectory {
+		return ErrDownloadDirectory
+	}
+	info, err := d.c.ResolveDownloadCtx(ctx, file.PickCode, d.o.ua)
+	if err != nil {
+		return err
+	}
+	r, err := info.OpenCtx(ctx)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	size := file.Size
+	if size <= 0 {
+		if size, err = r.Size(); err != nil {
+			return err
+		}
+	}
+	state := &downloadState{
+		PickCode:    file.PickCode,
+		Size:        size,
+		Sha1:        file.Sha1,
+		SegmentSize: d.o.segmentSize,
+		Written:     make([]int64, (size+d.o.segmentSize-1)/d.o.segmentSize),
+	}
+
+	partPath := localPath + partSuffix
+	statePath := partPath + stateSuffix
+	flag := os.O_RDWR | os.O_CREATE
+	if !d.o.resume || !loadDownloadState(statePath, partPath, state) {
+		// a stale state would describe the content of the truncated .part file
+		if err := os.Remove(statePath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		flag |= os.O_TRUNC
+	}
+	part, err := os.OpenFile(partPath, flag, 0o644)
+	if err != nil {
+		return err
+	}
+	defer part.Close()
+	if err := part.Truncate(size); err != nil {
+		return err
+	}
+
+	name := file.Name
+	if name == "" {
+		name = info.FileName
+	}
+	s := &segmentedDownload{
+		d:         d,
+		r:         r,
+		part:      part,
+		statePath: statePath,
+		state:     state,
+		progress:  DownloadProgress{FileName: name, Total: size, Segments: len(state.Written)},
+	}
+	if err := s.run(ctx); err != nil {
+		return err
+	}
+
+	if err := verifyDownload(part, state); err != nil {
+		_ = part.Close()
+		_ = os.Remove(partPath)
+		_ = os.Remove(statePath)
+		return err
+	}
+	if err := part.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(partPath, localPath); err != nil {
+		return err
+	}
+	if err := os.Remove(statePath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// fingerprint hashes the first bytes of the written ranges of part, which are
+// not written again once saved.
+func (s *downloadState) fingerprint(part io.ReaderAt) (string, error) {
+	h := sha1.New()
+	for i, written := range s.Written {
+		start, _ := s.segment(i)
+		if _, err := io.Copy(h, io.NewSectionReader(part, start, min(written, fingerprintSize))); err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// loadDownloadState replaces the written ranges of state with the ones saved
+// at path, if they belong to the same file and the .part file at partPath is
+// still the one they describe. The .part file may have been written after the
+// save by a download which did not stop cleanly, so its modification time may
+// be later than the saved one, but its size and fingerprint must match.
+func loadDownloadState(path, partPath string, state *downloadState) bool {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	saved := &downloadState{}
+	if json.Unmarshal(data, saved) != nil || !saved.matches(state) {
+		return false
+	}
+	part, err := os.Open(partPath)
+	if err != nil {
+		return false
+	}
+	defer part.Close()
+	fi, err := part.Stat()
+	if err != nil || fi.Size() != saved.Size || fi.ModTime().UnixNano() < saved.PartModTime {
+		// deleted or replaced since
+		return false
+	}
+	if sum, err := saved.fingerprint(part); err != nil || sum != saved.Fingerprint {
+		return false
+	}
+	copy(state.Written, saved.Written)
+	return true
+}
+
+// verifyDownload checks the size and the SHA1 of the downloaded content.
+func verifyDownload(part *os.File, state *downloadState) error {
+	fi, err := part.Stat()
+	if err != nil {
+		return err
+	}
+	if fi.Size() != state.Size {
+		return errors.Wrapf(ErrDownloadMismatch, "size %d, expected %d", fi.Size(), state.Size)
+	}
+	if state.Sha1 == "" {
+		return nil
+	}
+	h := sha1.New()
+	if _, err := io.Copy(h, io.NewSectionReader(part, 0, state.Size)); err != nil {
+		return err
+	}
+	if sum := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(sum, state.Sha1) {
+		return errors.Wrapf(ErrDownloadMismatch, "SHA1 %s, expected %s", strings.ToUpper(sum), strings.ToUpper(state.Sha1))
+	}
+	return nil
+}
+
+// segmentedDownload fetches the missing ranges of a .part file.
+type segmentedDownload struct {
+	d         *Downloader
+	r         *DownloadReader
+	part      *os.File
+	statePath string
+
+	// mu guards state, progress and the writes of the state file.
+	mu       sync.Mutex
+	state    *downloadState
+	progress DownloadProgress
+}
+
+func (s *segmentedDownload) run(ctx context.Context) error {
+	var todo []int
+	for i := range s.state.Written {
+		start, end := s.state.segment(i)
+		s.progress.Done += s.state.Written[i]
+		if start+s.state.Written[i] < end {
+			todo = append(todo, i)
+		} else {
+			s.progress.SegmentsDone++
+		}
+	}
+	s.report()
+	if len(todo) == 0 {
+		return nil
+	}
+
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	segments := make(chan int)
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	fail := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+	for n := min(s.d.o.connections, len(todo)); n > 0; n-- {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range segments {
+				if err := s.fetch(ctx, i); err != nil {
+					fail(err)
+					return
+				}
+			}
+		}()
+	}
+	// save the ranges written so far now and then, in case the process dies
+	saved := make(chan struct{})
+	go func() {
+		defer close(saved)
+		ticker := time.NewTicker(s.d.o.saveInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := s.saveState(); err != nil {
+					fail(err)
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+feed:
+	for _, i := range todo {
+		select {
+		case segments <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(segments)
+	wg.Wait()
+	cancel()
+	<-saved
+
+	if err := s.saveState(); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	if firstErr == nil {
+		firstErr = parent.Err()
+	}
+	return firstErr
+}
+
+// fetch downloads the rest of the segment i, retrying transient failures.
+func (s *segmentedDownload) fetch(ctx context.Context, i int) error {
+	start, end := s.state.segment(i)
+	var err error
+	for attempt := 1; attempt <= maxSegmentAttempts; attempt++ {
+		if err = s.fetchOnce(ctx, start, end, i); err == nil {
+			s.mu.Lock()
+			s.progress.SegmentsDone++
+			s.mu.Unlock()
+			s.report()
+			return s.saveState()
+		}
+		if ctx.Err() != nil || !IsRetryable(err) {
+			return err
+		}
+	}
+	return err
+}
+
+func (s *segmentedDownload) fetchOnce(ctx context.Context, start, end int64, i int) error {
+	s.mu.Lock()
+	off := start + s.state.Written[i]
+	s.mu.Unlock()
+	if off >= end {
+		return nil
+	}
+	resp, err := s.r.request(ctx, off, end-1)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	buf := make([]byte, 256<<10)
+	for off < end {
+		n, err := resp.Body.Read(buf[:min(int64(len(buf)), end-off)])
+		if n > 0 {
+			if _, err := s.part.WriteAt(buf[:n], off); err != nil {
+				return err
+			}
+			off += int64(n)
+			s.mu.Lock()
+			s.state.Written[i] += int64(n)
+			s.progress.Done += int64(n)
+			s.mu.Unlock()
+			s.report()
+		}
+		if err == io.EOF && off < end {
+			return io.ErrUnexpectedEOF
+		} else if err != nil && err != io.EOF {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *segmentedDownload) report() {
+	if s.d.o.progress == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.d.o.progress(s.progress)
+}
+
+// saveState syncs the .part file and records the written ranges. The bytes
+// counted in the state are on disk before it is saved, as they are counted
+// once written.
+func (s *segmentedDownload) saveState() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.part.Sync(); err != nil {
+		return err
+	}
+	fi, err := s.part.Stat()
+	if err != nil {
+		return err
+	}
+	s.state.PartModTime = fi.ModTime().UnixNano()
+	if s.state.Fingerprint, err = s.state.fingerprint(s.part); err != nil {
+		return err
+	}
+	data, err := json.Marshal(s.state)
+	if err != nil {
+		return err
+	}
+	tmp := s.statePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.statePath)
+}
@@ -0,0 +1,236 @@
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownloader(t *testing.T) {
+	srv, c, f, content := newReaderTestClient(t, 100_000)
+	file, err := c.GetFile(f.ID)
+	require.NoError(t, err)
+	local := filepath.Join(t.TempDir(), "video.mp4")
+
+	var last DownloadProgress
+	d := NewDownloader(c, WithDownloaderConnections(3), WithDownloaderSegmentSize(8000), WithDownloaderProgress(func(p DownloadProgress) {
+		assert.GreaterOrEqual(t, p.Done, last.Done)
+		last = p
+	}))
+	require.NoError(t, d.Download(file, local))
+
+	got, err := os.ReadFile(local)
+	require.NoError(t, err)
+	assert.Equal(t, content, got)
+	assert.Equal(t, DownloadProgress{FileName: "video.mp4", Total: 100_000, Done: 100_000, Segments: 13, SegmentsDone: 13}, last)
+	assert.NoFileExists(t, local+partSuffix)
+	assert.NoFileExists(t, local+partSuffix+stateSuffix)
+
+	last = DownloadProgress{}
+	empty := srv.AddFile("0", "empty", nil)
+	require.NoError(t, d.Download(&File{PickCode: empty.PickCode, Sha1: empty.SHA1}, local))
+	got, err = os.ReadFile(local)
+	require.NoError(t, err)
+	assert.Empty(t, got)
+}
+
+func TestDownloaderResumes(t *testing.T) {
+	_, c, f, content := newReaderTestClient(t, 100_000)
+	tr := &countingTransport{}
+	c.Client.SetTransport(tr)
+	file, err := c.GetFile(f.ID)
+	require.NoError(t, err)
+	local := filepath.Join(t.TempDir(), "video.mp4")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	d := NewDownloader(c, WithDownloaderConnections(1), WithDownloaderSegmentSize(10_000), WithDownloaderProgress(func(p DownloadProgress) {
+		if p.SegmentsDone == 4 {
+			cancel()
+		}
+	}))
+	require.ErrorIs(t, d.DownloadCtx(ctx, file, local), context.Canceled)
+	assert.FileExists(t, local+partSuffix+stateSuffix)
+
+	var fetched atomic.Int64
+	d = NewDownloader(c, WithDownloaderResume(), WithDownloaderSegmentSize(10_000), WithDownloaderProgress(func(p DownloadProgress) {
+		if fetched.Load() == 0 {
+			fetched.Store(p.Done)
+		}
+	}))
+	tr.ranges = nil
+	require.NoError(t, d.Download(file, local))
+	assert.GreaterOrEqual(t, fetched.Load(), int64(40_000), "resumed from the state file")
+	for _, rng := range tr.ranges {
+		assert.NotEqual(t, "bytes=0-9999", rng, "fetched again")
+	}
+
+	got, err := os.ReadFile(local)
+	require.NoError(t, err)
+	assert.Equal(t, content, got)
+}
+
+func TestDownloaderVerifies(t *testing.T) {
+	_, c, f, _ := newReaderTestClient(t, 1000)
+	local := filepath.Join(t.TempDir(), "video.mp4")
+
+	err := NewDownloader(c).Download(&File{PickCode: f.PickCode, Size: 1000, Sha1: "0000"}, local)
+	assert.ErrorIs(t, err, ErrDownloadMismatch)
+	assert.NoFileExists(t, local)
+
+	assert.NoFileExists(t, local+partSuffix)
+
+	err = NewDownloader(c).Download(&File{PickCode: "missing"}, local)
+	assert.ErrorIs(t, err, ErrPickCodeNotExist)
+	assert.ErrorIs(t, NewDownloader(c).Download(&File{IsDirectory: true}, local), ErrDownloadDirectory)
+}
+
+// stallTransport fails the requests of the range fail, and stalls the ones
+// of the range stall until their context is done.
+type stallTransport struct {
+	fail, stall string
+}
+
+func (t *stallTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	switch req.Header.Get("Range") {
+	case t.fail:
+		return &http.Response{StatusCode: http.StatusNotFound, Status: "404 Not Found", Body: io.NopCloser(strings.NewReader("")), Request: req}, nil
+	case t.stall:
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestDownloaderCancelsSegments(t *testing.T) {
+	_, c, f, _ := newReaderTestClient(t, 100_000)
+	c.Client.SetTransport(&stallTransport{fail: "bytes=10000-19999", stall: "bytes=0-9999"})
+	file, err := c.GetFile(f.ID)
+	require.NoError(t, err)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- NewDownloader(c, WithDownloaderConnections(2), WithDownloaderSegmentSize(10_000)).Download(file, filepath.Join(t.TempDir(), "video.mp4"))
+	}()
+	select {
+	case err := <-done:
+		require.Error(t, err)
+		assert.NotErrorIs(t, err, context.Canceled, "the failure of the segment is returned")
+	case <-time.After(5 * time.Second):
+		t.Fatal("the failure of a segment does not cancel the others")
+	}
+}
+
+func TestDownloaderDiscardsStaleState(t *testing.T) {
+	_, c, f, content := newReaderTestClient(t, 100_000)
+	local := filepath.Join(t.TempDir(), "video.mp4")
+	// without a SHA1, a zero-filled segment would go unnoticed
+	file := &File{PickCode: f.PickCode, Size: int64(len(content))}
+
+	interrupt := func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		d := NewDownloader(c, WithDownloaderConnections(1), WithDownloaderSegmentSize(10_000), WithDownloaderProgress(func(p DownloadProgress) {
+			if p.SegmentsDone == 4 {
+				cancel()
+			}
+		}))
+		require.ErrorIs(t, d.DownloadCtx(ctx, file, local), context.Canceled)
+		require.FileExists(t, local+partSuffix+stateSuffix)
+	}
+
+	for name, stale := range map[string]func(part string){
+		"deleted":  func(part string) { require.NoError(t, os.Remove(part)) },
+		"replaced": func(part string) { require.NoError(t, os.WriteFile(part, make([]byte, len(content)), 0o644)) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			interrupt()
+			stale(local + partSuffix)
+
+			require.NoError(t, NewDownloader(c, WithDownloaderResume(), WithDownloaderSegmentSize(10_000)).Download(file, local))
+			got, err := os.ReadFile(local)
+			require.NoError(t, err)
+			assert.Equal(t, content, got)
+		})
+	}
+}
+
+// stallBodyTransport stalls the response bodies after their first after
+// bytes, until the context of the request is done.
+type stallBodyTransport struct {
+	after int
+}
+
+func (t *stallBodyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusPartialContent {
+		return resp, err
+	}
+	resp.Body = &stallBody{ReadCloser: resp.Body, left: t.after, ctx: req.Context()}
+	return resp, nil
+}
+
+type stallBody struct {
+	io.ReadCloser
+	left int
+	ctx  context.Context
+}
+
+func (b *stallBody) Read(p []byte) (int, error) {
+	if b.left == 0 {
+		<-b.ctx.Done()
+		return 0, b.ctx.Err()
+	}
+	n, err := b.ReadCloser.Read(p[:min(len(p), b.left)])
+	b.left -= n
+	return n, err
+}
+
+func TestDownloaderResumesAfterCrash(t *testing.T) {
+	_, c, f, content := newReaderTestClient(t, 100_000)
+	c.Client.SetTransport(&stallBodyTransport{after: 40_000})
+	file, err := c.GetFile(f.ID)
+	require.NoError(t, err)
+	local := filepath.Join(t.TempDir(), "video.mp4")
+	part, statePath := local+partSuffix, local+partSuffix+stateSuffix
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		// a single segment, only saved by the periodic saves until cancelled
+		d := NewDownloader(c, WithDownloaderConnections(1), func(o *DownloaderOptions) { o.saveInterval = 10 * time.Millisecond })
+		done <- d.DownloadCtx(ctx, file, local)
+	}()
+	var state, data []byte
+	require.Eventually(t, func() bool {
+		saved := &downloadState{}
+		state, _ = os.ReadFile(statePath)
+		return json.Unmarshal(state, saved) == nil && len(saved.Written) == 1 && saved.Written[0] == 40_000
+	}, 5*time.Second, 10*time.Millisecond)
+	// the files as a crash would leave them, the .part written after the state
+	data, err = os.ReadFile(part)
+	require.NoError(t, err)
+	cancel()
+	require.ErrorIs(t, <-done, context.Canceled)
+	require.NoError(t, os.WriteFile(part, data, 0o644))
+	require.NoError(t, os.WriteFile(statePath, state, 0o644))
+
+	tr := &countingTransport{}
+	c.Client.SetTransport(tr)
+	require.NoError(t, NewDownloader(c, WithDownloaderResume()).Download(file, local))
+	assert.Contains(t, tr.ranges, "bytes=40000-99999", "resumed from the saved state")
+	assert.NotContains(t, tr.ranges, "bytes=0-99999")
+	got, err := os.ReadFile(local)
+	require.NoError(t, err)
+	assert.Equal(t, content, got)
+}
@@ -0,0 +1,50 @@
+package driver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEndpointsWithBaseHost(t *testing.T) {
+	e := DefaultEndpoints().WithBaseHost("http://127.0.0.1:8080/")
+
+	assert.Equal(t, "http://127.0.0.1:8080/files", e.FileList)
+	assert.Equal(t, "http://127.0.0.1:8080/files", e.FileList1)
+	assert.Equal(t, "http://127.0.0.1:8080/app/chrome/downurl", e.DownloadGetUrl)
+	assert.Equal(t, "http://127.0.0.1:8080/app/1.0/%s/1.0/login/qrcode", e.QrcodeLoginWithApp)
+	assert.Equal(t, "http://127.0.0.1:8080/lixian/?ct=lixian&ac=task_lists", e.ListOfflineUrl)
+	assert.Equal(t, OSSEndpoint, e.OSS)
+	// the defaults are not modified
+	assert.Equal(t, ApiFileList, DefaultEndpoints().FileList)
+}
+
+func TestEndpointsWithHost(t *testing.T) {
+	e := DefaultEndpoints().
+		WithHost("lixian.115.com", "http://127.0.0.1:8080").
+		WithHost(OSSEndpoint, "http://127.0.0.1:9090")
+
+	assert.Equal(t, "http://127.0.0.1:8080/lixianssp/?ac=add_task_urls", e.AddOfflineUrl)
+	assert.Equal(t, ApiFileList, e.FileList)
+	assert.Equal(t, ApiDownloadGetUrl, e.DownloadGetUrl)
+	assert.Equal(t, "http://127.0.0.1:9090", e.OSS)
+}
+
+func TestClientUsesEndpoints(t *testing.T) {
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		writeJSON(w, map[string]any{"state": true, "cid": "1", "count": 0})
+	}))
+	defer srv.Close()
+
+	c := New(UA(), WithBaseHost(srv.URL))
+	require.NoError(t, c.Delete("1"))
+	_, err := c.List("0", WithMultiUrls())
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"/rb/delete", "/files"}, paths)
+}
@@ -40,6 +40,9 @@ var (
 
 	ErrInvalidCursor = errors.New("invalid cursor")
 
+	// ErrDirChanged means the entries of a directory changed while it was listed.
+	ErrDirChanged = errors.New("directory changed while listing")
+
 	ErrUploadTooLarge = errors.New("upload reach the limit")
 
 	ErrUploadFailed = errors.New("upload failed")
@@ -48,6 +51,12 @@ var (
 
 	ErrDownloadEmpty = errors.New("can not get download URL")
 
+	// ErrDownloadURLExpired means a download URL was refused, usually because its signature expired.
+	ErrDownloadURLExpired = errors.New("download URL expired")
+
+	// ErrDownloadMismatch means a downloaded file does not have the expected size or SHA1.
+	ErrDownloadMismatch = errors.New("downloaded file does not match")
+
 	ErrDownloadDirectory = errors.New("can not download directory")
 
 	ErrDownloadFileNotExistOrHasDeleted = errors.New("target file does not exist or has deleted")
@@ -80,6 +89,9 @@ var (
 
 	ErrUploadSigInvalid = errors.New("sig invalid")
 
+	// ErrTooManyRequests means the account is throttled for sending requests too frequently.
+	ErrTooManyRequests = errors.New("too many requests")
+
 	errMap = map[int]error{
 		// Normal errors
 		99:     ErrNotLogin,
@@ -128,21 +140,93 @@ var (
 	}
 )
 
+// APIError is returned when the 115 API reports a failure. It unwraps to the
+// sentinel error mapped from Code, or ErrUnexpected for unknown codes, so
+// errors.Is(err, ErrExist) keeps working while errors.As gives access to the
+// details of the response.
+//
+// The failures read from a response are returned as *APIError, so comparing
+// them with ==, as in err == ErrExist, does not match: use errors.Is.
+type APIError struct {
+	// Code is the error code reported by the API, 0 if there is none.
+	Code int
+	// Message is the error message reported by the API.
+	Message string
+	// Endpoint is the URL of the failed request.
+	Endpoint string
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+	// Body is the raw response body.
+	Body string
+
+	err error
+}
+
+func (e *APIError) Error() string {
+	detail := e.Message
+	if e.Body != "" {
+		detail = readableBody(e.Body)
+	}
+	if detail == "" {
+		return e.err.Error()
+	}
+	return detail + ": " + e.err.Error()
+}
+
+// Unwrap returns the sentinel error of the code.
+func (e *APIError) Unwrap() error {
+	return e.err
+}
+
+// GetErr returns the sentinel error of code, or an *APIError wrapping it when
+// the raw response body is given.
 func GetErr(code int, respBody ...string) error {
-	errWithMsg := ErrUnexpected
+	if len(respBody) == 0 {
+		return codeErr(code)
+	}
+	return newAPIError(code, "", respBody...)
+}
+
+// responseErr is like newAPIError, but returns the bare sentinel error of
+// code when there is neither a message nor a response body to add to it.
+func responseErr(code int, message string, respBody ...string) error {
+	if message == "" && len(respBody) == 0 {
+		return codeErr(code)
+	}
+	return newAPIError(code, message, respBody...)
+}
+
+// codeErr returns the sentinel error of code, ErrUnexpected for unknown codes.
+func codeErr(code int) error {
 	if err, found := errMap[code]; found {
-		errWithMsg = err
+		return err
+	}
+	return ErrUnexpected
+}
+
+func newAPIError(code int, message string, respBody ...string) *APIError {
+	e := &APIError{
+		Code:    code,
+		Message: message,
+		err:     codeErr(code),
 	}
-	// if len(respBody) > 0 && errors.Is(ErrUnexpected, errWithMsg) {
 	if len(respBody) > 0 {
-		bodyRaw := respBody[0]
-		readableBody, err := strconv.Unquote(strings.Replace(strconv.Quote(bodyRaw), `\\u`, `\u`, -1))
-		if err != nil {
-			return errors.Wrap(errWithMsg, bodyRaw)
-		}
-		return errors.Wrap(errWithMsg, readableBody)
+		e.Body = respBody[0]
+	}
+	// throttling responses carry no dedicated code, only "请求过于频繁" or alike
+	if e.err == ErrUnexpected && (strings.Contains(message, "频繁") || strings.Contains(readableBody(e.Body), "频繁")) {
+		e.err = ErrTooManyRequests
 	}
-	return errWithMsg
+	return e
+}
+
+// readableBody unescapes the \uXXXX sequences of a JSON body.
+func readableBody(bodyRaw string) string {
+	readable, err := strconv.Unquote(strings.Replace(strconv.Quote(bodyRaw), `\\u`, `\u`, -1))
+	if err != nil {
+		return bodyRaw
+	}
+	return readable
 }
 
 type ResultWithErr interface {
@@ -152,6 +236,13 @@ type ResultWithErr interface {
 func CheckErr(err error, result ResultWithErr, restyResp *resty.Response) error {
 	if err == nil {
 		err = result.Err(restyResp.String())
+		var apiErr *APIError
+		if errors.As(err, &apiErr) {
+			apiErr.Endpoint = restyResp.Request.URL
+			if apiErr.StatusCode == 0 {
+				apiErr.StatusCode = restyResp.StatusCode()
+			}
+		}
 	}
 	if err != nil {
 		return err
@@ -0,0 +1,73 @@
+package driver
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/SheltonZhu/115driver/pkg/driver/fake115"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIErrorFromResponse(t *testing.T) {
+	srv := fake115.NewServer()
+	defer srv.Close()
+	c := New(UA(), WithBaseHost(srv.URL))
+
+	_, err := c.Mkdir("0", "dup")
+	require.NoError(t, err)
+	_, err = c.Mkdir("0", "dup")
+	require.ErrorIs(t, err, ErrExist)
+
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, 20004, apiErr.Code)
+	assert.Equal(t, "该目录名称已存在。", apiErr.Message)
+	assert.Equal(t, srv.URL+"/files/add", apiErr.Endpoint)
+	assert.Equal(t, 200, apiErr.StatusCode)
+	assert.Contains(t, apiErr.Body, `"errno":20004`)
+}
+
+func TestAPIErrorUnmappedCode(t *testing.T) {
+	err := (&BasicResp{ErrNo: 123456, Error: "boom"}).Err(`{"errNo":123456}`)
+
+	assert.ErrorIs(t, err, ErrUnexpected)
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, 123456, apiErr.Code)
+	assert.Equal(t, "boom", apiErr.Message)
+	assert.Equal(t, `{"errNo":123456}: unexpected error`, err.Error())
+}
+
+func TestAPIErrorFromUploadResponses(t *testing.T) {
+	var apiErr *APIError
+
+	err := (&UploadInitResp{ErrorCode: 402, ErrorMsg: "sha1 invalid"}).Err()
+	assert.ErrorIs(t, err, ErrUploadSH1Invalid)
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, "sha1 invalid: "+ErrUploadSH1Invalid.Error(), err.Error())
+
+	for _, status := range []string{"400", "402", "403"} {
+		err = (&UploadOSSTokenResp{StatusCode: status}).Err()
+		assert.ErrorIs(t, err, ErrUnexpected, "not mapped as a 115 error code")
+		require.True(t, errors.As(err, &apiErr))
+		assert.Zero(t, apiErr.Code)
+		assert.Equal(t, status, strconv.Itoa(apiErr.StatusCode))
+	}
+
+	err = (&QRCodeBasicResp{Code: 40199002, Message: "expired"}).Err()
+	assert.ErrorIs(t, err, ErrQrcodeExpired)
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, "expired", apiErr.Message)
+}
+
+func TestBareSentinelErrors(t *testing.T) {
+	assert.Equal(t, ErrExist, GetErr(20004))
+	assert.Equal(t, ErrUnexpected, GetErr(123456))
+	assert.Equal(t, ErrExist, (&BasicResp{Errno: 20004}).Err(), "nothing to add to the sentinel")
+
+	var apiErr *APIError
+	assert.True(t, errors.As(GetErr(20004, `{"errno":20004}`), &apiErr))
+	assert.ErrorIs(t, apiErr, ErrExist)
+}
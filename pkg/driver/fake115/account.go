@@ -0,0 +1,73 @@
+package fake115
+
+import (
+	"net/http"
+	"strconv"
+)
+
+func (s *Server) handleLoginCheck(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]any{
+		"state": 0,
+		"code":  0,
+		"data":  map[string]any{"user_id": s.UserID(), "expire": 0, "link": ""},
+	})
+}
+
+// handleMy serves the APIs of my.115.com, selected by the ac query param.
+func (s *Server) handleMy(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch r.URL.Query().Get("ac") {
+	case "nav":
+		writeJSON(w, map[string]any{
+			"state": true,
+			"data": map[string]any{
+				"user_id":   s.userID,
+				"user_name": s.userName,
+				"face":      "",
+				"vip":       0,
+			},
+		})
+	case "status":
+		writeJSON(w, map[string]any{"state": true})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) handleAppVersion(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]any{
+		"state": true,
+		"data": map[string]any{
+			"win":     map[string]any{"version_code": "35.0.0"},
+			"android": map[string]any{"version_code": "35.0.0"},
+		},
+	})
+}
+
+// totalSpace is the capacity reported by the index info API.
+const totalSpace = 1 << 40
+
+func (s *Server) handleIndexInfo(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	used := 0
+	for _, n := range s.nodes {
+		used += len(n.content)
+	}
+	s.mu.Unlock()
+	size := func(n int) map[string]any {
+		return map[string]any{"size": n, "size_format": strconv.Itoa(n) + "B"}
+	}
+	writeJSON(w, map[string]any{
+		"state": true,
+		"data": map[string]any{
+			"space_info": map[string]any{
+				"all_total":  size(totalSpace),
+				"all_remain": size(totalSpace - used),
+				"all_use":    size(used),
+			},
+			"login_devices_info": map[string]any{"last": map[string]any{}, "list": []any{}},
+			"imei_info":          false,
+		},
+	})
+}
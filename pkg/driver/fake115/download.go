@@ -0,0 +1,160 @@
+package fake115
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/SheltonZhu/115driver/pkg/crypto/m115"
+)
+
+// downloadPath is where the download URLs handed out by the server point to.
+const downloadPath = "/fake115/download/"
+
+// errno of the download API
+const (
+	errnoPickCodeNotExist = 50003
+	errnoFileTooBig       = 50028
+)
+
+// decodeRequest decodes the m115 encoded data form field into v.
+func (s *Server) decodeRequest(r *http.Request, v any) (m115.Key, bool) {
+	data, key, err := m115.ServerDecode(s.Key, r.PostFormValue("data"))
+	if err != nil {
+		return key, false
+	}
+	return key, json.Unmarshal(data, v) == nil
+}
+
+// writeEncoded writes v as the m115 encoded data of a successful response.
+func (s *Server) writeEncoded(w http.ResponseWriter, key m115.Key, v any) {
+	data, _ := json.Marshal(v)
+	writeJSON(w, map[string]any{"state": true, "msg": "", "data": m115.ServerEncode(s.Key, data, key)})
+}
+
+// byPickCode returns the live file with pickCode.
+func (s *Server) byPickCode(pickCode string) *node {
+	for _, n := range s.nodes {
+		if n.pickCode == pickCode && !n.deleted && !n.isDir {
+			return n
+		}
+	}
+	return nil
+}
+
+// downloadURL returns a URL of the content of n, which expires like the
+// signed URLs of the real service, at the Unix time of its t parameter.
+func (s *Server) downloadURL(n *node) string {
+	expires := time.Now().Add(s.urlTTL).Unix()
+	return s.URL + downloadPath + n.pickCode + "?gen=" + strconv.Itoa(s.urlGen) + "&t=" + strconv.FormatInt(expires, 10)
+}
+
+// SetWebDownloadLimit makes the web download API refuse the files larger
+// than size bytes, like the real service does for large files. Zero removes
+// the limit.
+func (s *Server) SetWebDownloadLimit(size int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.webLimit = size
+}
+
+// SetDownloadURLTTL sets how long the download URLs handed out are valid.
+func (s *Server) SetDownloadURLTTL(ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.urlTTL = ttl
+}
+
+// ExpireDownloadURLs makes the download URLs handed out so far fail with
+// 403 Forbidden, like the signed URLs of the real service do once expired.
+func (s *Server) ExpireDownloadURLs() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.urlGen++
+}
+
+func (s *Server) handleDownURL(w http.ResponseWriter, r *http.Request) {
+	params := struct {
+		PickCode string `json:"pickcode"`
+	}{}
+	key, ok := s.decodeRequest(r, &params)
+	if !ok {
+		writeError(w, errnoWrongParams, "参数错误")
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := s.byPickCode(params.PickCode)
+	if n == nil {
+		writeError(w, errnoPickCodeNotExist, "pickcode does not exist")
+		return
+	}
+	if s.webLimit > 0 && int64(len(n.content)) > s.webLimit {
+		writeError(w, errnoFileTooBig, "文件过大，请使用客户端下载")
+		return
+	}
+	s.writeEncoded(w, key, map[string]any{
+		n.id: map[string]any{
+			"file_name": n.name,
+			"file_size": strconv.Itoa(len(n.content)),
+			"pick_code": n.pickCode,
+			"url": map[string]any{
+				"client": 1,
+				"oss_id": n.sha1,
+				"url":    s.downloadURL(n),
+			},
+		},
+	})
+}
+
+func (s *Server) handleAndroidDownURL(w http.ResponseWriter, r *http.Request) {
+	params := struct {
+		PickCode string `json:"pick_code"`
+	}{}
+	key, ok := s.decodeRequest(r, &params)
+	if !ok {
+		writeError(w, errnoWrongParams, "参数错误")
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := s.byPickCode(params.PickCode)
+	if n == nil {
+		writeError(w, errnoPickCodeNotExist, "pickcode does not exist")
+		return
+	}
+	s.writeEncoded(w, key, map[string]any{"url": s.downloadURL(n)})
+}
+
+// handleDownload serves the content of a file, honouring Range requests.
+func (s *Server) handleDownload(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	n := s.byPickCode(r.PathValue("pickcode"))
+	var (
+		content []byte
+		name    string
+	)
+	if n != nil {
+		content, name = n.content, n.name
+	}
+	mtime := s.nodes[RootID].mtime
+	if n != nil {
+		mtime = n.mtime
+	}
+	expires, _ := strconv.ParseInt(r.URL.Query().Get("t"), 10, 64)
+	expired := r.URL.Query().Get("gen") != strconv.Itoa(s.urlGen) || time.Now().Unix() > expires
+	s.mu.Unlock()
+	if n == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if expired {
+		http.Error(w, "expired", http.StatusForbidden)
+		return
+	}
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	http.ServeContent(w, r, name, mtime, bytes.NewReader(content))
+}
@@ -0,0 +1,502 @@
+package fake115
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// error codes returned by the 115 webapi
+const (
+	errnoExist       = 20004
+	errnoWrongParams = 990002
+	errnoCyclicCopy  = 91002
+	errnoCyclicMove  = 800006
+)
+
+var shanghai = time.FixedZone("UTC+8", 8*3600)
+
+// fileInfo renders n like an entry of the file list API.
+func fileInfo(n *node) map[string]any {
+	info := map[string]any{
+		"aid": "1",
+		"n":   n.name,
+		"pc":  n.pickCode,
+		"m":   boolInt(n.star),
+		"tp":  n.ctime.Unix(),
+		"fl":  []any{},
+	}
+	if n.isDir {
+		info["cid"] = n.id
+		info["pid"] = n.parentID
+		info["t"] = strconv.FormatInt(n.mtime.Unix(), 10)
+		return info
+	}
+	info["fid"] = n.id
+	info["cid"] = n.parentID
+	info["s"] = len(n.content)
+	info["sha"] = n.sha1
+	info["ico"] = strings.TrimPrefix(path.Ext(n.name), ".")
+	info["t"] = n.mtime.In(shanghai).Format("2006-01-02 15:04")
+	return info
+}
+
+// sortOptions are the ordering params of the file list API.
+type sortOptions struct {
+	order   string
+	asc     bool
+	mixed   bool
+	natural bool
+}
+
+func listSortOptions(q url.Values) sortOptions {
+	return sortOptions{
+		order:   q.Get("o"),
+		asc:     q.Get("asc") != "0",
+		mixed:   q.Get("fc_mix") == "1",
+		natural: q.Get("natsort") == "1",
+	}
+}
+
+// sortNodes sorts nodes by the order of the file list API, directories first
+// unless mixed.
+func sortNodes(nodes []*node, o sortOptions) {
+	less := func(a, b *node) bool {
+		switch o.order {
+		case "file_size":
+			if len(a.content) != len(b.content) {
+				return len(a.content) < len(b.content)
+			}
+		case "user_ptime":
+			if !a.ctime.Equal(b.ctime) {
+				return a.ctime.Before(b.ctime)
+			}
+		case "user_utime":
+			if !a.mtime.Equal(b.mtime) {
+				return a.mtime.Before(b.mtime)
+			}
+		case "file_type":
+			if ea, eb := path.Ext(a.name), path.Ext(b.name); ea != eb {
+				return ea < eb
+			}
+		}
+		if a.name != b.name {
+			if o.natural {
+				return naturalLess(a.name, b.name)
+			}
+			return a.name < b.name
+		}
+		return a.id < b.id
+	}
+	sort.SliceStable(nodes, func(i, j int) bool {
+		a, b := nodes[i], nodes[j]
+		if a.isDir != b.isDir && !o.mixed {
+			return a.isDir
+		}
+		if o.asc {
+			return less(a, b)
+		}
+		return less(b, a)
+	})
+}
+
+// naturalLess compares a and b with the runs of digits compared as numbers.
+func naturalLess(a, b string) bool {
+	for a != "" && b != "" {
+		da, db := digitPrefix(a), digitPrefix(b)
+		if da == "" || db == "" {
+			if a[0] != b[0] {
+				return a[0] < b[0]
+			}
+			a, b = a[1:], b[1:]
+			continue
+		}
+		na, nb := strings.TrimLeft(da, "0"), strings.TrimLeft(db, "0")
+		if len(na) != len(nb) {
+			return len(na) < len(nb)
+		}
+		if na != nb {
+			return na < nb
+		}
+		a, b = a[len(da):], b[len(db):]
+	}
+	return len(a) < len(b)
+}
+
+func digitPrefix(s string) string {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return s[:i]
+}
+
+// filtered reports whether n is excluded by the filter params of the file
+// list API, the type param being numbered like the one of the search API.
+func filtered(n *node, q url.Values) bool {
+	ext := strings.ToLower(path.Ext(n.name))
+	switch t := q.Get("type"); {
+	case t == "1" && !n.isDir:
+		return true
+	case searchTypes[t] != nil && (n.isDir || !contains(searchTypes[t], ext)):
+		return true
+	}
+	if suffix := q.Get("suffix"); suffix != "" && (n.isDir || !strings.EqualFold(ext, "."+suffix)) {
+		return true
+	}
+	if q.Get("star") == "1" && !n.star {
+		return true
+	}
+	return n.isDir && q.Get("show_dir") == "0"
+}
+
+// page returns the slice of nodes selected by the offset and limit query params.
+func page(nodes []*node, r *http.Request) (offset, limit int, paged []*node) {
+	q := r.URL.Query()
+	offset, _ = strconv.Atoi(q.Get("offset"))
+	limit, _ = strconv.Atoi(q.Get("limit"))
+	if limit <= 0 || limit > maxPageLimit {
+		limit = maxPageLimit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	if offset < len(nodes) {
+		paged = nodes[offset:min(offset+limit, len(nodes))]
+	}
+	return
+}
+
+func renderNodes(nodes []*node) []map[string]any {
+	data := make([]map[string]any, 0, len(nodes))
+	for _, n := range nodes {
+		data = append(data, fileInfo(n))
+	}
+	return data
+}
+
+func (s *Server) handleList(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	q := r.URL.Query()
+	// like the real service, an unknown directory lists the root
+	dir := s.liveDir(q.Get("cid"))
+	if dir == nil {
+		dir = s.nodes[RootID]
+	}
+	o := listSortOptions(q)
+	var nodes []*node
+	for _, n := range s.children(dir.id) {
+		if !filtered(n, q) {
+			nodes = append(nodes, n)
+		}
+	}
+	sortNodes(nodes, o)
+	offset, limit, paged := page(nodes, r)
+	writeJSON(w, map[string]any{
+		"state":     true,
+		"aid":       "1",
+		"cid":       dir.id,
+		"count":     len(nodes),
+		"order":     o.order,
+		"is_asc":    boolInt(o.asc),
+		"offset":    offset,
+		"limit":     limit,
+		"page_size": limit,
+		"data":      renderNodes(paged),
+	})
+}
+
+func (s *Server) handleGetID(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dir := s.nodes[RootID]
+	for _, name := range splitPath(r.URL.Query().Get("path")) {
+		if dir = s.childByName(dir.id, name); dir == nil || !dir.isDir {
+			// the real service reports a missing path as the root
+			writeJSON(w, map[string]any{"state": true, "id": 0, "is_private": 0})
+			return
+		}
+	}
+	writeJSON(w, map[string]any{"state": true, "id": dir.id, "is_private": 0})
+}
+
+func (s *Server) handleMkdir(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseForm()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	parent := s.liveDir(r.PostForm.Get("pid"))
+	name := r.PostForm.Get("cname")
+	if parent == nil || name == "" {
+		writeError(w, errnoWrongParams, "参数错误")
+		return
+	}
+	if s.childByName(parent.id, name) != nil {
+		writeError(w, errnoExist, "该目录名称已存在。")
+		return
+	}
+	n := s.addNode(parent.id, name, true, nil)
+	writeJSON(w, map[string]any{
+		"state":     true,
+		"aid":       1,
+		"cid":       n.id,
+		"cname":     n.name,
+		"file_id":   n.id,
+		"file_name": n.name,
+	})
+}
+
+// lookup returns the live nodes for ids, or false if one is missing.
+func (s *Server) lookup(ids []string) ([]*node, bool) {
+	nodes := make([]*node, 0, len(ids))
+	for _, id := range ids {
+		n := s.live(id)
+		if n == nil || n.id == RootID {
+			return nil, false
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes, len(nodes) > 0
+}
+
+func (s *Server) handleDelete(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseForm()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	nodes, ok := s.lookup(indexedForm(r, "fid"))
+	if !ok {
+		writeError(w, errnoWrongParams, "参数错误")
+		return
+	}
+	now := time.Now()
+	for _, n := range nodes {
+		parentName := ""
+		if p := s.nodes[n.parentID]; p != nil {
+			parentName = p.name
+		}
+		for _, d := range s.descendants(n) {
+			d.deleted = true
+		}
+		s.recycle = append(s.recycle, &recycleItem{node: n, parentName: parentName, dtime: now})
+	}
+	writeOK(w)
+}
+
+func (s *Server) handleMove(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseForm()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dir := s.liveDir(r.PostForm.Get("pid"))
+	nodes, ok := s.lookup(indexedForm(r, "fid"))
+	if dir == nil || !ok {
+		writeError(w, errnoWrongParams, "参数错误")
+		return
+	}
+	for _, n := range nodes {
+		if n.isDir && s.isAncestor(n, dir.id) {
+			writeError(w, errnoCyclicMove, "不能移动到自身或其子目录")
+			return
+		}
+	}
+	now := time.Now()
+	for _, n := range nodes {
+		n.parentID = dir.id
+		n.mtime = now
+	}
+	writeOK(w)
+}
+
+func (s *Server) handleCopy(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseForm()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dir := s.liveDir(r.PostForm.Get("pid"))
+	nodes, ok := s.lookup(indexedForm(r, "fid"))
+	if dir == nil || !ok {
+		writeError(w, errnoWrongParams, "参数错误")
+		return
+	}
+	for _, n := range nodes {
+		if n.isDir && s.isAncestor(n, dir.id) {
+			writeError(w, errnoCyclicCopy, "不能复制到自身或其子目录")
+			return
+		}
+	}
+	for _, n := range nodes {
+		s.copyNode(n, dir.id)
+	}
+	writeOK(w)
+}
+
+// copyNode copies the subtree of n under parentID, must be called with s.mu held.
+func (s *Server) copyNode(n *node, parentID string) {
+	c := s.addNode(parentID, n.name, n.isDir, n.content)
+	if !n.isDir {
+		return
+	}
+	for _, child := range s.children(n.id) {
+		s.copyNode(child, c.id)
+	}
+}
+
+func (s *Server) handleRename(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseForm()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	renamed := map[*node]string{}
+	for key, values := range r.PostForm {
+		id, ok := strings.CutPrefix(key, "files_new_name[")
+		if !ok || len(values) == 0 {
+			continue
+		}
+		n := s.live(strings.TrimSuffix(id, "]"))
+		if n == nil || n.id == RootID || values[0] == "" {
+			writeError(w, errnoWrongParams, "参数错误")
+			return
+		}
+		renamed[n] = values[0]
+	}
+	if len(renamed) == 0 {
+		writeError(w, errnoWrongParams, "参数错误")
+		return
+	}
+	now := time.Now()
+	for n, name := range renamed {
+		n.name = name
+		n.mtime = now
+	}
+	writeOK(w)
+}
+
+func (s *Server) handleStat(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := s.live(r.URL.Query().Get("cid"))
+	if n == nil {
+		writeError(w, errnoWrongParams, "参数错误")
+		return
+	}
+	var files, dirs, size int
+	for _, d := range s.descendants(n)[1:] {
+		if d.deleted {
+			continue
+		}
+		if d.isDir {
+			dirs++
+		} else {
+			files++
+			size += len(d.content)
+		}
+	}
+	if !n.isDir {
+		size = len(n.content)
+	}
+	paths := []map[string]any{}
+	for _, p := range s.parents(n) {
+		id, _ := strconv.Atoi(p.id)
+		paths = append(paths, map[string]any{"file_id": id, "file_name": p.name})
+	}
+	writeJSON(w, map[string]any{
+		"count":         strconv.Itoa(files),
+		"size":          strconv.Itoa(size),
+		"folder_count":  strconv.Itoa(dirs),
+		"ptime":         strconv.FormatInt(n.ctime.Unix(), 10),
+		"utime":         strconv.FormatInt(n.mtime.Unix(), 10),
+		"is_share":      "0",
+		"file_name":     n.name,
+		"pick_code":     n.pickCode,
+		"sha1":          n.sha1,
+		"is_mark":       strconv.Itoa(boolInt(n.star)),
+		"open_time":     0,
+		"file_category": strconv.Itoa(boolInt(!n.isDir)),
+		"paths":         paths,
+	})
+}
+
+func (s *Server) handleGetInfo(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := s.live(r.URL.Query().Get("file_id"))
+	if n == nil || n.id == RootID {
+		writeError(w, errnoWrongParams, "参数错误")
+		return
+	}
+	writeJSON(w, map[string]any{"state": true, "data": []any{fileInfo(n)}})
+}
+
+// searchTypes maps the type param of the search API to file extensions.
+var searchTypes = map[string][]string{
+	"2": {".txt", ".doc", ".docx", ".pdf", ".xls", ".xlsx", ".ppt", ".pptx", ".md"},
+	"3": {".jpg", ".jpeg", ".png", ".gif", ".bmp", ".webp"},
+	"4": {".mp4", ".mkv", ".avi", ".mov", ".wmv", ".flv", ".ts"},
+	"5": {".mp3", ".flac", ".wav", ".aac", ".ogg", ".m4a"},
+	"6": {".zip", ".rar", ".7z", ".tar", ".gz"},
+}
+
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	q := r.URL.Query()
+	scope := s.liveDir(q.Get("cid"))
+	if scope == nil {
+		scope = s.nodes[RootID]
+	}
+	value := strings.ToLower(q.Get("search_value"))
+	typ := q.Get("type")
+	suffix := strings.ToLower(strings.TrimPrefix(q.Get("suffix"), "."))
+	var nodes []*node
+	for _, n := range s.descendants(scope)[1:] {
+		if n.deleted || !strings.Contains(strings.ToLower(n.name), value) {
+			continue
+		}
+		if q.Get("pick_code") != "" && n.pickCode != q.Get("pick_code") {
+			continue
+		}
+		if q.Get("star") == "1" && !n.star {
+			continue
+		}
+		ext := strings.ToLower(path.Ext(n.name))
+		if suffix != "" && (n.isDir || strings.TrimPrefix(ext, ".") != suffix) {
+			continue
+		}
+		if typ == "1" && !n.isDir {
+			continue
+		}
+		if exts, ok := searchTypes[typ]; ok && (n.isDir || !contains(exts, ext)) {
+			continue
+		}
+		nodes = append(nodes, n)
+	}
+	o := listSortOptions(q)
+	sortNodes(nodes, o)
+	offset, limit, paged := page(nodes, r)
+	writeJSON(w, map[string]any{
+		"state":     true,
+		"count":     len(nodes),
+		"order":     o.order,
+		"is_asc":    boolInt(o.asc),
+		"offset":    offset,
+		"page_size": limit,
+		"data":      renderNodes(paged),
+	})
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
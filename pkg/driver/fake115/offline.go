@@ -0,0 +1,225 @@
+package fake115
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// errno of the offline APIs
+const (
+	errnoOfflineInvalidLink = 10004
+	errnoOfflineTaskExisted = 10008
+)
+
+// offlinePageRow is the number of tasks per page of the task list API.
+const offlinePageRow = 30
+
+// offline task status
+const (
+	taskFailed  = -1
+	taskTodo    = 0
+	taskRunning = 1
+	taskDone    = 2
+)
+
+type offlineTask struct {
+	hash    string
+	url     string
+	name    string
+	dirID   string
+	fileID  string
+	status  int
+	addTime time.Time
+}
+
+func validOfflineURL(uri string) bool {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https", "ftp", "ed2k", "magnet":
+		return true
+	}
+	return false
+}
+
+func (s *Server) handleOfflineAdd(w http.ResponseWriter, r *http.Request) {
+	params := map[string]string{}
+	key, ok := s.decodeRequest(r, &params)
+	if !ok {
+		writeError(w, errnoWrongParams, "参数错误")
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dirID := params["wp_path_id"]
+	if dirID == "" || s.liveDir(dirID) == nil {
+		dirID = RootID
+	}
+	var result []map[string]any
+	for i := 0; ; i++ {
+		uri, ok := params["url["+strconv.Itoa(i)+"]"]
+		if !ok {
+			break
+		}
+		if !validOfflineURL(uri) {
+			writeError(w, errnoOfflineInvalidLink, "链接无效")
+			return
+		}
+		sum := sha1.Sum([]byte(uri))
+		hash := hex.EncodeToString(sum[:])
+		if s.task(hash) != nil {
+			writeError(w, errnoOfflineTaskExisted, "任务已存在")
+			return
+		}
+		name := path.Base(uri)
+		if u, err := url.Parse(uri); err == nil && u.Path != "" {
+			name = path.Base(u.Path)
+		}
+		s.tasks = append(s.tasks, &offlineTask{
+			hash:    hash,
+			url:     uri,
+			name:    name,
+			dirID:   dirID,
+			status:  taskTodo,
+			addTime: time.Now(),
+		})
+		result = append(result, map[string]any{"info_hash": hash, "url": uri})
+	}
+	s.writeEncoded(w, key, map[string]any{"state": true, "result": result})
+}
+
+// handleOffline serves the task APIs of lixian.115.com, selected by the ac query param.
+func (s *Server) handleOffline(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseForm()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch r.URL.Query().Get("ac") {
+	case "task_lists":
+		s.listTasks(w, r)
+	case "task_del":
+		hashes := r.PostForm["hash"]
+		s.removeTasks(func(t *offlineTask) bool { return contains(hashes, t.hash) }, r.PostForm.Get("flag") == "1")
+		writeOK(w)
+	case "task_clear":
+		flag := r.PostForm.Get("flag")
+		s.removeTasks(func(t *offlineTask) bool {
+			switch flag {
+			case "0", "4":
+				return t.status == taskDone
+			case "1", "5":
+				return true
+			case "2":
+				return t.status == taskFailed
+			case "3":
+				return t.status == taskRunning
+			}
+			return false
+		}, flag == "4" || flag == "5")
+		writeOK(w)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) listTasks(w http.ResponseWriter, r *http.Request) {
+	p, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if p < 1 {
+		p = 1
+	}
+	tasks := []map[string]any{}
+	for i := (p - 1) * offlinePageRow; i < len(s.tasks) && i < p*offlinePageRow; i++ {
+		t := s.tasks[i]
+		size, percent := 0, 0
+		if t.status == taskDone {
+			percent = 100
+			if n := s.live(t.fileID); n != nil {
+				size = len(n.content)
+			}
+		}
+		tasks = append(tasks, map[string]any{
+			"info_hash":      t.hash,
+			"name":           t.name,
+			"size":           size,
+			"url":            t.url,
+			"add_time":       t.addTime.Unix(),
+			"status":         t.status,
+			"percentDone":    percent,
+			"last_update":    t.addTime.Unix(),
+			"file_id":        t.fileID,
+			"delete_file_id": t.fileID,
+			"wp_path_id":     t.dirID,
+		})
+	}
+	pageCount := (len(s.tasks) + offlinePageRow - 1) / offlinePageRow
+	writeJSON(w, map[string]any{
+		"state":      true,
+		"total":      len(s.tasks),
+		"count":      len(s.tasks),
+		"page_row":   offlinePageRow,
+		"page_count": pageCount,
+		"page":       p,
+		"quota":      1000,
+		"tasks":      tasks,
+	})
+}
+
+// removeTasks removes the tasks matching match, deleting their files if deleteFiles.
+func (s *Server) removeTasks(match func(t *offlineTask) bool, deleteFiles bool) {
+	var kept []*offlineTask
+	for _, t := range s.tasks {
+		if !match(t) {
+			kept = append(kept, t)
+			continue
+		}
+		if n := s.live(t.fileID); deleteFiles && n != nil {
+			for _, d := range s.descendants(n) {
+				delete(s.nodes, d.id)
+			}
+		}
+	}
+	s.tasks = kept
+}
+
+func (s *Server) task(hash string) *offlineTask {
+	for _, t := range s.tasks {
+		if t.hash == hash {
+			return t
+		}
+	}
+	return nil
+}
+
+// FinishOfflineTask completes the offline task with hash, saving content as
+// the downloaded file in the task directory.
+func (s *Server) FinishOfflineTask(hash string, content []byte) (File, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := s.task(hash)
+	if t == nil {
+		return File{}, false
+	}
+	n := s.addNode(t.dirID, t.name, false, content)
+	t.fileID = n.id
+	t.status = taskDone
+	return n.file(), true
+}
+
+// FailOfflineTask marks the offline task with hash as failed.
+func (s *Server) FailOfflineTask(hash string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := s.task(hash)
+	if t == nil {
+		return false
+	}
+	t.status = taskFailed
+	return true
+}
@@ -0,0 +1,432 @@
+package fake115
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultBucket is the OSS bucket of the upload parameters handed out.
+	DefaultBucket = "fake115"
+
+	// maxListParts is the largest page served by the OSS ListParts API.
+	maxListParts = 1000
+)
+
+// UploadParams are the OSS upload parameters the 115 upload init API hands
+// out when a file cannot be rapid uploaded. They mirror driver.UploadOSSParams.
+type UploadParams struct {
+	SHA1        string
+	Bucket      string
+	Object      string
+	Callback    string
+	CallbackVar string
+}
+
+// ossUpload is a multipart upload in progress.
+type ossUpload struct {
+	id         string
+	bucket     string
+	key        string
+	sequential bool
+	parts      map[int]*ossPart
+}
+
+type ossPart struct {
+	data []byte
+	etag string
+	time time.Time
+}
+
+// OSSFault decides the fate of an OSS request: a non-zero status makes the
+// server fail the request with it instead of serving it.
+type OSSFault func(r *http.Request) int
+
+// UploadParams returns the parameters to upload a file named name of the
+// given SHA1 into dirID through OSS. Once the object is stored, the callback
+// creates the file, provided OSS reports the expected SHA1.
+func (s *Server) UploadParams(dirID, name, sha1 string) UploadParams {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.uploadParams(dirID, name, sha1)
+}
+
+// uploadParams must be called with s.mu held.
+func (s *Server) uploadParams(dirID, name, sha1 string) UploadParams {
+	s.nextID++
+	callback, _ := json.Marshal(map[string]string{
+		"callbackUrl":      s.URL + "/fake115/callback",
+		"callbackBody":     "bucket=${bucket}&object=${object}&size=${size}&sha1=${sha1}&target=${x:target}&name=${x:name}&expect=${x:sha1}",
+		"callbackBodyType": "application/x-www-form-urlencoded",
+	})
+	callbackVar, _ := json.Marshal(map[string]string{
+		"x:target": "U_1_" + dirID,
+		"x:name":   name,
+		"x:sha1":   strings.ToUpper(sha1),
+	})
+	return UploadParams{
+		SHA1:        strings.ToUpper(sha1),
+		Bucket:      DefaultBucket,
+		Object:      "fake115/" + strconv.FormatInt(s.nextID, 10),
+		Callback:    string(callback),
+		CallbackVar: string(callbackVar),
+	}
+}
+
+// SetOSSFault installs fault to inject failures into the OSS requests, nil
+// removes it.
+func (s *Server) SetOSSFault(fault OSSFault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ossFault = fault
+}
+
+// MultipartUploads returns the IDs of the multipart uploads neither completed
+// nor aborted.
+func (s *Server) MultipartUploads() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids := make([]string, 0, len(s.uploads))
+	for id := range s.uploads {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// UploadedParts returns the numbers of the parts stored for the multipart
+// upload id.
+func (s *Server) UploadedParts(id string) []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.uploads[id]
+	if !ok {
+		return nil
+	}
+	return u.partNumbers()
+}
+
+func (u *ossUpload) partNumbers() []int {
+	numbers := make([]int, 0, len(u.parts))
+	for n := range u.parts {
+		numbers = append(numbers, n)
+	}
+	sort.Ints(numbers)
+	return numbers
+}
+
+func (s *Server) handleOSSToken(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, map[string]any{
+		"AccessKeyID":     "fake115-key",
+		"AccessKeySecret": "fake115-secret",
+		"Expiration":      time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+		"SecurityToken":   "fake115-token",
+		"StatusCode":      "200",
+	})
+}
+
+// serveOSS serves the OSS API with path style URLs, /bucket/object.
+func (s *Server) serveOSS(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	fault := s.ossFault
+	s.mu.Unlock()
+	if fault != nil {
+		if status := fault(r); status != 0 {
+			writeOSSError(w, status, "InternalError", "injected fault")
+			return
+		}
+	}
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	q := r.URL.Query()
+	_, uploads := q["uploads"]
+	uploadID := q.Get("uploadId")
+	switch {
+	case r.Method == http.MethodPost && uploads:
+		s.handleInitiateMultipart(w, r, bucket, key)
+	case r.Method == http.MethodPut && uploadID != "":
+		s.handleUploadPart(w, r, uploadID)
+	case r.Method == http.MethodPut:
+		s.handlePutObject(w, r, bucket, key)
+	case r.Method == http.MethodGet && uploadID != "":
+		s.handleListParts(w, r, uploadID)
+	case r.Method == http.MethodPost && uploadID != "":
+		s.handleCompleteMultipart(w, r, uploadID)
+	case r.Method == http.MethodDelete && uploadID != "":
+		s.handleAbortMultipart(w, uploadID)
+	default:
+		writeOSSError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", "unsupported request")
+	}
+}
+
+func (s *Server) handleInitiateMultipart(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	s.mu.Lock()
+	s.nextID++
+	u := &ossUpload{
+		id:     "upload" + strconv.FormatInt(s.nextID, 10),
+		bucket: bucket,
+		key:    key,
+		parts:  map[int]*ossPart{},
+	}
+	// OSS computes the SHA1 of the object only when asked to and the parts
+	// are uploaded in order.
+	_, u.sequential = r.URL.Query()["sequential"]
+	if _, ok := r.URL.Query()["x-oss-enable-sha1"]; !ok {
+		u.sequential = false
+	}
+	s.uploads[u.id] = u
+	s.mu.Unlock()
+
+	writeXML(w, struct {
+		XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+		Bucket   string   `xml:"Bucket"`
+		Key      string   `xml:"Key"`
+		UploadID string   `xml:"UploadId"`
+	}{Bucket: bucket, Key: key, UploadID: u.id})
+}
+
+func (s *Server) handleUploadPart(w http.ResponseWriter, r *http.Request, uploadID string) {
+	number, err := strconv.Atoi(r.URL.Query().Get("partNumber"))
+	if err != nil || number < 1 || number > 10000 {
+		writeOSSError(w, http.StatusBadRequest, "InvalidArgument", "invalid part number")
+		return
+	}
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeOSSError(w, http.StatusBadRequest, "IncompleteBody", err.Error())
+		return
+	}
+	sum := md5.Sum(data)
+	if want := r.Header.Get("Content-MD5"); want != "" && want != base64.StdEncoding.EncodeToString(sum[:]) {
+		writeOSSError(w, http.StatusBadRequest, "InvalidDigest", "content md5 mismatch")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.uploads[uploadID]
+	if !ok {
+		writeOSSError(w, http.StatusNotFound, "NoSuchUpload", "the upload does not exist")
+		return
+	}
+	if u.sequential && number > 1 && u.parts[number-1] == nil {
+		writeOSSError(w, http.StatusBadRequest, "InvalidPartOrder", "sequential parts must be uploaded in order")
+		return
+	}
+	etag := `"` + strings.ToUpper(hex.EncodeToString(sum[:])) + `"`
+	u.parts[number] = &ossPart{data: data, etag: etag, time: time.Now()}
+	w.Header().Set("ETag", etag)
+}
+
+func (s *Server) handleListParts(w http.ResponseWriter, r *http.Request, uploadID string) {
+	q := r.URL.Query()
+	marker, _ := strconv.Atoi(q.Get("part-number-marker"))
+	maxParts, err := strconv.Atoi(q.Get("max-parts"))
+	if err != nil || maxParts <= 0 || maxParts > maxListParts {
+		maxParts = maxListParts
+	}
+
+	type part struct {
+		PartNumber   int       `xml:"PartNumber"`
+		LastModified time.Time `xml:"LastModified"`
+		ETag         string    `xml:"ETag"`
+		Size         int       `xml:"Size"`
+	}
+	result := struct {
+		XMLName              xml.Name `xml:"ListPartsResult"`
+		Bucket               string   `xml:"Bucket"`
+		Key                  string   `xml:"Key"`
+		UploadID             string   `xml:"UploadId"`
+		NextPartNumberMarker string   `xml:"NextPartNumberMarker"`
+		MaxParts             int      `xml:"MaxParts"`
+		IsTruncated          bool     `xml:"IsTruncated"`
+		Parts                []part   `xml:"Part"`
+	}{UploadID: uploadID, MaxParts: maxParts}
+
+	s.mu.Lock()
+	u, ok := s.uploads[uploadID]
+	if ok {
+		result.Bucket, result.Key = u.bucket, url.QueryEscape(u.key)
+		for _, n := range u.partNumbers() {
+			if n <= marker {
+				continue
+			}
+			if len(result.Parts) == maxParts {
+				result.IsTruncated = true
+				break
+			}
+			p := u.parts[n]
+			result.Parts = append(result.Parts, part{PartNumber: n, LastModified: p.time, ETag: p.etag, Size: len(p.data)})
+			result.NextPartNumberMarker = strconv.Itoa(n)
+		}
+	}
+	s.mu.Unlock()
+	if !ok {
+		writeOSSError(w, http.StatusNotFound, "NoSuchUpload", "the upload does not exist")
+		return
+	}
+	writeXML(w, result)
+}
+
+func (s *Server) handleCompleteMultipart(w http.ResponseWriter, r *http.Request, uploadID string) {
+	var complete struct {
+		Parts []struct {
+			PartNumber int    `xml:"PartNumber"`
+			ETag       string `xml:"ETag"`
+		} `xml:"Part"`
+	}
+	if err := xml.NewDecoder(r.Body).Decode(&complete); err != nil || len(complete.Parts) == 0 {
+		writeOSSError(w, http.StatusBadRequest, "MalformedXML", "invalid part list")
+		return
+	}
+
+	s.mu.Lock()
+	u, ok := s.uploads[uploadID]
+	if !ok {
+		s.mu.Unlock()
+		writeOSSError(w, http.StatusNotFound, "NoSuchUpload", "the upload does not exist")
+		return
+	}
+	var content []byte
+	for i, p := range complete.Parts {
+		part := u.parts[p.PartNumber]
+		if part == nil || part.etag != p.ETag || (i > 0 && p.PartNumber <= complete.Parts[i-1].PartNumber) {
+			s.mu.Unlock()
+			writeOSSError(w, http.StatusBadRequest, "InvalidPart", "part "+strconv.Itoa(p.PartNumber)+" is missing or does not match")
+			return
+		}
+		content = append(content, part.data...)
+	}
+	delete(s.uploads, uploadID)
+	s.mu.Unlock()
+
+	s.callback(w, r, u.bucket, u.key, content, u.sequential)
+}
+
+func (s *Server) handleAbortMultipart(w http.ResponseWriter, uploadID string) {
+	s.mu.Lock()
+	_, ok := s.uploads[uploadID]
+	delete(s.uploads, uploadID)
+	s.mu.Unlock()
+	if !ok {
+		writeOSSError(w, http.StatusNotFound, "NoSuchUpload", "the upload does not exist")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handlePutObject(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	content, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeOSSError(w, http.StatusBadRequest, "IncompleteBody", err.Error())
+		return
+	}
+	s.callback(w, r, bucket, key, content, true)
+}
+
+// callback runs the upload callback of the request once the object is stored,
+// creating the file and writing the 115 upload result. withSHA1 tells whether
+// OSS knows the SHA1 of the object, for the ${sha1} variable.
+func (s *Server) callback(w http.ResponseWriter, r *http.Request, bucket, key string, content []byte, withSHA1 bool) {
+	var callback struct {
+		CallbackBody string `json:"callbackBody"`
+	}
+	vars := map[string]string{}
+	if err := decodeHeader(r.Header.Get("x-oss-callback"), &callback); err != nil {
+		writeOSSError(w, http.StatusBadRequest, "InvalidArgument", "invalid callback")
+		return
+	}
+	if err := decodeHeader(r.Header.Get("x-oss-callback-var"), &vars); err != nil {
+		writeOSSError(w, http.StatusBadRequest, "InvalidArgument", "invalid callback var")
+		return
+	}
+	sum := sha1.Sum(content)
+	sha1Hex := ""
+	if withSHA1 {
+		sha1Hex = strings.ToUpper(hex.EncodeToString(sum[:]))
+	}
+	replacements := []string{
+		"${bucket}", bucket,
+		"${object}", key,
+		"${size}", strconv.Itoa(len(content)),
+		"${sha1}", sha1Hex,
+	}
+	for k, v := range vars {
+		replacements = append(replacements, "${"+k+"}", url.QueryEscape(v))
+	}
+	form, err := url.ParseQuery(strings.NewReplacer(replacements...).Replace(callback.CallbackBody))
+	if err != nil {
+		writeOSSError(w, http.StatusBadRequest, "InvalidArgument", "invalid callback body")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	got := form.Get("sha1")
+	if got == "" || got != form.Get("expect") {
+		_ = json.NewEncoder(w).Encode(map[string]any{"state": false, "errno": 990002, "error": "sha1 mismatch: " + got})
+		return
+	}
+	dirID := strings.TrimPrefix(form.Get("target"), "U_1_")
+
+	s.mu.Lock()
+	if s.liveDir(dirID) == nil {
+		s.mu.Unlock()
+		_ = json.NewEncoder(w).Encode(map[string]any{"state": false, "errno": 990009, "error": "target dir missing"})
+		return
+	}
+	n := s.addNode(dirID, form.Get("name"), false, content)
+	s.mu.Unlock()
+
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"state": true,
+		"data": map[string]any{
+			"pick_code": n.pickCode,
+			"file_size": len(content),
+			"file_id":   n.id,
+			"sha1":      n.sha1,
+			"file_name": n.name,
+			"cid":       dirID,
+		},
+	})
+}
+
+func decodeHeader(v string, out any) error {
+	if v == "" {
+		return nil
+	}
+	data, err := base64.StdEncoding.DecodeString(v)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+func writeXML(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/xml")
+	_, _ = io.WriteString(w, xml.Header)
+	_ = xml.NewEncoder(w).Encode(v)
+}
+
+// writeOSSError writes an error response of the OSS API.
+func writeOSSError(w http.ResponseWriter, status int, code, msg string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	_, _ = io.WriteString(w, xml.Header)
+	_ = xml.NewEncoder(w).Encode(struct {
+		XMLName xml.Name `xml:"Error"`
+		Code    string   `xml:"Code"`
+		Message string   `xml:"Message"`
+	}{Code: code, Message: msg})
+}
@@ -0,0 +1,114 @@
+package fake115
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// QRCode session status, as reported by the status API.
+const (
+	QRCodeWaiting  = 0
+	QRCodeScanned  = 1
+	QRCodeAllowed  = 2
+	QRCodeExpired  = -1
+	QRCodeCanceled = -2
+)
+
+// error codes of the QRCode APIs
+const (
+	codeQrcodeExpired = 40199002
+	codeFailedToLogin = 40101017
+)
+
+type qrcodeSession struct {
+	uid    string
+	time   int64
+	sign   string
+	status int
+}
+
+func (s *Server) handleQRCodeToken(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	now := time.Now().Unix()
+	sum := sha1.Sum([]byte("qrcode:" + strconv.FormatInt(s.nextID, 10)))
+	session := &qrcodeSession{
+		uid:  hex.EncodeToString(sum[:10]),
+		time: now,
+		sign: hex.EncodeToString(sum[10:]),
+	}
+	s.qrcodes[session.uid] = session
+	writeJSON(w, map[string]any{
+		"state": 1,
+		"code":  0,
+		"data": map[string]any{
+			"uid":    session.uid,
+			"time":   session.time,
+			"sign":   session.sign,
+			"qrcode": "https://115.com/scan/dg-" + session.uid,
+		},
+	})
+}
+
+func writeQRCodeError(w http.ResponseWriter, code int, msg string) {
+	writeJSON(w, map[string]any{"state": 0, "code": code, "errno": code, "error": msg, "message": msg})
+}
+
+func (s *Server) handleQRCodeStatus(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	q := r.URL.Query()
+	session, ok := s.qrcodes[q.Get("uid")]
+	if !ok || session.sign != q.Get("sign") {
+		writeQRCodeError(w, codeQrcodeExpired, "二维码已过期")
+		return
+	}
+	writeJSON(w, map[string]any{
+		"state": 1,
+		"code":  0,
+		"data":  map[string]any{"status": session.status, "msg": "", "version": "1.0"},
+	})
+}
+
+func (s *Server) handleQRCodeLogin(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseForm()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.qrcodes[r.PostForm.Get("account")]
+	if !ok || session.status != QRCodeAllowed {
+		writeQRCodeError(w, codeFailedToLogin, "登录失败")
+		return
+	}
+	delete(s.qrcodes, session.uid)
+	uid := strconv.FormatInt(s.userID, 10)
+	writeJSON(w, map[string]any{
+		"state": 1,
+		"code":  0,
+		"data": map[string]any{
+			"user_id":   s.userID,
+			"user_name": s.userName,
+			"cookie": map[string]any{
+				"UID":  uid + "_A1_" + strconv.FormatInt(session.time, 10),
+				"CID":  session.sign[:16],
+				"SEID": session.sign,
+				"KID":  session.uid,
+			},
+		},
+	})
+}
+
+// SetQRCodeStatus sets the status of the QRCode session uid, like the mobile
+// app does when scanning and confirming the login.
+func (s *Server) SetQRCodeStatus(uid string, status int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.qrcodes[uid]
+	if ok {
+		session.status = status
+	}
+	return ok
+}
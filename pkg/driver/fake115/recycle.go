@@ -0,0 +1,99 @@
+package fake115
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type recycleItem struct {
+	node       *node
+	parentName string
+	dtime      time.Time
+}
+
+func (s *Server) handleRecycleList(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	q := r.URL.Query()
+	offset, _ := strconv.Atoi(q.Get("offset"))
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	if limit <= 0 {
+		limit = len(s.recycle)
+	}
+	data := []map[string]any{}
+	for i := offset; i >= 0 && i < len(s.recycle) && i < offset+limit; i++ {
+		item := s.recycle[i]
+		size := 0
+		if !item.node.isDir {
+			size = len(item.node.content)
+		}
+		data = append(data, map[string]any{
+			"id":          item.node.id,
+			"file_name":   item.node.name,
+			"file_size":   strconv.Itoa(size),
+			"cid":         item.node.parentID,
+			"parent_name": item.parentName,
+			"dtime":       strconv.FormatInt(item.dtime.Unix(), 10),
+		})
+	}
+	writeJSON(w, map[string]any{
+		"state":  true,
+		"count":  len(s.recycle),
+		"offset": offset,
+		"data":   data,
+	})
+}
+
+// takeRecycled removes the items with rids from the recycle bin, or all items
+// when rids is empty.
+func (s *Server) takeRecycled(rids []string) []*recycleItem {
+	if len(rids) == 0 {
+		items := s.recycle
+		s.recycle = nil
+		return items
+	}
+	var taken, kept []*recycleItem
+	for _, item := range s.recycle {
+		if contains(rids, item.node.id) {
+			taken = append(taken, item)
+		} else {
+			kept = append(kept, item)
+		}
+	}
+	s.recycle = kept
+	return taken
+}
+
+func (s *Server) handleRecycleRevert(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseForm()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rids := indexedForm(r, "rid")
+	if len(rids) == 0 {
+		writeError(w, errnoWrongParams, "参数错误")
+		return
+	}
+	for _, item := range s.takeRecycled(rids) {
+		for _, n := range s.descendants(item.node) {
+			n.deleted = false
+		}
+		// restore into the root when the original parent is gone
+		if s.liveDir(item.node.parentID) == nil {
+			item.node.parentID = RootID
+		}
+	}
+	writeOK(w)
+}
+
+func (s *Server) handleRecycleClean(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseForm()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, item := range s.takeRecycled(indexedForm(r, "rid")) {
+		for _, n := range s.descendants(item.node) {
+			delete(s.nodes, n.id)
+		}
+	}
+	writeOK(w)
+}
@@ -0,0 +1,440 @@
+// Package fake115 provides an in-process stand-in for the 115 web APIs.
+//
+// A Server keeps an in-memory file tree and serves the file, recycle bin,
+// download, offline task and QRCode login endpoints over HTTP, so a
+// Pan115Client pointed at it behaves like one talking to the real service:
+//
+//	srv := fake115.NewServer()
+//	defer srv.Close()
+//	client := driver.New(driver.UA(),
+//		driver.WithBaseHost(srv.URL),
+//		driver.WithM115PublicKey(srv.PublicKey()),
+//		driver.WithEC115PublicKey(srv.EC115PublicKey()),
+//	)
+//
+// It also runs a stand-in for the Aliyun OSS API the uploads go to, which
+// the client reaches with driver.WithHost(driver.OSSEndpoint, srv.OSSURL).
+//
+// The package does not depend on the driver, so it can be used from the
+// driver's own tests.
+package fake115
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SheltonZhu/115driver/pkg/crypto/ec115"
+)
+
+const (
+	// RootID is the ID of the root directory.
+	RootID = "0"
+
+	// DefaultUserID is the user ID reported by a new Server.
+	DefaultUserID = 10001
+	// DefaultUserName is the user name reported by a new Server.
+	DefaultUserName = "fake115"
+
+	// DefaultDownloadURLTTL is how long the download URLs of a new Server are valid.
+	DefaultDownloadURLTTL = 2 * time.Hour
+
+	// maxPageLimit is the largest page served by the file list API.
+	maxPageLimit = 1150
+)
+
+// File is a snapshot of an entry of the fake file tree.
+type File struct {
+	ID       string
+	ParentID string
+	Name     string
+	IsDir    bool
+	Size     int64
+	SHA1     string
+	PickCode string
+}
+
+// Server is an in-memory 115 service.
+type Server struct {
+	// URL is the base URL of the server, pass it to driver.WithBaseHost.
+	URL string
+	// OSSURL is the base URL of the OSS stand-in, see UploadParams.
+	OSSURL string
+	// Key signs the m115 payloads, pass its public part to driver.WithM115PublicKey.
+	Key *rsa.PrivateKey
+	// ecKey decrypts the payloads of the upload init API, see EC115PublicKey.
+	ecKey []byte
+	ecPub []byte
+
+	srv    *httptest.Server
+	ossSrv *httptest.Server
+	mux    *http.ServeMux
+
+	mu       sync.Mutex
+	userID   int64
+	userName string
+	nextID   int64
+	nodes    map[string]*node
+	recycle  []*recycleItem
+	tasks    []*offlineTask
+	qrcodes  map[string]*qrcodeSession
+	// urlGen is the generation of the download URLs, see ExpireDownloadURLs.
+	urlGen int
+	urlTTL time.Duration
+	// webLimit is the size above which the web download API refuses files.
+	webLimit int64
+	// uploads are the OSS multipart uploads in progress, by upload ID.
+	uploads  map[string]*ossUpload
+	ossFault OSSFault
+}
+
+type node struct {
+	id       string
+	parentID string
+	name     string
+	isDir    bool
+	content  []byte
+	sha1     string
+	pickCode string
+	star     bool
+	deleted  bool
+	ctime    time.Time
+	mtime    time.Time
+}
+
+func (n *node) file() File {
+	return File{
+		ID:       n.id,
+		ParentID: n.parentID,
+		Name:     n.name,
+		IsDir:    n.isDir,
+		Size:     int64(len(n.content)),
+		SHA1:     n.sha1,
+		PickCode: n.pickCode,
+	}
+}
+
+// NewServer starts a Server with an empty file tree. The caller should call
+// Close when finished, to shut it down.
+func NewServer() *Server {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		panic("fake115: generate key: " + err.Error())
+	}
+	ecKey, ecPub, err := ec115.GenerateServerKey()
+	if err != nil {
+		panic("fake115: generate ec key: " + err.Error())
+	}
+	s := &Server{
+		Key:      key,
+		ecKey:    ecKey,
+		ecPub:    ecPub,
+		userID:   DefaultUserID,
+		userName: DefaultUserName,
+		nextID:   1000,
+		urlTTL:   DefaultDownloadURLTTL,
+		nodes:    map[string]*node{},
+		qrcodes:  map[string]*qrcodeSession{},
+		uploads:  map[string]*ossUpload{},
+	}
+	now := time.Now()
+	s.nodes[RootID] = &node{id: RootID, name: "根目录", isDir: true, ctime: now, mtime: now}
+	s.routes()
+	s.srv = httptest.NewServer(s)
+	s.URL = s.srv.URL
+	s.ossSrv = httptest.NewServer(http.HandlerFunc(s.serveOSS))
+	s.OSSURL = s.ossSrv.URL
+	return s
+}
+
+// Close shuts down the server.
+func (s *Server) Close() {
+	s.srv.Close()
+	s.ossSrv.Close()
+}
+
+// PublicKey returns the key clients must encode m115 payloads with.
+func (s *Server) PublicKey() *rsa.PublicKey {
+	return &s.Key.PublicKey
+}
+
+// EC115PublicKey returns the key clients must encrypt upload init payloads
+// with, pass it to driver.WithEC115PublicKey.
+func (s *Server) EC115PublicKey() []byte {
+	return append([]byte(nil), s.ecPub...)
+}
+
+// UserID returns the ID of the logged in user.
+func (s *Server) UserID() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.userID
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) routes() {
+	mux := http.NewServeMux()
+
+	// login
+	mux.HandleFunc("GET /app/1.0/web/1.0/check/sso", s.handleLoginCheck)
+	mux.HandleFunc("GET /{$}", s.handleMy)
+	mux.HandleFunc("GET /1/web/1.0/api/chrome", s.handleAppVersion)
+	mux.HandleFunc("GET /files/index_info", s.handleIndexInfo)
+
+	// dir
+	mux.HandleFunc("POST /files/add", s.handleMkdir)
+	mux.HandleFunc("GET /files/getid", s.handleGetID)
+	mux.HandleFunc("GET /files", s.handleList)
+
+	// file
+	mux.HandleFunc("POST /rb/delete", s.handleDelete)
+	mux.HandleFunc("POST /files/move", s.handleMove)
+	mux.HandleFunc("POST /files/copy", s.handleCopy)
+	mux.HandleFunc("POST /files/batch_rename", s.handleRename)
+	mux.HandleFunc("GET /category/get", s.handleStat)
+	mux.HandleFunc("GET /files/get_info", s.handleGetInfo)
+	mux.HandleFunc("GET /files/search", s.handleSearch)
+
+	// recycle
+	mux.HandleFunc("GET /rb", s.handleRecycleList)
+	mux.HandleFunc("POST /rb/revert", s.handleRecycleRevert)
+	mux.HandleFunc("POST /rb/clean", s.handleRecycleClean)
+
+	// download
+	mux.HandleFunc("POST /app/chrome/downurl", s.handleDownURL)
+	mux.HandleFunc("POST /android/2.0/ufile/download", s.handleAndroidDownURL)
+	mux.HandleFunc("GET "+downloadPath+"{pickcode}", s.handleDownload)
+
+	// upload
+	mux.HandleFunc("POST /app/uploadinfo", s.handleUploadInfo)
+	mux.HandleFunc("POST /4.0/initupload.php", s.handleUploadInit)
+	mux.HandleFunc("GET /3.0/gettoken.php", s.handleOSSToken)
+
+	// offline
+	mux.HandleFunc("POST /lixianssp/", s.handleOfflineAdd)
+	mux.HandleFunc("POST /lixian/", s.handleOffline)
+
+	// qrcode
+	mux.HandleFunc("GET /api/1.0/web/1.0/token", s.handleQRCodeToken)
+	mux.HandleFunc("GET /get/status/", s.handleQRCodeStatus)
+	mux.HandleFunc("POST /app/1.0/{app}/1.0/login/qrcode", s.handleQRCodeLogin)
+
+	s.mux = mux
+}
+
+// AddDir creates a directory named name under parentID.
+func (s *Server) AddDir(parentID, name string) File {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addNode(parentID, name, true, nil).file()
+}
+
+// AddFile creates a file named name with content under parentID.
+func (s *Server) AddFile(parentID, name string, content []byte) File {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addNode(parentID, name, false, content).file()
+}
+
+// MkdirAll creates the directories of the slash separated p, like os.MkdirAll,
+// and returns the last one.
+func (s *Server) MkdirAll(p string) File {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dir := s.nodes[RootID]
+	for _, name := range splitPath(p) {
+		child := s.childByName(dir.id, name)
+		if child == nil {
+			child = s.addNode(dir.id, name, true, nil)
+		}
+		dir = child
+	}
+	return dir.file()
+}
+
+// Get returns the live entry with id.
+func (s *Server) Get(id string) (File, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := s.live(id)
+	if n == nil {
+		return File{}, false
+	}
+	return n.file(), true
+}
+
+// Content returns the content of the file with id.
+func (s *Server) Content(id string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := s.live(id)
+	if n == nil || n.isDir {
+		return nil, false
+	}
+	return append([]byte(nil), n.content...), true
+}
+
+// Children returns the live entries of the directory dirID.
+func (s *Server) Children(dirID string) []File {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var files []File
+	for _, n := range s.children(dirID) {
+		files = append(files, n.file())
+	}
+	return files
+}
+
+// addNode must be called with s.mu held.
+func (s *Server) addNode(parentID, name string, isDir bool, content []byte) *node {
+	s.nextID++
+	id := strconv.FormatInt(s.nextID, 10)
+	now := time.Now()
+	n := &node{
+		id:       id,
+		parentID: parentID,
+		name:     name,
+		isDir:    isDir,
+		content:  content,
+		pickCode: pickCode(id),
+		ctime:    now,
+		mtime:    now,
+	}
+	if !isDir {
+		sum := sha1.Sum(content)
+		n.sha1 = strings.ToUpper(hex.EncodeToString(sum[:]))
+	}
+	s.nodes[id] = n
+	return n
+}
+
+// live returns the node with id unless it is missing or in the recycle bin.
+func (s *Server) live(id string) *node {
+	n, ok := s.nodes[id]
+	if !ok || n.deleted {
+		return nil
+	}
+	return n
+}
+
+func (s *Server) liveDir(id string) *node {
+	if n := s.live(id); n != nil && n.isDir {
+		return n
+	}
+	return nil
+}
+
+func (s *Server) children(dirID string) []*node {
+	var nodes []*node
+	for _, n := range s.nodes {
+		if n.parentID == dirID && n.id != RootID && !n.deleted {
+			nodes = append(nodes, n)
+		}
+	}
+	sortNodes(nodes, sortOptions{asc: true})
+	return nodes
+}
+
+func (s *Server) childByName(dirID, name string) *node {
+	for _, n := range s.children(dirID) {
+		if n.name == name {
+			return n
+		}
+	}
+	return nil
+}
+
+// descendants returns n and every node below it.
+func (s *Server) descendants(n *node) []*node {
+	nodes := []*node{n}
+	for i := 0; i < len(nodes); i++ {
+		if !nodes[i].isDir {
+			continue
+		}
+		for _, c := range s.nodes {
+			if c.parentID == nodes[i].id && c.id != RootID {
+				nodes = append(nodes, c)
+			}
+		}
+	}
+	return nodes
+}
+
+// isAncestor reports whether dir is id or one of its ancestors.
+func (s *Server) isAncestor(dir *node, id string) bool {
+	for n := s.nodes[id]; n != nil; n = s.nodes[n.parentID] {
+		if n.id == dir.id {
+			return true
+		}
+		if n.id == RootID {
+			break
+		}
+	}
+	return false
+}
+
+// parents returns the ancestors of n from the root down to its parent.
+func (s *Server) parents(n *node) []*node {
+	var nodes []*node
+	for p := s.nodes[n.parentID]; p != nil && n.id != RootID; p = s.nodes[p.parentID] {
+		nodes = append([]*node{p}, nodes...)
+		if p.id == RootID {
+			break
+		}
+	}
+	return nodes
+}
+
+func splitPath(p string) []string {
+	var names []string
+	for _, name := range strings.Split(path.Clean("/"+p), "/") {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func pickCode(id string) string {
+	sum := sha1.Sum([]byte("pickcode:" + id))
+	return "pc" + hex.EncodeToString(sum[:9])
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes a failed response of the webapi family.
+func writeError(w http.ResponseWriter, errno int, msg string) {
+	writeJSON(w, map[string]any{"state": false, "errno": errno, "error": msg})
+}
+
+func writeOK(w http.ResponseWriter) {
+	writeJSON(w, map[string]any{"state": true, "errno": 0, "error": ""})
+}
+
+// indexedForm returns the values of the form keys name[0], name[1]...
+func indexedForm(r *http.Request, name string) []string {
+	var values []string
+	for i := 0; ; i++ {
+		v, ok := r.PostForm[name+"["+strconv.Itoa(i)+"]"]
+		if !ok {
+			return values
+		}
+		values = append(values, v...)
+	}
+}
@@ -0,0 +1,274 @@
+package fake115_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/SheltonZhu/115driver/pkg/driver"
+	"github.com/SheltonZhu/115driver/pkg/driver/fake115"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newClient(t *testing.T) (*fake115.Server, *driver.Pan115Client) {
+	srv := fake115.NewServer()
+	t.Cleanup(srv.Close)
+	c := driver.New(driver.UA(),
+		driver.WithBaseHost(srv.URL),
+		driver.WithHost(driver.OSSEndpoint, srv.OSSURL),
+		driver.WithM115PublicKey(srv.PublicKey()),
+		driver.WithEC115PublicKey(srv.EC115PublicKey()),
+	)
+	return srv, c
+}
+
+func names(files *[]driver.File) []string {
+	var ns []string
+	for _, f := range *files {
+		ns = append(ns, f.Name)
+	}
+	return ns
+}
+
+func TestLogin(t *testing.T) {
+	_, c := newClient(t)
+
+	require.NoError(t, c.LoginCheck())
+	assert.Equal(t, int64(fake115.DefaultUserID), c.UserID)
+	require.NoError(t, c.CookieCheck())
+
+	user, err := c.GetUser()
+	require.NoError(t, err)
+	assert.Equal(t, fake115.DefaultUserName, user.UserName)
+
+	info, err := c.GetInfo()
+	require.NoError(t, err)
+	assert.Positive(t, info.SpaceInfo.AllTotal.Size)
+}
+
+func TestDirectoryOperations(t *testing.T) {
+	srv, c := newClient(t)
+
+	dirID, err := c.Mkdir("0", "docs")
+	require.NoError(t, err)
+	_, err = c.Mkdir("0", "docs")
+	assert.ErrorIs(t, err, driver.ErrExist)
+
+	sub := srv.AddDir(dirID, "sub")
+	for i := 0; i < 30; i++ {
+		srv.AddFile(dirID, "file"+string(rune('a'+i%26))+string(rune('a'+i/26)), []byte("x"))
+	}
+
+	files, err := c.ListWithLimit(dirID, 7)
+	require.NoError(t, err)
+	require.Len(t, *files, 31)
+	assert.True(t, (*files)[0].IsDirectory, "directories are listed first")
+	assert.Equal(t, sub.ID, (*files)[0].FileID)
+	assert.Equal(t, dirID, (*files)[1].ParentID)
+
+	got, err := c.DirName2CID("docs/sub")
+	require.NoError(t, err)
+	assert.Equal(t, sub.ID, string(got.CategoryID))
+	got, err = c.DirName2CID("docs/missing")
+	require.NoError(t, err)
+	assert.Equal(t, "0", string(got.CategoryID))
+}
+
+func TestListOptions(t *testing.T) {
+	srv, c := newClient(t)
+	srv.AddDir("0", "dir")
+	for _, name := range []string{"ep2.mp4", "ep10.mp4", "notes.txt"} {
+		srv.AddFile("0", name, []byte(name))
+	}
+
+	files, err := c.List("0", driver.WithListOrder(driver.FileOrderByName), driver.WithListAsc(false))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dir", "notes.txt", "ep2.mp4", "ep10.mp4"}, names(files))
+
+	files, err = c.List("0", driver.WithListOrder(driver.FileOrderByName), driver.WithListNatSort(true),
+		driver.WithListMixed(true))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dir", "ep2.mp4", "ep10.mp4", "notes.txt"}, names(files))
+
+	files, err = c.List("0", driver.WithListSuffix("mp4"))
+	require.NoError(t, err)
+	assert.Len(t, *files, 2)
+	files, err = c.List("0", driver.WithListFileType(driver.FileTypeDocument))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"notes.txt"}, names(files))
+	files, err = c.List("0", driver.WithListStarred())
+	require.NoError(t, err)
+	assert.Empty(t, *files)
+}
+
+func TestFileOperations(t *testing.T) {
+	srv, c := newClient(t)
+	a := srv.MkdirAll("a")
+	b := srv.MkdirAll("a/b")
+	f := srv.AddFile(a.ID, "hello.txt", []byte("hello"))
+
+	require.NoError(t, c.Rename(f.ID, "hi.txt"))
+	require.NoError(t, c.Copy(b.ID, f.ID))
+	require.NoError(t, c.Move("0", f.ID))
+	assert.ErrorIs(t, c.Move(b.ID, a.ID), driver.ErrCyclicMove)
+	assert.ErrorIs(t, c.Copy(b.ID, a.ID), driver.ErrCyclicCopy)
+
+	files, err := c.List("0")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "hi.txt"}, names(files))
+	files, err = c.List(b.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"hi.txt"}, names(files))
+
+	stat, err := c.Stat(b.ID)
+	require.NoError(t, err)
+	assert.True(t, stat.IsDirectory)
+	assert.Equal(t, 1, stat.FileCount)
+	require.Len(t, stat.Parents, 2)
+	assert.Equal(t, a.ID, stat.Parents[1].ID)
+
+	file, err := c.GetFile(f.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "hi.txt", file.Name)
+	assert.Equal(t, int64(5), file.Size)
+	assert.Equal(t, f.SHA1, file.Sha1)
+
+	result, err := c.Search(&driver.SearchOption{SearchValue: "hi", Asc: 1})
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Count)
+}
+
+func TestRecycleBin(t *testing.T) {
+	srv, c := newClient(t)
+	dir := srv.MkdirAll("trash")
+	srv.AddFile(dir.ID, "a.txt", []byte("a"))
+	f := srv.AddFile("0", "b.txt", []byte("b"))
+
+	require.NoError(t, c.Delete(dir.ID, f.ID))
+	files, err := c.List("0")
+	require.NoError(t, err)
+	assert.Empty(t, *files)
+
+	items, err := c.ListRecycleBin(0, 10)
+	require.NoError(t, err)
+	require.Len(t, items, 2)
+
+	require.NoError(t, c.RevertRecycleBin(dir.ID))
+	files, err = c.List(dir.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.txt"}, names(files))
+
+	require.NoError(t, c.CleanRecycleBin(""))
+	items, err = c.ListRecycleBin(0, 10)
+	require.NoError(t, err)
+	assert.Empty(t, items)
+	_, ok := srv.Get(f.ID)
+	assert.False(t, ok)
+}
+
+func TestDownload(t *testing.T) {
+	srv, c := newClient(t)
+	f := srv.AddFile("0", "movie.mp4", []byte("0123456789"))
+
+	info, err := c.Download(f.PickCode)
+	require.NoError(t, err)
+	assert.Equal(t, "movie.mp4", info.FileName)
+	assert.Equal(t, int64(10), int64(info.FileSize))
+
+	r, err := info.Get()
+	require.NoError(t, err)
+	content, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789", string(content))
+
+	info, err = c.DownloadWithUAByAndroidAPI(f.PickCode, "")
+	require.NoError(t, err)
+	assert.Contains(t, info.Url.Url, f.PickCode)
+
+	srv.ExpireDownloadURLs()
+	resp, err := http.Get(info.Url.Url)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	_, err = c.Download("missing")
+	assert.ErrorIs(t, err, driver.ErrPickCodeNotExist)
+}
+
+func TestOfflineTasks(t *testing.T) {
+	srv, c := newClient(t)
+	dir := srv.MkdirAll("offline")
+
+	hashes, err := c.AddOfflineTaskURIs([]string{"https://example.com/a.iso", "https://example.com/b.iso"}, dir.ID)
+	require.NoError(t, err)
+	require.Len(t, hashes, 2)
+	_, err = c.AddOfflineTaskURIs([]string{"https://example.com/a.iso"}, dir.ID)
+	assert.ErrorIs(t, err, driver.ErrOfflineTaskExisted)
+	_, err = c.AddOfflineTaskURIs([]string{"file:///etc/passwd"}, dir.ID)
+	assert.ErrorIs(t, err, driver.ErrOfflineInvalidLink)
+
+	file, ok := srv.FinishOfflineTask(hashes[0], []byte("iso"))
+	require.True(t, ok)
+	tasks, err := c.ListOfflineTask(1)
+	require.NoError(t, err)
+	require.Len(t, tasks.Tasks, 2)
+	assert.True(t, tasks.Tasks[0].IsDone())
+	assert.Equal(t, file.ID, tasks.Tasks[0].FileId)
+
+	require.NoError(t, c.DeleteOfflineTasks(hashes[1:], false))
+	require.NoError(t, c.ClearOfflineTasks(0))
+	tasks, err = c.ListOfflineTask(1)
+	require.NoError(t, err)
+	assert.Empty(t, tasks.Tasks)
+}
+
+func TestQRCodeLogin(t *testing.T) {
+	srv, c := newClient(t)
+
+	session, err := c.QRCodeStart()
+	require.NoError(t, err)
+	status, err := c.QRCodeStatus(session)
+	require.NoError(t, err)
+	assert.True(t, status.IsWaiting())
+
+	_, err = c.QRCodeLogin(session)
+	assert.ErrorIs(t, err, driver.ErrFailedToLogin)
+
+	require.True(t, srv.SetQRCodeStatus(session.UID, fake115.QRCodeAllowed))
+	status, err = c.QRCodeStatus(session)
+	require.NoError(t, err)
+	assert.True(t, status.IsAllowed())
+
+	cr, err := c.QRCodeLogin(session)
+	require.NoError(t, err)
+	assert.NotEmpty(t, cr.UID)
+	assert.NotEmpty(t, cr.SEID)
+}
+
+func TestUpload(t *testing.T) {
+	srv, c := newClient(t)
+	dir := srv.MkdirAll("docs")
+
+	content := []byte("a file uploaded through OSS")
+	require.NoError(t, c.RapidUploadOrByOSS(dir.ID, "a.txt", int64(len(content)), bytes.NewReader(content)))
+	// the same content is rapid uploaded
+	require.NoError(t, c.RapidUploadOrByOSS(fake115.RootID, "b.txt", int64(len(content)), bytes.NewReader(content)))
+
+	files, err := c.List(dir.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.txt"}, names(files))
+	files, err = c.List(fake115.RootID)
+	require.NoError(t, err)
+	assert.Contains(t, names(files), "b.txt")
+	for _, n := range srv.Children(fake115.RootID) {
+		if n.Name == "b.txt" {
+			got, _ := srv.Content(n.ID)
+			assert.Equal(t, content, got)
+		}
+	}
+
+	_, err = c.RapidUpload(int64(len(content)), "c.txt", "404", "", "", bytes.NewReader(content))
+	assert.Error(t, err, "the target dir does not exist")
+}
@@ -0,0 +1,101 @@
+package fake115
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/SheltonZhu/115driver/pkg/crypto/ec115"
+)
+
+const (
+	// userKey is the key the upload signatures are made with.
+	userKey = "fake115userkey"
+	// uploadSizeLimit is the largest file the upload info API allows.
+	uploadSizeLimit = 115 << 30
+)
+
+// errnoUploadTargetNotExist is the statuscode of the upload init API when
+// the target dir does not exist.
+const errnoUploadTargetNotExist = 990009
+
+func (s *Server) handleUploadInfo(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, map[string]any{
+		"state":          true,
+		"user_id":        s.UserID(),
+		"userkey":        userKey,
+		"size_limit":     uploadSizeLimit,
+		"upload_allowed": true,
+	})
+}
+
+// handleUploadInit serves the ECDH encrypted upload init API: files whose
+// SHA1 is already stored are rapid uploaded, the others are handed the OSS
+// parameters to upload them with, see UploadParams.
+func (s *Server) handleUploadInit(w http.ResponseWriter, r *http.Request) {
+	c, err := ec115.NewServerCipher(s.ecKey, r.URL.Query().Get("k_ec"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	data, err := c.Decrypt(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	form, err := url.ParseQuery(string(data))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	sha1 := strings.ToUpper(form.Get("fileid"))
+	name := form.Get("filename")
+	dirID := strings.TrimPrefix(form.Get("target"), "U_1_")
+
+	s.mu.Lock()
+	var result map[string]any
+	src := s.bySHA1(sha1)
+	switch {
+	case s.liveDir(dirID) == nil:
+		result = map[string]any{"status": 0, "statuscode": errnoUploadTargetNotExist, "statusmsg": "target dir missing"}
+	case src != nil:
+		n := s.addNode(dirID, name, false, append([]byte(nil), src.content...))
+		result = map[string]any{"status": 2, "statuscode": 0, "pickcode": n.pickCode, "target": form.Get("target")}
+	default:
+		p := s.uploadParams(dirID, name, sha1)
+		result = map[string]any{
+			"status":     1,
+			"statuscode": 0,
+			"target":     form.Get("target"),
+			"bucket":     p.Bucket,
+			"object":     p.Object,
+			"callback":   map[string]any{"callback": p.Callback, "callback_var": p.CallbackVar},
+		}
+	}
+	s.mu.Unlock()
+
+	data, _ = json.Marshal(result)
+	encrypted, err := c.Encrypt(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_, _ = w.Write(encrypted)
+}
+
+// bySHA1 returns a live file with the content of sha1.
+func (s *Server) bySHA1(sha1 string) *node {
+	for _, n := range s.nodes {
+		if n.sha1 == sha1 && !n.deleted && !n.isDir {
+			return n
+		}
+	}
+	return nil
+}
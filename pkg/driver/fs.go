@@ -0,0 +1,270 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileSystem is a read-only fs.FS of a directory of the cloud storage, see FS.
+type FileSystem struct {
+	c      *Pan115Client
+	ctx    context.Context
+	rootID string
+
+	once     sync.Once
+	rootPath string
+	rootErr  error
+}
+
+var (
+	_ fs.ReadDirFS  = (*FileSystem)(nil)
+	_ fs.StatFS     = (*FileSystem)(nil)
+	_ fs.ReadFileFS = (*FileSystem)(nil)
+)
+
+// FS returns the directory rootID as an fs.FS, which also implements
+// fs.ReadDirFS, fs.StatFS and fs.ReadFileFS, so it can be used with
+// fs.WalkDir, http.FS or template.ParseFS. The files it opens stream their
+// content from the download URL and implement io.Seeker.
+func FS(c *Pan115Client, rootID string) *FileSystem {
+	return FSCtx(context.Background(), c, rootID)
+}
+
+// FSCtx is like FS but uses ctx for the requests.
+func FSCtx(ctx context.Context, c *Pan115Client, rootID string) *FileSystem {
+	return &FileSystem{c: c, ctx: ctx, rootID: rootID}
+}
+
+// Open implements fs.FS.
+func (fsys *FileSystem) Open(name string) (fs.File, error) {
+	f, err := fsys.stat("open", name)
+	if err != nil {
+		return nil, err
+	}
+	info := fileInfo{name: path.Base(name), f: f}
+	if f.IsDir() {
+		return &dirFile{fsys: fsys, name: name, info: info}, nil
+	}
+	return &file{fsys: fsys, name: name, info: info}, nil
+}
+
+// Stat implements fs.StatFS.
+func (fsys *FileSystem) Stat(name string) (fs.FileInfo, error) {
+	f, err := fsys.stat("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return fileInfo{name: path.Base(name), f: f}, nil
+}
+
+// ReadDir implements fs.ReadDirFS, the entries are sorted by name.
+func (fsys *FileSystem) ReadDir(name string) ([]fs.DirEntry, error) {
+	f, err := fsys.stat("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if !f.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errNotDir}
+	}
+	return fsys.readDir(name, f.FileID)
+}
+
+// ReadFile implements fs.ReadFileFS.
+func (fsys *FileSystem) ReadFile(name string) ([]byte, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if d, ok := f.(*dirFile); ok {
+		return nil, &fs.PathError{Op: "read", Path: d.name, Err: errIsDir}
+	}
+	data := make([]byte, 0, f.(*file).info.Size())
+	for {
+		n, err := f.Read(data[len(data):cap(data)])
+		data = data[:len(data)+n]
+		if err == io.EOF {
+			return data, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(data) == cap(data) {
+			data = append(data, 0)[:len(data)]
+		}
+	}
+}
+
+var (
+	errIsDir  = errors.New("is a directory")
+	errNotDir = errors.New("not a directory")
+)
+
+// abs returns the path of name from the root of the account.
+func (fsys *FileSystem) abs(name string) (string, error) {
+	fsys.once.Do(func() {
+		fsys.rootPath, fsys.rootErr = fsys.c.dirPath(fsys.ctx, fsys.rootID)
+	})
+	if fsys.rootErr != nil {
+		return "", fsys.rootErr
+	}
+	return path.Join(fsys.rootPath, name), nil
+}
+
+func (fsys *FileSystem) stat(op, name string) (*File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	if name == "." {
+		return &File{IsDirectory: true, FileID: fsys.rootID, Name: "."}, nil
+	}
+	p, err := fsys.abs(name)
+	if err != nil {
+		return nil, pathError(op, name, err)
+	}
+	f, err := fsys.c.StatPathCtx(fsys.ctx, p)
+	if err != nil {
+		return nil, pathError(op, name, err)
+	}
+	return f, nil
+}
+
+func (fsys *FileSystem) readDir(name, dirID string) ([]fs.DirEntry, error) {
+	p, err := fsys.abs(name)
+	if err != nil {
+		return nil, pathError("readdir", name, err)
+	}
+	var entries []fs.DirEntry
+	for f, err := range fsys.c.ListIterCtx(fsys.ctx, dirID) {
+		if err != nil {
+			return nil, pathError("readdir", name, err)
+		}
+		fsys.c.paths.put(pathEntry{path: path.Join(p, f.Name), id: f.FileID, isDir: f.IsDirectory})
+		entries = append(entries, fs.FileInfoToDirEntry(fileInfo{name: f.Name, f: &f}))
+	}
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+	return entries, nil
+}
+
+func pathError(op, name string, err error) error {
+	if errors.Is(err, ErrNotExist) {
+		err = fs.ErrNotExist
+	}
+	return &fs.PathError{Op: op, Path: name, Err: err}
+}
+
+// fileInfo implements fs.FileInfo, Sys returns the *File.
+type fileInfo struct {
+	name string
+	f    *File
+}
+
+func (fi fileInfo) Name() string       { return fi.name }
+func (fi fileInfo) Size() int64        { return fi.f.GetSize() }
+func (fi fileInfo) ModTime() time.Time { return fi.f.ModTime() }
+func (fi fileInfo) IsDir() bool        { return fi.f.IsDir() }
+func (fi fileInfo) Sys() any           { return fi.f }
+
+func (fi fileInfo) Mode() fs.FileMode {
+	if fi.f.IsDir() {
+		return fs.ModeDir | 0o555
+	}
+	return 0o444
+}
+
+// file is a regular file opened by a FileSystem.
+type file struct {
+	fsys *FileSystem
+	name string
+	info fileInfo
+	r    *DownloadReader
+}
+
+func (f *file) Stat() (fs.FileInfo, error) { return f.info, nil }
+
+func (f *file) reader() (*DownloadReader, error) {
+	if f.r == nil {
+		r, err := f.fsys.c.OpenFileCtx(f.fsys.ctx, f.info.f.PickCode)
+		if err != nil {
+			return nil, pathError("open", f.name, err)
+		}
+		f.r = r
+	}
+	return f.r, nil
+}
+
+func (f *file) Read(p []byte) (int, error) {
+	if f.info.Size() == 0 {
+		return 0, io.EOF
+	}
+	r, err := f.reader()
+	if err != nil {
+		return 0, err
+	}
+	return r.Read(p)
+}
+
+// Seek implements io.Seeker.
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	r, err := f.reader()
+	if err != nil {
+		return 0, err
+	}
+	return r.Seek(offset, whence)
+}
+
+func (f *file) Close() error {
+	if f.r != nil {
+		return f.r.Close()
+	}
+	return nil
+}
+
+// dirFile is a directory opened by a FileSystem.
+type dirFile struct {
+	fsys    *FileSystem
+	name    string
+	info    fileInfo
+	entries []fs.DirEntry
+	loaded  bool
+	off     int
+}
+
+func (d *dirFile) Stat() (fs.FileInfo, error) { return d.info, nil }
+
+func (d *dirFile) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: errIsDir}
+}
+
+func (d *dirFile) Close() error { return nil }
+
+// ReadDir implements fs.ReadDirFile.
+func (d *dirFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !d.loaded {
+		entries, err := d.fsys.readDir(d.name, d.info.f.FileID)
+		if err != nil {
+			return nil, err
+		}
+		d.entries, d.loaded = entries, true
+	}
+	rest := d.entries[d.off:]
+	if n <= 0 {
+		d.off = len(d.entries)
+		return rest, nil
+	}
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+	rest = rest[:min(n, len(rest))]
+	d.off += len(rest)
+	return rest, nil
+}
@@ -0,0 +1,95 @@
+package driver
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+	"text/template"
+
+	"github.com/SheltonZhu/115driver/pkg/driver/fake115"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newFSTestClient(t *testing.T) (*fake115.Server, *Pan115Client, fake115.File) {
+	srv := fake115.NewServer()
+	t.Cleanup(srv.Close)
+	site := srv.MkdirAll("site")
+	srv.AddFile(site.ID, "index.html", []byte("<h1>{{.}}</h1>"))
+	srv.AddFile(site.ID, "empty.txt", nil)
+	assets := srv.MkdirAll("site/assets")
+	srv.AddFile(assets.ID, "app.js", bytes.Repeat([]byte("0123456789"), 100))
+	return srv, New(UA(), WithBaseHost(srv.URL), WithM115PublicKey(srv.PublicKey())), site
+}
+
+func TestFS(t *testing.T) {
+	_, c, site := newFSTestClient(t)
+	require.NoError(t, fstest.TestFS(FS(c, site.ID), "index.html", "empty.txt", "assets/app.js"))
+	require.NoError(t, fstest.TestFS(FS(c, "0"), "site/index.html", "site/assets/app.js"))
+}
+
+func TestFSWalkDirAndReadFile(t *testing.T) {
+	_, c, site := newFSTestClient(t)
+	fsys := FS(c, site.ID)
+
+	var paths []string
+	require.NoError(t, fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		paths = append(paths, p)
+		return err
+	}))
+	assert.Equal(t, []string{".", "assets", "assets/app.js", "empty.txt", "index.html"}, paths)
+
+	data, err := fs.ReadFile(fsys, "assets/app.js")
+	require.NoError(t, err)
+	assert.Len(t, data, 1000)
+
+	_, err = fsys.Open("missing.txt")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+	_, err = fsys.ReadFile("assets")
+	assert.Error(t, err)
+	_, err = fsys.Stat("/index.html")
+	assert.ErrorIs(t, err, fs.ErrInvalid)
+}
+
+func TestFSSeek(t *testing.T) {
+	_, c, site := newFSTestClient(t)
+	f, err := FS(c, site.ID).Open("assets/app.js")
+	require.NoError(t, err)
+	defer f.Close()
+
+	s := f.(io.ReadSeeker)
+	_, err = s.Seek(995, io.SeekStart)
+	require.NoError(t, err)
+	rest, err := io.ReadAll(s)
+	require.NoError(t, err)
+	assert.Equal(t, "56789", string(rest))
+
+	_, err = s.Seek(-12, io.SeekEnd)
+	require.NoError(t, err)
+	buf := make([]byte, 4)
+	_, err = io.ReadFull(s, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "8901", string(buf))
+}
+
+func TestFSHTTPAndTemplates(t *testing.T) {
+	_, c, site := newFSTestClient(t)
+	fsys := FS(c, site.ID)
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/assets/app.js", nil)
+	req.Header.Set("Range", "bytes=10-19")
+	http.FileServer(http.FS(fsys)).ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusPartialContent, rec.Code)
+	assert.Equal(t, "0123456789", rec.Body.String())
+
+	tmpl, err := template.ParseFS(fsys, "*.html")
+	require.NoError(t, err)
+	var out bytes.Buffer
+	require.NoError(t, tmpl.Execute(&out, "hello"))
+	assert.Equal(t, "<h1>hello</h1>", out.String())
+}
@@ -1,6 +1,7 @@
 package driver
 
 import (
+	"context"
 	"encoding/json"
 	"math/big"
 	"strconv"
@@ -9,12 +10,17 @@ import (
 
 // GetInfo get space info and login device info.
 func (c *Pan115Client) GetInfo() (InfoData, error) {
+	return c.GetInfoCtx(context.Background())
+}
+
+// GetInfoCtx is like GetInfo but uses ctx for the request.
+func (c *Pan115Client) GetInfoCtx(ctx context.Context) (InfoData, error) {
 	result := InfoResponse{}
-	req := c.NewRequest().
+	req := c.newRequest(ctx).
 		SetResult(&result).
 		ForceContentType("application/json;charset=UTF-8")
 
-	resp, err := req.Get(ApiFileIndexInfo)
+	resp, err := c.get(req, c.endpoints().FileIndexInfo)
 
 	if err = CheckErr(err, &result, resp); err != nil {
 		return InfoData{}, err
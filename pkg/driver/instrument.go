@@ -0,0 +1,172 @@
+package driver
+
+import (
+	"context"
+	"time"
+)
+
+// Instrumentation observes the API calls, uploads and downloads of a client,
+// to record traces and metrics, see WithInstrumentation. The context returned
+// by the Start methods is passed to the matching Finish method and to the
+// calls made in between, so it can carry a span.
+//
+// Implementations must be safe for concurrent use. Embed NopInstrumentation
+// to implement only some of the methods.
+type Instrumentation interface {
+	// RequestStart is called before an API call is sent.
+	RequestStart(ctx context.Context, ev *RequestEvent) context.Context
+	// RequestFinish is called after the last attempt of an API call.
+	RequestFinish(ctx context.Context, ev *RequestEvent)
+	// UploadPhaseStart is called when an upload enters a phase.
+	UploadPhaseStart(ctx context.Context, ev *UploadEvent) context.Context
+	// UploadPhaseFinish is called when an upload phase ends.
+	UploadPhaseFinish(ctx context.Context, ev *UploadEvent)
+	// PartUploaded is called after every part of a multipart upload.
+	PartUploaded(ctx context.Context, ev *PartEvent)
+	// DownloadURLResolved is called when the download URL of a file is fetched.
+	DownloadURLResolved(ctx context.Context, ev *DownloadEvent)
+}
+
+// NopInstrumentation is an Instrumentation which does nothing.
+type NopInstrumentation struct{}
+
+func (NopInstrumentation) RequestStart(ctx context.Context, _ *RequestEvent) context.Context {
+	return ctx
+}
+
+func (NopInstrumentation) RequestFinish(context.Context, *RequestEvent) {}
+
+func (NopInstrumentation) UploadPhaseStart(ctx context.Context, _ *UploadEvent) context.Context {
+	return ctx
+}
+
+func (NopInstrumentation) UploadPhaseFinish(context.Context, *UploadEvent) {}
+
+func (NopInstrumentation) PartUploaded(context.Context, *PartEvent) {}
+
+func (NopInstrumentation) DownloadURLResolved(context.Context, *DownloadEvent) {}
+
+// RequestEvent describes an API call. The fields after Start are set when it
+// finishes.
+type RequestEvent struct {
+	// Endpoint is the name of the Endpoints field of the call, e.g. "FileList",
+	// or the URL path for unknown URLs.
+	Endpoint string
+	// Method is the HTTP method.
+	Method string
+	// URL is the endpoint URL, with its signing parameters redacted.
+	URL   string
+	Start time.Time
+
+	Duration time.Duration
+	// Attempts is the number of attempts made, more than one when retried.
+	Attempts int
+	// StatusCode is the HTTP status of the last response, 0 when none was received.
+	StatusCode int
+	// Errno is the 115 error code of a failed call.
+	Errno int
+	// Bytes is the size of the response body.
+	Bytes int64
+	Err   error
+}
+
+// UploadPhase is a step of an upload.
+type UploadPhase string
+
+const (
+	// UploadPhaseHashing computes the digests of the file, it is only
+	// reported by UploadProgress.
+	UploadPhaseHashing UploadPhase = "hashing"
+	// UploadPhaseRapid checks whether the file is already stored by 115.
+	UploadPhaseRapid UploadPhase = "rapid"
+	// UploadPhaseOSS uploads the file in a single request.
+	UploadPhaseOSS UploadPhase = "oss"
+	// UploadPhaseMultipart uploads the file in parts.
+	UploadPhaseMultipart UploadPhase = "multipart"
+	// UploadPhaseVerify checks that 115 created the file once OSS stored it,
+	// it is only reported by UploadProgress.
+	UploadPhaseVerify UploadPhase = "verify"
+)
+
+// UploadEvent describes a phase of an upload. Duration and Err are set when
+// it finishes.
+type UploadEvent struct {
+	Phase UploadPhase
+	// FileName is the name of the file, set for the rapid phase.
+	FileName string
+	// Object is the OSS object key, set for the oss and multipart phases.
+	Object string
+	// FileSize is the size of the file, -1 when unknown.
+	FileSize int64
+	DirID    string
+	// Rapid reports whether the rapid upload phase stored the file.
+	Rapid bool
+	Start time.Time
+
+	Duration time.Duration
+	Err      error
+}
+
+// PartEvent describes a part of a multipart upload.
+type PartEvent struct {
+	PartNumber int
+	Size       int64
+	// Attempts is the number of attempts made to upload the part.
+	Attempts int
+	Duration time.Duration
+	Err      error
+}
+
+// DownloadEvent describes the resolution of a download URL.
+type DownloadEvent struct {
+	PickCode string
+	// API is the API which resolved the URL, "web" or "android".
+	API string
+	// URL is the download URL, with its signing parameters redacted.
+	URL string
+	// FileSize is the size of the file, 0 when not reported by the API.
+	FileSize int64
+}
+
+// observed reports whether the API calls are logged or instrumented.
+func (c *Pan115Client) observed() bool {
+	return c.logger != nil || c.instrumentation != nil
+}
+
+// uploadPhase runs fn as the phase ev of an upload.
+func (c *Pan115Client) uploadPhase(ctx context.Context, ev *UploadEvent, fn func(ctx context.Context) error) error {
+	inst := c.instrumentation
+	if inst == nil {
+		return fn(ctx)
+	}
+	ev.Start = time.Now()
+	ctx = inst.UploadPhaseStart(ctx, ev)
+	err := fn(ctx)
+	ev.Duration = time.Since(ev.Start)
+	ev.Err = err
+	inst.UploadPhaseFinish(ctx, ev)
+	return err
+}
+
+func (c *Pan115Client) partUploaded(ctx context.Context, ev *PartEvent) {
+	if c.instrumentation != nil {
+		c.instrumentation.PartUploaded(ctx, ev)
+	}
+}
+
+// downloadResolved reports the resolution of a download URL.
+func (c *Pan115Client) downloadResolved(ctx context.Context, api, pickCode string, info *DownloadInfo) {
+	if !c.observed() {
+		return
+	}
+	ev := &DownloadEvent{
+		PickCode: pickCode,
+		API:      api,
+		URL:      redactURL(info.Url.Url),
+		FileSize: int64(info.FileSize),
+	}
+	c.logDownloadURL(ctx, ev)
+	if c.instrumentation != nil {
+		c.instrumentation.DownloadURLResolved(ctx, ev)
+	}
+}
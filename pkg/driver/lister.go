@@ -0,0 +1,224 @@
+package driver
+
+import (
+	"context"
+	"iter"
+
+	"github.com/pkg/errors"
+)
+
+// Lister iterates over the entries of a directory, fetching a page from the
+// server whenever the previous one is consumed, so listing a large directory
+// does not hold all of it in memory:
+//
+//	l := client.NewLister(dirID)
+//	defer l.Close()
+//	for l.Next() {
+//		file := l.File()
+//		...
+//	}
+//	if err := l.Err(); err != nil {
+//		...
+//	}
+//
+// With WithListConcurrency, the following pages are fetched ahead in parallel.
+// A Lister is not safe for concurrent use.
+type Lister struct {
+	c           *Pan115Client
+	ctx         context.Context
+	cancel      context.CancelFunc
+	dirID       string
+	limit       int64
+	apiURLs     []string
+	concurrency int
+	fileOpts    []GetFileOptions
+
+	pages     int
+	offset    int64
+	count     int
+	done      bool
+	page      []File
+	pos       int
+	file      File
+	err       error
+	scheduled int64
+	pending   []chan listPage
+}
+
+// listPage is a page of a directory.
+type listPage struct {
+	files  []File
+	offset int64
+	count  int
+	err    error
+}
+
+// NewLister creates a Lister of the directory dirID.
+func (c *Pan115Client) NewLister(dirID string, opts ...ListOption) *Lister {
+	return c.NewListerCtx(context.Background(), dirID, opts...)
+}
+
+// NewListerCtx is like NewLister but uses ctx for the requests.
+func (c *Pan115Client) NewListerCtx(ctx context.Context, dirID string, opts ...ListOption) *Lister {
+	return c.newLister(ctx, dirID, FileListLimit, opts...)
+}
+
+func (c *Pan115Client) newLister(ctx context.Context, dirID string, limit int64, opts ...ListOption) *Lister {
+	if limit > MaxDirPageLimit {
+		limit = MaxDirPageLimit
+	}
+	o := DefaultListOptions()
+	if len(opts) > 0 {
+		for _, opt := range opts {
+			opt(o)
+		}
+	}
+	if o.pageSize > 0 {
+		limit = min(o.pageSize, MaxDirPageLimit)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	return &Lister{
+		c:           c,
+		ctx:         ctx,
+		cancel:      cancel,
+		dirID:       dirID,
+		limit:       limit,
+		apiURLs:     c.listURLs(o),
+		concurrency: o.concurrency,
+		fileOpts:    o.fileOpts,
+	}
+}
+
+// Next advances to the next entry, which is then available through File. It
+// returns false at the end of the directory or on error, see Err.
+func (l *Lister) Next() bool {
+	for l.pos >= len(l.page) {
+		if l.done || l.err != nil {
+			return false
+		}
+		l.fetch()
+	}
+	l.file = l.page[l.pos]
+	l.pos++
+	return true
+}
+
+// fetch fetches the next page, or waits for it when it is prefetched.
+func (l *Lister) fetch() {
+	var p listPage
+	if l.concurrency > 1 && l.pages > 0 {
+		for len(l.pending) < l.concurrency && l.scheduled < int64(l.count) {
+			l.pending = append(l.pending, l.prefetch(l.scheduled, l.pages+len(l.pending)))
+			l.scheduled += l.limit
+		}
+		p = <-l.pending[0]
+		l.pending = l.pending[1:]
+		if p.err == nil && p.count != l.count {
+			p.err = errors.Wrapf(ErrDirChanged, "%s had %d entries, now %d", l.dirID, l.count, p.count)
+		}
+	} else {
+		p = l.getPage(l.offset, l.pages)
+	}
+	if p.err != nil {
+		l.err = p.err
+		l.Close()
+		return
+	}
+	l.pages++
+	l.count = p.count
+	l.page = p.files
+	l.pos = 0
+	l.offset = p.offset + l.limit
+	if l.pages == 1 {
+		l.scheduled = l.offset
+	}
+	if l.offset >= int64(p.count) {
+		l.done = true
+		l.Close()
+	}
+}
+
+// getPage fetches the page at offset, the n-th one, rotating over the list URLs.
+func (l *Lister) getPage(offset int64, n int) listPage {
+	opts := append(l.fileOpts[:len(l.fileOpts):len(l.fileOpts)],
+		WithApiURL(l.apiURLs[n%len(l.apiURLs)]),
+		WithLimit(l.limit),
+		WithOffset(offset),
+	)
+	result, err := l.c.getFiles(l.ctx, l.dirID, opts...)
+	if err != nil {
+		return listPage{err: err}
+	}
+	p := listPage{
+		files:  make([]File, 0, len(result.Files)),
+		offset: int64(result.Offset),
+		count:  result.Count,
+	}
+	for _, fileInfo := range result.Files {
+		p.files = append(p.files, *(&File{}).from(&fileInfo))
+	}
+	return p
+}
+
+func (l *Lister) prefetch(offset int64, n int) chan listPage {
+	ch := make(chan listPage, 1)
+	go func() {
+		ch <- l.getPage(offset, n)
+	}()
+	return ch
+}
+
+// File returns the current entry.
+func (l *Lister) File() File {
+	return l.file
+}
+
+// Err returns the error which stopped the iteration, if any.
+func (l *Lister) Err() error {
+	return l.err
+}
+
+// Count returns the number of entries of the directory reported by the
+// server, fetching the first page if needed.
+func (l *Lister) Count() (int, error) {
+	if l.pages == 0 && l.err == nil {
+		l.fetch()
+	}
+	return l.count, l.err
+}
+
+// Close cancels the pages being prefetched. It is called when the iteration
+// ends, and only needs to be called when stopping early.
+func (l *Lister) Close() {
+	l.cancel()
+}
+
+// All returns an iterator over the remaining entries, which yields the error
+// stopping the iteration, if any, as its last pair. Breaking out of the loop
+// stops fetching pages.
+func (l *Lister) All() iter.Seq2[File, error] {
+	return func(yield func(File, error) bool) {
+		defer l.Close()
+		for l.Next() {
+			if !yield(l.File(), nil) {
+				return
+			}
+		}
+		if l.err != nil {
+			yield(File{}, l.err)
+		}
+	}
+}
+
+// ListIter returns an iterator over the entries of the directory dirID,
+// fetching pages on demand, see Lister.
+func (c *Pan115Client) ListIter(dirID string, opts ...ListOption) iter.Seq2[File, error] {
+	return c.ListIterCtx(context.Background(), dirID, opts...)
+}
+
+// ListIterCtx is like ListIter but uses ctx for the requests.
+func (c *Pan115Client) ListIterCtx(ctx context.Context, dirID string, opts ...ListOption) iter.Seq2[File, error] {
+	return func(yield func(File, error) bool) {
+		c.NewListerCtx(ctx, dirID, opts...).All()(yield)
+	}
+}
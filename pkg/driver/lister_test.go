@@ -0,0 +1,172 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/SheltonZhu/115driver/pkg/driver/fake115"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingInstrumentation counts the API calls per endpoint.
+type countingInstrumentation struct {
+	NopInstrumentation
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func (i *countingInstrumentation) RequestStart(ctx context.Context, ev *RequestEvent) context.Context {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.calls == nil {
+		i.calls = map[string]int{}
+	}
+	i.calls[ev.Endpoint]++
+	return ctx
+}
+
+func (i *countingInstrumentation) count(endpoint string) int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.calls[endpoint]
+}
+
+func newListerTestClient(t *testing.T, files int) (*fake115.Server, *Pan115Client, *countingInstrumentation) {
+	srv := fake115.NewServer()
+	t.Cleanup(srv.Close)
+	for i := 0; i < files; i++ {
+		srv.AddFile(fake115.RootID, fmt.Sprintf("file%03d", i), []byte("x"))
+	}
+	inst := &countingInstrumentation{}
+	return srv, New(UA(), WithBaseHost(srv.URL), WithInstrumentation(inst)), inst
+}
+
+func TestListerPaginates(t *testing.T) {
+	_, c, inst := newListerTestClient(t, 120)
+
+	l := c.NewLister("0")
+	count, err := l.Count()
+	require.NoError(t, err)
+	assert.Equal(t, 120, count)
+
+	var names []string
+	for l.Next() {
+		names = append(names, l.File().Name)
+	}
+	require.NoError(t, l.Err())
+	require.Len(t, names, 120)
+	assert.Equal(t, "file000", names[0])
+	assert.Equal(t, "file119", names[119])
+	assert.Equal(t, 3, inst.count("FileList"), "120 entries in pages of 56")
+	assert.False(t, l.Next())
+}
+
+func TestListIterStopsEarly(t *testing.T) {
+	_, c, inst := newListerTestClient(t, 120)
+
+	n := 0
+	for f, err := range c.ListIter("0") {
+		require.NoError(t, err)
+		assert.NotEmpty(t, f.Name)
+		if n++; n == 10 {
+			break
+		}
+	}
+	assert.Equal(t, 10, n)
+	assert.Equal(t, 1, inst.count("FileList"))
+}
+
+func TestListIterMultiUrls(t *testing.T) {
+	srv, c, inst := newListerTestClient(t, 120)
+	target, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+	proxy := httptest.NewServer(httputil.NewSingleHostReverseProxy(target))
+	defer proxy.Close()
+	c.Endpoints.FileList1 = proxy.URL + "/files"
+
+	n := 0
+	for _, err := range c.ListIter("0", WithMultiUrls()) {
+		require.NoError(t, err)
+		n++
+	}
+	assert.Equal(t, 120, n)
+	assert.Equal(t, 2, inst.count("FileList"))
+	assert.Equal(t, 1, inst.count("FileList1"))
+}
+
+func TestListIterYieldsError(t *testing.T) {
+	srv, _ := failingServer(t, 0, nil)
+	srv.Close()
+	c := New(UA(), WithBaseHost(srv.URL))
+
+	var errs []error
+	for _, err := range c.ListIter("0") {
+		errs = append(errs, err)
+	}
+	require.Len(t, errs, 1)
+	assert.Error(t, errs[0])
+}
+
+// wrapFake serves srv through a handler calling before for every request.
+func wrapFake(t *testing.T, srv *fake115.Server, before func(r *http.Request)) *httptest.Server {
+	wrapper := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		before(r)
+		srv.ServeHTTP(w, r)
+	}))
+	t.Cleanup(wrapper.Close)
+	return wrapper
+}
+
+func TestListConcurrency(t *testing.T) {
+	srv, _, _ := newListerTestClient(t, 120)
+	var inFlight, maxInFlight int32
+	wrapper := wrapFake(t, srv, func(r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			m := atomic.LoadInt32(&maxInFlight)
+			if n <= m || atomic.CompareAndSwapInt32(&maxInFlight, m, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	})
+	inst := &countingInstrumentation{}
+	c := New(UA(), WithBaseHost(wrapper.URL), WithInstrumentation(inst))
+
+	files, err := c.ListWithLimit("0", 10, WithListConcurrency(4))
+	require.NoError(t, err)
+	require.Len(t, *files, 120)
+	for i, f := range *files {
+		assert.Equal(t, fmt.Sprintf("file%03d", i), f.Name)
+	}
+	assert.Equal(t, 12, inst.count("FileList"))
+	assert.Equal(t, int32(4), atomic.LoadInt32(&maxInFlight))
+}
+
+func TestListConcurrencyDetectsChanges(t *testing.T) {
+	srv, _, _ := newListerTestClient(t, 120)
+	var once sync.Once
+	wrapper := wrapFake(t, srv, func(r *http.Request) {
+		if r.URL.Query().Get("offset") != "0" {
+			once.Do(func() { srv.AddFile(fake115.RootID, "new", nil) })
+		}
+	})
+	c := New(UA(), WithBaseHost(wrapper.URL))
+
+	_, err := c.ListWithLimit("0", 10, WithListConcurrency(4))
+	assert.ErrorIs(t, err, ErrDirChanged)
+
+	// sequential listings do not check the count
+	_, err = c.ListWithLimit("0", 10)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,171 @@
+package driver
+
+import (
+	"context"
+	"log/slog"
+	"net/url"
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// redacted replaces secrets in log records.
+const redacted = "REDACTED"
+
+// sensitiveParams are the query parameters of signed and encrypted URLs.
+var sensitiveParams = map[string]bool{
+	"t":                    true,
+	"k":                    true,
+	"s":                    true,
+	"u":                    true,
+	"us":                   true,
+	"sign":                 true,
+	"signature":            true,
+	"token":                true,
+	"expires":              true,
+	"ossaccesskeyid":       true,
+	"security-token":       true,
+	"x-oss-signature":      true,
+	"x-oss-credential":     true,
+	"x-oss-security-token": true,
+	"k_ec":                 true,
+	"userid":               true,
+	"uid":                  true,
+}
+
+// redactURL masks the values of the signing parameters and the user info of
+// rawURL, so it can be logged.
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return redacted
+	}
+	if u.User != nil {
+		u.User = url.User(redacted)
+	}
+	if u.RawQuery != "" {
+		q := u.Query()
+		for k := range q {
+			if sensitiveParams[strings.ToLower(k)] {
+				q[k] = []string{redacted}
+			}
+		}
+		u.RawQuery = q.Encode()
+	}
+	return u.String()
+}
+
+// redactError returns the message of err without the response bodies of the
+// APIErrors it wraps, and with the URLs of the *url.Errors redacted.
+func redactError(err error) string {
+	msg := err.Error()
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		switch e := e.(type) {
+		case *APIError:
+			if e.Body != "" {
+				noBody := &APIError{Message: e.Message, err: e.err}
+				msg = strings.Replace(msg, e.Error(), noBody.Error(), 1)
+			}
+		case *url.Error:
+			msg = strings.ReplaceAll(msg, e.URL, redactURL(e.URL))
+		}
+	}
+	return msg
+}
+
+// LogValue implements slog.LogValuer, so the response body and the signed
+// URL of a failure are never written to the logs.
+func (e *APIError) LogValue() slog.Value {
+	attrs := []slog.Attr{
+		slog.Int("code", e.Code),
+		slog.String("message", e.Message),
+		slog.String("error", e.err.Error()),
+	}
+	if e.Endpoint != "" {
+		attrs = append(attrs, slog.String("endpoint", redactURL(e.Endpoint)))
+	}
+	if e.StatusCode != 0 {
+		attrs = append(attrs, slog.Int("status", e.StatusCode))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// LogValue implements slog.LogValuer, so the cookie values of a credential
+// are never written to the logs.
+func (cr *Credential) LogValue() slog.Value {
+	mask := func(v string) string {
+		if v == "" {
+			return ""
+		}
+		return redacted
+	}
+	return slog.GroupValue(
+		slog.String("UID", mask(cr.UID)),
+		slog.String("CID", mask(cr.CID)),
+		slog.String("SEID", mask(cr.SEID)),
+		slog.String("KID", mask(cr.KID)),
+	)
+}
+
+// endpointName returns the name of the Endpoints field holding rawURL, or
+// its path when it is not a known endpoint.
+func (c *Pan115Client) endpointName(rawURL string) string {
+	v := reflect.ValueOf(c.endpoints()).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if v.Field(i).String() == rawURL {
+			return v.Type().Field(i).Name
+		}
+	}
+	if u, err := url.Parse(rawURL); err == nil {
+		return u.Path
+	}
+	return ""
+}
+
+// logCall writes the record of an API call.
+func (c *Pan115Client) logCall(ctx context.Context, ev *RequestEvent) {
+	if c.logger == nil {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String("endpoint", ev.Endpoint),
+		slog.String("method", ev.Method),
+		slog.String("url", ev.URL),
+		slog.Duration("duration", ev.Duration),
+		slog.Int("retries", ev.Attempts-1),
+		slog.Int("errno", ev.Errno),
+	}
+	if ev.StatusCode != 0 {
+		attrs = append(attrs,
+			slog.Int("status", ev.StatusCode),
+			slog.Int64("bytes", ev.Bytes),
+		)
+	}
+	level := slog.LevelDebug
+	if ev.Err != nil {
+		level = slog.LevelWarn
+		attrs = append(attrs, slog.String("error", redactError(ev.Err)))
+	}
+	c.logger.LogAttrs(ctx, level, "115 api call", attrs...)
+}
+
+// logWarn logs an error which does not fail the call.
+func (c *Pan115Client) logWarn(ctx context.Context, msg string, err error, args ...any) {
+	if c.logger == nil {
+		return
+	}
+	c.logger.WarnContext(ctx, msg, append(args, slog.String("error", redactError(err)))...)
+}
+
+// logDownloadURL logs the resolution of a download URL.
+func (c *Pan115Client) logDownloadURL(ctx context.Context, ev *DownloadEvent) {
+	if c.logger == nil {
+		return
+	}
+	c.logger.DebugContext(ctx, "download url resolved",
+		slog.String("pickcode", ev.PickCode),
+		slog.String("api", ev.API),
+		slog.String("url", ev.URL),
+	)
+}
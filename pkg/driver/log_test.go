@@ -0,0 +1,115 @@
+package driver
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/SheltonZhu/115driver/pkg/driver/fake115"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func records(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	var recs []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		rec := map[string]any{}
+		require.NoError(t, json.Unmarshal([]byte(line), &rec))
+		recs = append(recs, rec)
+	}
+	return recs
+}
+
+func TestLoggerRecordsCalls(t *testing.T) {
+	srv := fake115.NewServer()
+	defer srv.Close()
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	cr := &Credential{UID: "uid-secret", CID: "cid-secret", SEID: "seid-secret", KID: "kid-secret"}
+	c := New(UA(), WithBaseHost(srv.URL), WithM115PublicKey(srv.PublicKey()), WithLogger(logger)).
+		ImportCredential(cr)
+	f := srv.AddFile("0", "a.txt", []byte("a"))
+
+	_, err := c.List("0")
+	require.NoError(t, err)
+	_, err = c.Download(f.PickCode)
+	require.NoError(t, err)
+	_, err = c.Download("missing")
+	require.Error(t, err)
+	logger.Info("credential", "cr", cr)
+
+	out := buf.String()
+	for _, secret := range []string{"uid-secret", "cid-secret", "seid-secret", "kid-secret"} {
+		assert.NotContains(t, out, secret)
+	}
+
+	recs := records(t, &buf)
+	require.Len(t, recs, 5)
+	list := recs[0]
+	assert.Equal(t, "115 api call", list["msg"])
+	assert.Equal(t, "DEBUG", list["level"])
+	assert.Equal(t, "FileList", list["endpoint"])
+	assert.Equal(t, http.MethodGet, list["method"])
+	assert.EqualValues(t, 0, list["errno"])
+	assert.EqualValues(t, 0, list["retries"])
+	assert.Positive(t, list["bytes"])
+	assert.Contains(t, list, "duration")
+
+	assert.Equal(t, "DownloadGetUrl", recs[1]["endpoint"])
+	assert.Equal(t, "download url resolved", recs[2]["msg"])
+	assert.NotContains(t, recs[2]["url"], "?t=1")
+
+	failed := recs[3]
+	assert.Equal(t, "WARN", failed["level"])
+	assert.EqualValues(t, 50003, failed["errno"])
+	assert.Contains(t, failed["error"], ErrPickCodeNotExist.Error())
+}
+
+func TestLoggerUploadEndpointFallback(t *testing.T) {
+	srv, _ := failingServer(t, 0, nil)
+	srv.Close()
+	var buf bytes.Buffer
+	c := New(UA(), WithBaseHost(srv.URL), WithLogger(slog.New(slog.NewJSONHandler(&buf, nil))))
+
+	assert.Equal(t, c.endpoints().OSS, c.GetOSSEndpoint(true))
+	recs := records(t, &buf)
+	require.Len(t, recs, 2)
+	assert.Equal(t, "GetUploadEndpoint", recs[0]["endpoint"])
+	assert.Equal(t, "WARN", recs[0]["level"])
+	assert.Contains(t, recs[1]["msg"], "fallback")
+}
+
+func TestRedactURL(t *testing.T) {
+	got := redactURL("https://cdn.115.com/abc/file.mp4?t=1700000000&u=10001&s=104857600&sign=deadbeef&x=1")
+	assert.NotContains(t, got, "deadbeef")
+	assert.NotContains(t, got, "10001")
+	assert.Contains(t, got, "x=1")
+	assert.Contains(t, got, "https://cdn.115.com/abc/file.mp4?")
+	assert.Equal(t, redacted, redactURL("://bad"))
+}
+
+func TestRedactError(t *testing.T) {
+	apiErr := newAPIError(50003, "", `{"state":false,"secret":"body-secret"}`)
+	err := errors.Wrap(apiErr, "download")
+	got := redactError(err)
+	assert.NotContains(t, got, "body-secret")
+	assert.Equal(t, "download: "+ErrPickCodeNotExist.Error(), got)
+
+	urlErr := &url.Error{Op: "Get", URL: "https://cdn.115.com/a.mp4?sign=deadbeef&x=1", Err: io.EOF}
+	got = redactError(errors.Wrap(urlErr, "open"))
+	assert.NotContains(t, got, "deadbeef")
+	assert.Contains(t, got, "x=1")
+
+	var buf bytes.Buffer
+	apiErr.Endpoint = "https://webapi.115.com/files?sign=deadbeef"
+	slog.New(slog.NewJSONHandler(&buf, nil)).Error("failed", "err", apiErr)
+	assert.NotContains(t, buf.String(), "body-secret")
+	assert.NotContains(t, buf.String(), "deadbeef")
+	assert.Contains(t, buf.String(), `"code":50003`)
+}
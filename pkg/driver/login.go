@@ -1,6 +1,7 @@
 package driver
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	neturl "net/url"
@@ -11,14 +12,19 @@ import (
 
 // CookieCheck checks the cookie status and will not logout of other devices.
 func (c *Pan115Client) CookieCheck() error {
+	return c.CookieCheckCtx(context.Background())
+}
+
+// CookieCheckCtx is like CookieCheck but uses ctx for the request.
+func (c *Pan115Client) CookieCheckCtx(ctx context.Context) error {
 	result := struct {
 		State bool `json:"state"`
 	}{}
-	req := c.NewRequest().
+	req := c.newRequest(ctx).
 		SetQueryParam("_", NowMilli().String()).
 		SetResult(&result)
 
-	if _, _ = req.Get(ApiStatusCheck); !result.State {
+	if _, _ = c.get(req, c.endpoints().StatusCheck); !result.State {
 		return ErrBadCookie
 	}
 	return nil
@@ -26,15 +32,20 @@ func (c *Pan115Client) CookieCheck() error {
 
 // LoginCheck checks the login status and will logout of other devices.
 func (c *Pan115Client) LoginCheck() error {
+	return c.LoginCheckCtx(context.Background())
+}
+
+// LoginCheckCtx is like LoginCheck but uses ctx for the request.
+func (c *Pan115Client) LoginCheckCtx(ctx context.Context) error {
 	result := LoginResp{}
-	req := c.NewRequest().
+	req := c.newRequest(ctx).
 		SetQueryParam("_", NowMilli().String()).
 		SetResult(&result)
-	resp, err := req.Get(ApiLoginCheck)
+	resp, err := c.get(req, c.endpoints().LoginCheck)
 	if err = CheckErr(err, &result, resp); err != nil {
 		return err
 	}
-	c.UserID = result.Data.UserID
+	c.setUserID(result.Data.UserID)
 	return nil
 }
 
@@ -125,10 +136,15 @@ func (cr *Credential) Cookie() string {
 
 // GetUser get user information
 func (c *Pan115Client) GetUser() (*UserInfo, error) {
+	return c.GetUserCtx(context.Background())
+}
+
+// GetUserCtx is like GetUser but uses ctx for the request.
+func (c *Pan115Client) GetUserCtx(ctx context.Context) (*UserInfo, error) {
 	result := UserInfoResp{}
-	req := c.NewRequest().
+	req := c.newRequest(ctx).
 		SetQueryParam("_", Now().String()).
 		SetResult(&result)
-	resp, err := req.Get(ApiUserInfo)
+	resp, err := c.get(req, c.endpoints().UserInfo)
 	return &result.UserInfo, CheckErr(err, &result, resp)
 }
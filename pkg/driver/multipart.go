@@ -0,0 +1,231 @@
+package driver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+	"github.com/pkg/errors"
+)
+
+const (
+	// maxPartAttempts is the number of attempts made to upload a part.
+	maxPartAttempts = 3
+	// abortTimeout bounds the abort of a failed multipart upload.
+	abortTimeout = 30 * time.Second
+)
+
+// multipartUpload is a multipart upload initiated on OSS.
+type multipartUpload struct {
+	c      *Pan115Client
+	bucket *oss.Bucket
+	imur   oss.InitiateMultipartUploadResult
+	params *UploadOSSParams
+	r      io.ReaderAt
+	// cp is the checkpoint saved along the upload, nil when disabled.
+	cp *uploadCheckpoint
+	// progress is nil unless the progress is reported.
+	progress *uploadProgress
+
+	tokenMu sync.RWMutex
+	token   *UploadOSSTokenResp
+
+	// mu guards parts.
+	mu    sync.Mutex
+	parts []oss.UploadPart
+}
+
+func (u *multipartUpload) ossToken() *UploadOSSTokenResp {
+	u.tokenMu.RLock()
+	defer u.tokenMu.RUnlock()
+	return u.token
+}
+
+func (u *multipartUpload) setOSSToken(token *UploadOSSTokenResp) {
+	u.tokenMu.Lock()
+	defer u.tokenMu.Unlock()
+	u.token = token
+}
+
+// run uploads chunks with threads workers. The first failure cancels the
+// other workers and is returned, and no goroutine is left running when run
+// returns.
+func (u *multipartUpload) run(ctx context.Context, chunks []oss.FileChunk, threads int, tokenRefresh time.Duration) error {
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		once     sync.Once
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	fail := func(err error) {
+		once.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	// ossToken一小时后就会失效，所以定时重新获取
+	refreshDone := make(chan struct{})
+	go func() {
+		defer close(refreshDone)
+		ticker := time.NewTicker(tokenRefresh)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-runCtx.Done():
+				return
+			case <-ticker.C:
+				token, err := u.c.GetOSSTokenCtx(runCtx)
+				if err != nil {
+					fail(errors.Wrap(err, "刷新token时出现错误"))
+					return
+				}
+				u.setOSSToken(token)
+			}
+		}
+	}()
+
+	chunksCh := make(chan oss.FileChunk)
+	for i := 0; i < max(threads, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					fail(fmt.Errorf("recovered in %v", r))
+				}
+			}()
+			for chunk := range chunksCh {
+				part, err := u.uploadPart(runCtx, chunk)
+				if err != nil {
+					fail(err)
+					return
+				}
+				u.addPart(runCtx, part)
+			}
+		}()
+	}
+
+feed:
+	for _, chunk := range chunks {
+		select {
+		case chunksCh <- chunk:
+		case <-runCtx.Done():
+			break feed
+		}
+	}
+	close(chunksCh)
+	wg.Wait()
+	cancel()
+	<-refreshDone
+
+	if firstErr != nil {
+		return firstErr
+	}
+	// canceled by the caller while feeding the workers
+	return ctx.Err()
+}
+
+// uploadPart uploads chunk, retrying the transient failures.
+func (u *multipartUpload) uploadPart(ctx context.Context, chunk oss.FileChunk) (oss.UploadPart, error) {
+	var (
+		part oss.UploadPart
+		err  error
+	)
+	ev := &PartEvent{PartNumber: chunk.Number, Size: chunk.Size}
+	start := time.Now()
+	defer func() {
+		ev.Duration = time.Since(start)
+		ev.Err = err
+		u.c.partUploaded(ctx, ev)
+	}()
+
+	buf := make([]byte, chunk.Size)
+	if n, rerr := u.r.ReadAt(buf, chunk.Offset); rerr != nil && !(errors.Is(rerr, io.EOF) && n == len(buf)) {
+		err = errors.Wrapf(rerr, "读取第%d个分片时出现错误", chunk.Number)
+		return part, err
+	}
+	for ev.Attempts < maxPartAttempts {
+		ev.Attempts++
+		progressOpts, rollback := u.progress.ossOptions()
+		if part, err = u.bucket.UploadPart(
+			u.imur,
+			bytes.NewReader(buf),
+			chunk.Size,
+			chunk.Number,
+			append(append(OssOption(u.params, u.ossToken()), oss.WithContext(ctx)), progressOpts...)...); err == nil {
+			return part, nil
+		}
+		rollback()
+		if ctx.Err() != nil || !IsRetryable(err) {
+			break
+		}
+	}
+	err = errors.Wrapf(err, "上传第%d个分片时出现错误", chunk.Number)
+	return part, err
+}
+
+// addPart records an uploaded part, and saves it to the checkpoint.
+func (u *multipartUpload) addPart(ctx context.Context, part oss.UploadPart) {
+	u.mu.Lock()
+	u.parts = append(u.parts, part)
+	u.mu.Unlock()
+	u.progress.partDone()
+	if u.cp != nil {
+		if err := u.cp.add(part); err != nil {
+			u.c.logWarn(ctx, "save upload checkpoint failed", err)
+		}
+	}
+}
+
+// complete completes the upload, OSS then calls back 115 with params.
+func (u *multipartUpload) complete(ctx context.Context, params *UploadOSSParams) error {
+	var bodyBytes []byte
+	u.mu.Lock()
+	parts := append([]oss.UploadPart(nil), u.parts...)
+	u.mu.Unlock()
+	if _, err := u.bucket.CompleteMultipartUpload(u.imur, parts,
+		append(
+			OssOption(params, u.ossToken()),
+			oss.CallbackResult(&bodyBytes),
+			oss.WithContext(ctx),
+		)...); err != nil {
+		return err
+	}
+	if u.cp != nil {
+		// the upload is gone from OSS, whatever the callback result
+		if err := u.cp.remove(); err != nil {
+			u.c.logWarn(ctx, "remove upload checkpoint failed", err)
+		}
+	}
+
+	var uploadResult UploadResult
+	if err := json.Unmarshal(bodyBytes, &uploadResult); err != nil {
+		return err
+	}
+	return uploadResult.Err(string(bodyBytes))
+}
+
+// abort aborts the upload on OSS, even when ctx is done, so its parts do not
+// linger in the bucket.
+func (u *multipartUpload) abort(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), abortTimeout)
+	defer cancel()
+	err := u.bucket.AbortMultipartUpload(u.imur,
+		oss.SetHeader(OssSecurityTokenHeaderName, u.ossToken().SecurityToken),
+		oss.UserAgentHeader(OSSUserAgent),
+		oss.WithContext(ctx),
+	)
+	var ossErr oss.ServiceError
+	if err != nil && !(errors.As(err, &ossErr) && ossErr.Code == "NoSuchUpload") {
+		u.c.logWarn(ctx, "abort multipart upload failed", err, slog.String("upload_id", u.imur.UploadID))
+	}
+}
@@ -1,6 +1,7 @@
 package driver
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/url"
@@ -62,13 +63,18 @@ func (t *OfflineTask) GetStatus() string {
 
 // ListOfflineTask list tasks
 func (c *Pan115Client) ListOfflineTask(page int64) (OfflineTaskResp, error) {
+	return c.ListOfflineTaskCtx(context.Background(), page)
+}
+
+// ListOfflineTaskCtx is like ListOfflineTask but uses ctx for the request.
+func (c *Pan115Client) ListOfflineTaskCtx(ctx context.Context, page int64) (OfflineTaskResp, error) {
 	result := OfflineTaskResp{}
-	req := c.NewRequest().
+	req := c.newRequest(ctx).
 		SetQueryParam("page", strconv.FormatInt(page, 10)).
 		SetResult(&result).
 		ForceContentType("application/json;charset=UTF-8")
 
-	resp, err := req.Post(ApiListOfflineUrl)
+	resp, err := c.post(req, c.endpoints().ListOfflineUrl)
 
 	if err := CheckErr(err, &result, resp); err != nil {
 		return OfflineTaskResp{}, err
@@ -79,6 +85,11 @@ func (c *Pan115Client) ListOfflineTask(page int64) (OfflineTaskResp, error) {
 // AddOfflineTaskURIs adds offline tasks by download URIs.
 // supports http, ed2k, magent
 func (c *Pan115Client) AddOfflineTaskURIs(uris []string, saveDirID string, opts ...OfflineOption) (hashes []string, err error) {
+	return c.AddOfflineTaskURIsCtx(context.Background(), uris, saveDirID, opts...)
+}
+
+// AddOfflineTaskURIsCtx is like AddOfflineTaskURIs but uses ctx for the requests.
+func (c *Pan115Client) AddOfflineTaskURIsCtx(ctx context.Context, uris []string, saveDirID string, opts ...OfflineOption) (hashes []string, err error) {
 	opt := DefaultOfflineOptions()
 
 	for _, o := range opts {
@@ -89,12 +100,14 @@ func (c *Pan115Client) AddOfflineTaskURIs(uris []string, saveDirID string, opts
 		return
 	}
 
-	if c.UserID <= 0 {
-		userInfo, err := c.GetUser()
+	userID := c.getUserID()
+	if userID <= 0 {
+		userInfo, err := c.GetUserCtx(ctx)
 		if err != nil {
 			return nil, err
 		}
-		c.UserID = userInfo.UserID
+		userID = userInfo.UserID
+		c.setUserID(userID)
 	}
 
 	key := crypto.GenerateKey()
@@ -105,7 +118,7 @@ func (c *Pan115Client) AddOfflineTaskURIs(uris []string, saveDirID string, opts
 		"ac":         "add_task_urls",
 		"wp_path_id": saveDirID,
 		"app_ver":    opt.appVer,
-		"uid":        strconv.FormatInt(c.UserID, 10),
+		"uid":        strconv.FormatInt(userID, 10),
 	}
 	for i, uri := range uris {
 		key := fmt.Sprintf("url[%d]", i)
@@ -116,20 +129,20 @@ func (c *Pan115Client) AddOfflineTaskURIs(uris []string, saveDirID string, opts
 		return nil, err
 	}
 
-	data := crypto.Encode(paramsBytes, key)
-	req := c.NewRequest().
+	data := crypto.EncodeWith(c.m115PublicKey(), paramsBytes, key)
+	req := c.newRequest(ctx).
 		SetQueryParam("t", Now().String()).
 		SetFormData(map[string]string{"data": data}).
 		ForceContentType("application/json").
 		SetResult(&result)
 
-	resp, err := req.Post(ApiAddOfflineUrl)
+	resp, err := c.post(req, c.endpoints().AddOfflineUrl)
 
 	if err := CheckErr(err, &result, resp); err != nil {
 		return nil, err
 	}
 
-	bytes, err := crypto.Decode(string(result.EncodedData), key)
+	bytes, err := crypto.DecodeWith(c.m115PublicKey(), string(result.EncodedData), key)
 	if err != nil {
 		return nil, err
 	}
@@ -148,6 +161,11 @@ func (c *Pan115Client) AddOfflineTaskURIs(uris []string, saveDirID string, opts
 
 // DeleteOfflineTasks deletes tasks.
 func (c *Pan115Client) DeleteOfflineTasks(hashes []string, deleteFiles bool) error {
+	return c.DeleteOfflineTasksCtx(context.Background(), hashes, deleteFiles)
+}
+
+// DeleteOfflineTasksCtx is like DeleteOfflineTasks but uses ctx for the request.
+func (c *Pan115Client) DeleteOfflineTasksCtx(ctx context.Context, hashes []string, deleteFiles bool) error {
 	form := url.Values{}
 	for _, hash := range hashes {
 		form.Add("hash", hash)
@@ -159,27 +177,32 @@ func (c *Pan115Client) DeleteOfflineTasks(hashes []string, deleteFiles bool) err
 	}
 
 	result := MkdirResp{}
-	req := c.NewRequest().
+	req := c.newRequest(ctx).
 		SetFormDataFromValues(form).
 		SetResult(&result).
 		ForceContentType("application/json;charset=UTF-8")
 
-	resp, err := req.Post(ApiDelOfflineUrl)
+	resp, err := c.post(req, c.endpoints().DelOfflineUrl)
 	return CheckErr(err, &result, resp)
 }
 
 // ClearOfflineTasks deletes tasks.
 func (c *Pan115Client) ClearOfflineTasks(clearFlag int64) error {
+	return c.ClearOfflineTasksCtx(context.Background(), clearFlag)
+}
+
+// ClearOfflineTasksCtx is like ClearOfflineTasks but uses ctx for the request.
+func (c *Pan115Client) ClearOfflineTasksCtx(ctx context.Context, clearFlag int64) error {
 	form := url.Values{}
 	form.Set("flag", strconv.FormatInt(int64(clearFlag), 10))
 
 	result := MkdirResp{}
-	req := c.NewRequest().
+	req := c.newRequest(ctx).
 		SetFormDataFromValues(form).
 		SetResult(&result).
 		ForceContentType("application/json;charset=UTF-8")
 
-	resp, err := req.Post(ApiClearOfflineUrl)
+	resp, err := c.post(req, c.endpoints().ClearOfflineUrl)
 	return CheckErr(err, &result, resp)
 }
 
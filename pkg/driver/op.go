@@ -1,6 +1,7 @@
 package driver
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 	"time"
@@ -8,6 +9,11 @@ import (
 
 // Delete delete files or directory from file ids
 func (c *Pan115Client) Delete(fileIDs ...string) error {
+	return c.DeleteCtx(context.Background(), fileIDs...)
+}
+
+// DeleteCtx is like Delete but uses ctx for the request.
+func (c *Pan115Client) DeleteCtx(ctx context.Context, fileIDs ...string) error {
 	if len(fileIDs) == 0 {
 		return nil
 	}
@@ -18,16 +24,25 @@ func (c *Pan115Client) Delete(fileIDs ...string) error {
 	}
 
 	result := BasicResp{}
-	req := c.NewRequest().
+	req := c.newRequest(ctx).
 		SetFormData(form).
 		ForceContentType("application/json;charset=UTF-8").
 		SetResult(&result)
-	resp, err := req.Post(ApiFileDelete)
-	return CheckErr(err, &result, resp)
+	resp, err := c.post(req, c.endpoints().FileDelete)
+	if err := CheckErr(err, &result, resp); err != nil {
+		return err
+	}
+	c.paths.invalidate(fileIDs...)
+	return nil
 }
 
 // Rename rename a file or directory with file id and name
 func (c *Pan115Client) Rename(fileID, newName string) error {
+	return c.RenameCtx(context.Background(), fileID, newName)
+}
+
+// RenameCtx is like Rename but uses ctx for the request.
+func (c *Pan115Client) RenameCtx(ctx context.Context, fileID, newName string) error {
 	form := map[string]string{
 		"fid":       fileID,
 		"file_name": newName,
@@ -35,16 +50,25 @@ func (c *Pan115Client) Rename(fileID, newName string) error {
 	}
 
 	result := BasicResp{}
-	req := c.NewRequest().
+	req := c.newRequest(ctx).
 		SetFormData(form).
 		ForceContentType("application/json;charset=UTF-8").
 		SetResult(&result)
-	resp, err := req.Post(ApiFileRename)
-	return CheckErr(err, &result, resp)
+	resp, err := c.post(req, c.endpoints().FileRename)
+	if err := CheckErr(err, &result, resp); err != nil {
+		return err
+	}
+	c.paths.invalidate(fileID)
+	return nil
 }
 
 // Move move files or directory into another directory with directroy id
 func (c *Pan115Client) Move(dirID string, fileIDs ...string) error {
+	return c.MoveCtx(context.Background(), dirID, fileIDs...)
+}
+
+// MoveCtx is like Move but uses ctx for the request.
+func (c *Pan115Client) MoveCtx(ctx context.Context, dirID string, fileIDs ...string) error {
 	if len(fileIDs) == 0 {
 		return nil
 	}
@@ -56,16 +80,25 @@ func (c *Pan115Client) Move(dirID string, fileIDs ...string) error {
 		form[key] = value
 	}
 	result := BasicResp{}
-	req := c.NewRequest().
+	req := c.newRequest(ctx).
 		SetFormData(form).
 		ForceContentType("application/json;charset=UTF-8").
 		SetResult(&result)
-	resp, err := req.Post(ApiFileMove)
-	return CheckErr(err, &result, resp)
+	resp, err := c.post(req, c.endpoints().FileMove)
+	if err := CheckErr(err, &result, resp); err != nil {
+		return err
+	}
+	c.paths.invalidate(fileIDs...)
+	return nil
 }
 
 // Copy copy files or directory into another directory with directroy id
 func (c *Pan115Client) Copy(dirID string, fileIDs ...string) error {
+	return c.CopyCtx(context.Background(), dirID, fileIDs...)
+}
+
+// CopyCtx is like Copy but uses ctx for the request.
+func (c *Pan115Client) CopyCtx(ctx context.Context, dirID string, fileIDs ...string) error {
 	if len(fileIDs) == 0 {
 		return nil
 	}
@@ -77,11 +110,11 @@ func (c *Pan115Client) Copy(dirID string, fileIDs ...string) error {
 		form[key] = value
 	}
 	result := BasicResp{}
-	req := c.NewRequest().
+	req := c.newRequest(ctx).
 		SetFormData(form).
 		ForceContentType("application/json;charset=UTF-8").
 		SetResult(&result)
-	resp, err := req.Post(ApiFileCopy)
+	resp, err := c.post(req, c.endpoints().FileCopy)
 	return CheckErr(err, &result, resp)
 }
 
@@ -120,12 +153,17 @@ type DirInfo struct {
 
 // Stat get statistic information of a file or directory
 func (c *Pan115Client) Stat(fileID string) (*FileStatInfo, error) {
+	return c.StatCtx(context.Background(), fileID)
+}
+
+// StatCtx is like Stat but uses ctx for the request.
+func (c *Pan115Client) StatCtx(ctx context.Context, fileID string) (*FileStatInfo, error) {
 	result := FileStatResponse{}
-	req := c.NewRequest().
+	req := c.newRequest(ctx).
 		SetQueryParam("cid", fileID).
 		ForceContentType("application/json;charset=UTF-8").
 		SetResult(&result)
-	resp, err := req.Get(ApiFileStat)
+	resp, err := c.get(req, c.endpoints().FileStat)
 	if err := CheckErr(err, &result, resp); err != nil {
 		return nil, err
 	}
@@ -156,12 +194,17 @@ func (c *Pan115Client) Stat(fileID string) (*FileStatInfo, error) {
 
 // GetFile gets information of a file or directory by its ID.
 func (c *Pan115Client) GetFile(fileID string) (*File, error) {
+	return c.GetFileCtx(context.Background(), fileID)
+}
+
+// GetFileCtx is like GetFile but uses ctx for the request.
+func (c *Pan115Client) GetFileCtx(ctx context.Context, fileID string) (*File, error) {
 	result := GetFileInfoResponse{}
-	req := c.NewRequest().
+	req := c.newRequest(ctx).
 		SetQueryParam("file_id", fileID).
 		ForceContentType("application/json;charset=UTF-8").
 		SetResult(&result)
-	resp, err := req.Get(ApiFileInfo)
+	resp, err := c.get(req, c.endpoints().FileInfo)
 	if err := CheckErr(err, &result, resp); err != nil {
 		return nil, err
 	}
@@ -1,7 +1,9 @@
 package driver
 
 import (
+	"crypto/rsa"
 	"crypto/tls"
+	"log/slog"
 	"net/http"
 	"strconv"
 	"time"
@@ -34,6 +36,8 @@ func WithRestyClient(resty *resty.Client) Option {
 	}
 }
 
+// WithDebug dumps the raw requests and responses, cookies included, so it
+// should not be used in production, see WithLogger.
 func WithDebug() Option {
 	return func(c *Pan115Client) {
 		c.SetDebug(true)
@@ -46,6 +50,115 @@ func WithTrace() Option {
 	}
 }
 
+// WithEndpoints sets the API endpoints used by the client.
+func WithEndpoints(e *Endpoints) Option {
+	return func(c *Pan115Client) {
+		c.Endpoints = e
+	}
+}
+
+// WithBaseHost sends every 115 API request to base, e.g. the URL of an httptest.Server.
+func WithBaseHost(base string) Option {
+	return func(c *Pan115Client) {
+		c.Endpoints = c.endpoints().WithBaseHost(base)
+	}
+}
+
+// WithHost reroutes the requests for host, e.g. "proapi.115.com", to base.
+func WithHost(host, base string) Option {
+	return func(c *Pan115Client) {
+		c.Endpoints = c.endpoints().WithHost(host, base)
+	}
+}
+
+// WithRetryPolicy retries the failed API calls according to p, see DefaultRetryPolicy.
+func WithRetryPolicy(p *RetryPolicy) Option {
+	return func(c *Pan115Client) {
+		c.retryPolicy = p
+	}
+}
+
+// WithRateLimiter limits the rate of the API calls per host with l, which may
+// be shared with other clients. Use NewRateLimiter(nil) for the default limits.
+func WithRateLimiter(l *RateLimiter) Option {
+	return func(c *Pan115Client) {
+		c.rateLimiter = l
+	}
+}
+
+// WithLogger writes a structured record of every API call to l: the endpoint
+// name, method, duration, errno, retry count and bytes received, at debug level
+// for successful calls and warn level for failed ones. Cookies are never logged
+// and the signing parameters of URLs are redacted. Errors the client recovers
+// from, like an upload endpoint fallback, are logged at warn level.
+func WithLogger(l *slog.Logger) Option {
+	return func(c *Pan115Client) {
+		c.logger = l
+	}
+}
+
+// WithDownloadStrategies resolves the download URLs of Download, OpenFile and
+// the Downloader with the strategies in order: when one fails with a file
+// too big or an empty URL error, the next one is tried. The default is the
+// web API alone.
+func WithDownloadStrategies(strategies ...DownloadStrategy) Option {
+	return func(c *Pan115Client) {
+		c.downloadStrategies = strategies
+	}
+}
+
+// WithDownloadFailover sets the errors of a download strategy on which the
+// next one is tried, instead of ErrDownloadFileTooBig and ErrDownloadEmpty.
+func WithDownloadFailover(errs ...error) Option {
+	return func(c *Pan115Client) {
+		c.downloadFailover = errs
+	}
+}
+
+// WithDownloadCache reuses the download URLs resolved by DownloadWithUA and
+// DownloadWithUAByAndroidAPI until they expire. The cache may be shared by
+// several clients, the URLs being kept per account, and a URL refused by the
+// server is resolved again.
+func WithDownloadCache(cache *DownloadCache) Option {
+	return func(c *Pan115Client) {
+		c.downloadCache = cache
+	}
+}
+
+// WithPathCacheSize caches the IDs of up to n paths resolved by ResolvePath
+// and the other path methods, DefaultPathCacheSize by default. Zero disables
+// the cache. The cache is kept up to date by the Move, Rename, Delete and Mkdir
+// calls of the client, but not by changes made elsewhere.
+func WithPathCacheSize(n int) Option {
+	return func(c *Pan115Client) {
+		c.paths = newPathCache(n)
+	}
+}
+
+// WithInstrumentation reports the API calls, upload phases and parts, and
+// resolved download URLs to i, e.g. to record spans and metrics.
+func WithInstrumentation(i Instrumentation) Option {
+	return func(c *Pan115Client) {
+		c.instrumentation = i
+	}
+}
+
+// WithM115PublicKey sets the RSA key used to encode the payloads of the download
+// and offline APIs, for servers standing in for 115 which sign with their own key.
+func WithM115PublicKey(pub *rsa.PublicKey) Option {
+	return func(c *Pan115Client) {
+		c.m115Key = pub
+	}
+}
+
+// WithEC115PublicKey sets the P-224 key, its X and Y coordinates, the payloads
+// of the upload init API are encrypted with, for servers standing in for 115.
+func WithEC115PublicKey(pub []byte) Option {
+	return func(c *Pan115Client) {
+		c.ec115Key = pub
+	}
+}
+
 func WithProxy(proxy string) Option {
 	return func(c *Pan115Client) {
 		c.SetProxy(proxy)
@@ -59,14 +172,30 @@ func InsecureSkipVerify(insecureSkipVerify bool) Option {
 }
 
 const (
-	FileOrderByTime = "user_ptime"
-	FileOrderByType = "file_type"
-	FileOrderBySize = "file_size"
-	FileOrderByName = "file_name"
+	FileOrderByTime       = "user_ptime"
+	FileOrderByUpdateTime = "user_utime"
+	FileOrderByOpenTime   = "user_otime"
+	FileOrderByType       = "file_type"
+	FileOrderBySize       = "file_size"
+	FileOrderByName       = "file_name"
 
 	FileListLimit = int64(56)
 )
 
+// FileType is a category of entries the list API can filter on, numbered
+// like the Type of SearchOption.
+type FileType int
+
+const (
+	FileTypeAll FileType = iota
+	FileTypeFolder
+	FileTypeDocument
+	FileTypeImage
+	FileTypeVideo
+	FileTypeAudio
+	FileTypeArchive
+)
+
 // GetFileOption get file options
 type GetFileOption struct {
 	order    string
@@ -75,6 +204,11 @@ type GetFileOption struct {
 	offset   int64
 	showDir  string
 	apiURL   string
+	natsort  string
+	fcMix    string
+	fileType FileType
+	suffix   string
+	star     bool
 }
 
 type GetFileOptions func(o *GetFileOption)
@@ -114,13 +248,54 @@ func WithShowDirEnable(e bool) GetFileOptions {
 
 func WithAsc(d bool) GetFileOptions {
 	return func(o *GetFileOption) {
-		o.showDir = "0"
+		o.asc = "0"
 		if d {
-			o.showDir = "1"
+			o.asc = "1"
 		}
 	}
 }
 
+// WithNatSort sorts names naturally, "2" before "10".
+func WithNatSort(e bool) GetFileOptions {
+	return func(o *GetFileOption) {
+		o.natsort = "0"
+		if e {
+			o.natsort = "1"
+		}
+	}
+}
+
+// WithMixed sorts directories with the files instead of before them.
+func WithMixed(e bool) GetFileOptions {
+	return func(o *GetFileOption) {
+		o.fcMix = "0"
+		if e {
+			o.fcMix = "1"
+		}
+	}
+}
+
+// WithFileType lists only the entries of category t.
+func WithFileType(t FileType) GetFileOptions {
+	return func(o *GetFileOption) {
+		o.fileType = t
+	}
+}
+
+// WithSuffix lists only the files with the extension suffix, e.g. "mp4".
+func WithSuffix(suffix string) GetFileOptions {
+	return func(o *GetFileOption) {
+		o.suffix = suffix
+	}
+}
+
+// WithStarred lists only the starred entries.
+func WithStarred(e bool) GetFileOptions {
+	return func(o *GetFileOption) {
+		o.star = e
+	}
+}
+
 func (o *GetFileOption) GetApiURL() string {
 	return o.apiURL
 }
@@ -145,6 +320,29 @@ func (o *GetFileOption) GetshowDir() string {
 	return o.showDir
 }
 
+func (o *GetFileOption) GetNatSort() string {
+	return o.natsort
+}
+
+func (o *GetFileOption) GetFcMix() string {
+	return o.fcMix
+}
+
+// params returns the filter query params, which are only sent when set.
+func (o *GetFileOption) params() map[string]string {
+	params := map[string]string{}
+	if o.fileType != FileTypeAll {
+		params["type"] = strconv.Itoa(int(o.fileType))
+	}
+	if o.suffix != "" {
+		params["suffix"] = o.suffix
+	}
+	if o.star {
+		params["star"] = "1"
+	}
+	return params
+}
+
 func DefaultGetFileOptions() *GetFileOption {
 	return &GetFileOption{
 		order:    FileOrderByTime,
@@ -153,13 +351,56 @@ func DefaultGetFileOptions() *GetFileOption {
 		offset:   int64(0),
 		showDir:  "1",
 		apiURL:   ApiFileList,
+		natsort:  "0",
+		fcMix:    "0",
+	}
+}
+
+type UploadOptions struct {
+	// Progress is called as the upload progresses, the calls are serialized.
+	Progress func(p UploadProgress)
+
+	// tracker is the progress of an upload this one is a phase of.
+	tracker *uploadProgress
+}
+
+func DefaultUploadOptions() *UploadOptions {
+	return &UploadOptions{}
+}
+
+type UploadOption func(o *UploadOptions)
+
+// UploadWithProgress calls fn as the upload progresses. The calls are serialized.
+func UploadWithProgress(fn func(p UploadProgress)) UploadOption {
+	return func(o *UploadOptions) {
+		o.Progress = fn
+	}
+}
+
+// uploadWithTracker reports the progress to the tracker of the upload this
+// one is a phase of.
+func uploadWithTracker(t *uploadProgress) UploadOption {
+	return func(o *UploadOptions) {
+		o.tracker = t
 	}
 }
 
 type UploadMultipartOptions struct {
+	// ThreadsNum is the number of parts uploaded at once. More than one
+	// uploads the parts out of order, unless Sequential is set.
 	ThreadsNum       int
 	Timeout          time.Duration
 	TokenRefreshTime time.Duration
+	// Sequential uploads the parts in order, one at a time, and lets OSS
+	// compute the SHA1 of the file.
+	Sequential bool
+	// Checkpoint is the file the upload progress is saved to, empty disables it.
+	Checkpoint string
+	// Progress is called as the upload progresses, the calls are serialized.
+	Progress func(p UploadProgress)
+
+	// tracker is the progress of an upload this one is a phase of.
+	tracker *uploadProgress
 }
 
 // DefalutUploadMultipartOptions is deprecated: use DefaultUploadMultipartOptions instead. This function exists for backward compatibility.
@@ -169,7 +410,7 @@ func DefalutUploadMultipartOptions() *UploadMultipartOptions {
 
 func DefaultUploadMultipartOptions() *UploadMultipartOptions {
 	return &UploadMultipartOptions{
-		// oss 启用Sequential必须按顺序上传
+		// 单线程时按顺序上传, oss 启用Sequential
 		ThreadsNum:       1,
 		Timeout:          time.Hour * 24,
 		TokenRefreshTime: time.Minute * 50,
@@ -178,6 +419,9 @@ func DefaultUploadMultipartOptions() *UploadMultipartOptions {
 
 type UploadMultipartOption func(o *UploadMultipartOptions)
 
+// UploadMultipartWithThreadsNum uploads n parts at once. With n > 1 the parts
+// are uploaded out of order and the SHA1 the upload callback checks is
+// computed by the client, see UploadMultipartWithSequential.
 func UploadMultipartWithThreadsNum(n int) UploadMultipartOption {
 	return func(o *UploadMultipartOptions) {
 		o.ThreadsNum = n
@@ -196,14 +440,122 @@ func UploadMultipartWithTokenRefreshTime(refreshTime time.Duration) UploadMultip
 	}
 }
 
+// UploadMultipartWithSequential uploads the parts in order with a single
+// thread, whatever the ThreadsNum, for OSS to compute the SHA1 of the file.
+func UploadMultipartWithSequential() UploadMultipartOption {
+	return func(o *UploadMultipartOptions) {
+		o.Sequential = true
+	}
+}
+
+// UploadMultipartWithCheckpoint saves the upload ID, the OSS parameters and
+// the uploaded parts to the file at path, so that a failed upload of the same
+// file resumes where it stopped, with the parts OSS already has. The file is
+// removed once the upload completes. A checkpoint of another file is
+// discarded, aborting its upload, see DiscardUploadCheckpoint. Without the
+// SHA1 in the OSS parameters, the file is hashed first to match it.
+func UploadMultipartWithCheckpoint(path string) UploadMultipartOption {
+	return func(o *UploadMultipartOptions) {
+		o.Checkpoint = path
+	}
+}
+
+// UploadMultipartWithProgress calls fn as the upload progresses, and after
+// each part. The calls are serialized.
+func UploadMultipartWithProgress(fn func(p UploadProgress)) UploadMultipartOption {
+	return func(o *UploadMultipartOptions) {
+		o.Progress = fn
+	}
+}
+
+func uploadMultipartWithTracker(t *uploadProgress) UploadMultipartOption {
+	return func(o *UploadMultipartOptions) {
+		o.tracker = t
+	}
+}
+
+type UploadStreamOptions struct {
+	// MemoryLimit is the largest stream spooled to memory, larger ones are
+	// spooled to a temp file.
+	MemoryLimit int64
+	// TempDir is the dir of the temp files, os.TempDir when empty.
+	TempDir string
+	// MaxSize bounds the stream, and so the temp file, 0 means the upload
+	// size limit of the account.
+	MaxSize int64
+	// MultipartOptions are the options of the multipart upload of the streams
+	// spooled to a temp file.
+	MultipartOptions []UploadMultipartOption
+	// Progress is called as the upload progresses, the calls are serialized.
+	Progress func(p UploadProgress)
+}
+
+func DefaultUploadStreamOptions() *UploadStreamOptions {
+	return &UploadStreamOptions{
+		MemoryLimit: 8 * MB,
+	}
+}
+
+type UploadStreamOption func(o *UploadStreamOptions)
+
+// UploadStreamWithMemoryLimit spools the streams of up to n bytes to memory,
+// and uploads them in a single request.
+func UploadStreamWithMemoryLimit(n int64) UploadStreamOption {
+	return func(o *UploadStreamOptions) {
+		o.MemoryLimit = n
+	}
+}
+
+// UploadStreamWithTempDir spools the large streams to a temp file in dir.
+func UploadStreamWithTempDir(dir string) UploadStreamOption {
+	return func(o *UploadStreamOptions) {
+		o.TempDir = dir
+	}
+}
+
+// UploadStreamWithMaxSize fails the uploads of the streams longer than n
+// bytes with ErrUploadTooLarge.
+func UploadStreamWithMaxSize(n int64) UploadStreamOption {
+	return func(o *UploadStreamOptions) {
+		o.MaxSize = n
+	}
+}
+
+// UploadStreamWithMultipartOptions sets the options of the multipart upload
+// of the large streams.
+func UploadStreamWithMultipartOptions(opts ...UploadMultipartOption) UploadStreamOption {
+	return func(o *UploadStreamOptions) {
+		o.MultipartOptions = append(o.MultipartOptions, opts...)
+	}
+}
+
+// UploadStreamWithProgress calls fn as the stream is hashed and uploaded. The
+// calls are serialized.
+func UploadStreamWithProgress(fn func(p UploadProgress)) UploadStreamOption {
+	return func(o *UploadStreamOptions) {
+		o.Progress = fn
+	}
+}
+
 type ListOptions struct {
+	// ApiURLs overrides the file list URLs, the client endpoints are used when empty.
 	ApiURLs []string
+	// multiUrls rotates the requests over the client's FileList and FileList1 endpoints.
+	multiUrls bool
+	// concurrency is the number of pages fetched in parallel, see WithListConcurrency.
+	concurrency int
+	// pageSize overrides the number of entries per request.
+	pageSize int64
+	// fileOpts are the ordering and filtering options of the requests.
+	fileOpts []GetFileOptions
 }
 
+// DefaultListOptions returns the options used when no ListOption is given.
+// ApiURLs is left empty so the requests follow the FileList endpoint of the
+// client, ApiFileList unless changed with WithEndpoints or WithBaseHost; it
+// used to hold ApiFileList, which ignored those.
 func DefaultListOptions() *ListOptions {
-	return &ListOptions{
-		ApiURLs: []string{ApiFileList},
-	}
+	return &ListOptions{}
 }
 
 type ListOption func(o *ListOptions)
@@ -217,12 +569,73 @@ func WithApiURLs(urls ...string) ListOption {
 }
 
 func WithMultiUrls() ListOption {
-	return WithApiURLs([]string{
-		ApiFileList,
-		ApiFileList1,
-		// ApiFileList2,
-		// ApiFileList3,
-	}...)
+	return func(o *ListOptions) {
+		o.ApiURLs = nil
+		o.multiUrls = true
+	}
+}
+
+// WithListOrder sorts the entries by order, one of the FileOrderBy constants.
+func WithListOrder(order string) ListOption {
+	return withFileOptions(WithOrder(order))
+}
+
+// WithListAsc sorts the entries in ascending order, the default, or descending order.
+func WithListAsc(asc bool) ListOption {
+	return withFileOptions(WithAsc(asc))
+}
+
+// WithListNatSort sorts names naturally, "2" before "10".
+func WithListNatSort(e bool) ListOption {
+	return withFileOptions(WithNatSort(e))
+}
+
+// WithListMixed sorts directories with the files instead of before them.
+func WithListMixed(e bool) ListOption {
+	return withFileOptions(WithMixed(e))
+}
+
+// WithListShowDir lists the directories, the default, or only the files.
+func WithListShowDir(e bool) ListOption {
+	return withFileOptions(WithShowDirEnable(e))
+}
+
+// WithListFileType lists only the entries of category t.
+func WithListFileType(t FileType) ListOption {
+	return withFileOptions(WithFileType(t))
+}
+
+// WithListSuffix lists only the files with the extension suffix, e.g. "mp4".
+func WithListSuffix(suffix string) ListOption {
+	return withFileOptions(WithSuffix(suffix))
+}
+
+// WithListStarred lists only the starred entries.
+func WithListStarred() ListOption {
+	return withFileOptions(WithStarred(true))
+}
+
+// WithListPageSize fetches n entries per request, up to MaxDirPageLimit.
+func WithListPageSize(n int64) ListOption {
+	return func(o *ListOptions) {
+		o.pageSize = n
+	}
+}
+
+func withFileOptions(opts ...GetFileOptions) ListOption {
+	return func(o *ListOptions) {
+		o.fileOpts = append(o.fileOpts, opts...)
+	}
+}
+
+// WithListConcurrency fetches up to n pages in parallel once the first page
+// reveals the number of entries, rotating over the list URLs. The entries are
+// still returned in order. A listing fails with ErrDirChanged when the number
+// of entries changes between pages.
+func WithListConcurrency(n int) ListOption {
+	return func(o *ListOptions) {
+		o.concurrency = n
+	}
 }
 
 type OfflineOptions struct {
@@ -0,0 +1,118 @@
+package driver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// queryServer records the query of the last file list request.
+func queryServer(t *testing.T) (*httptest.Server, *url.Values) {
+	var query url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		writeJSON(w, map[string]any{"state": true, "cid": query.Get("cid"), "count": 0})
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &query
+}
+
+func flatten(q url.Values) map[string]string {
+	m := map[string]string{}
+	for k, v := range q {
+		m[k] = v[0]
+	}
+	return m
+}
+
+func TestListDefaultQuery(t *testing.T) {
+	srv, query := queryServer(t)
+	c := New(UA(), WithBaseHost(srv.URL))
+
+	_, err := c.List("42")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"aid":              "1",
+		"cid":              "42",
+		"o":                FileOrderByTime,
+		"asc":              "1",
+		"offset":           "0",
+		"show_dir":         "1",
+		"limit":            "56",
+		"snap":             "0",
+		"natsort":          "0",
+		"record_open_time": "1",
+		"format":           "json",
+		"fc_mix":           "0",
+	}, flatten(*query))
+}
+
+func TestListOptionsQuery(t *testing.T) {
+	srv, query := queryServer(t)
+	c := New(UA(), WithBaseHost(srv.URL))
+	opts := []ListOption{
+		WithListOrder(FileOrderByName),
+		WithListAsc(false),
+		WithListNatSort(true),
+		WithListMixed(true),
+		WithListShowDir(false),
+		WithListFileType(FileTypeVideo),
+		WithListSuffix("mp4"),
+		WithListStarred(),
+		WithListPageSize(200),
+	}
+	want := map[string]string{
+		"aid":              "1",
+		"cid":              "42",
+		"o":                FileOrderByName,
+		"asc":              "0",
+		"offset":           "0",
+		"show_dir":         "0",
+		"limit":            "200",
+		"snap":             "0",
+		"natsort":          "1",
+		"record_open_time": "1",
+		"format":           "json",
+		"fc_mix":           "1",
+		"type":             "4",
+		"suffix":           "mp4",
+		"star":             "1",
+	}
+
+	_, err := c.List("42", opts...)
+	require.NoError(t, err)
+	assert.Equal(t, want, flatten(*query))
+
+	_, err = c.ListPage("42", 100, 200, opts...)
+	require.NoError(t, err)
+	want["offset"] = "100"
+	assert.Equal(t, want, flatten(*query))
+}
+
+func TestListPageSizeCapped(t *testing.T) {
+	srv, query := queryServer(t)
+	c := New(UA(), WithBaseHost(srv.URL))
+
+	_, err := c.List("0", WithListPageSize(5000))
+	require.NoError(t, err)
+	assert.Equal(t, "1150", query.Get("limit"))
+}
+
+func TestGetFilesWithAsc(t *testing.T) {
+	srv, query := queryServer(t)
+
+	_, err := GetFiles(resty.New().R(), "0", WithApiURL(srv.URL), WithAsc(false))
+	require.NoError(t, err)
+	assert.Equal(t, "0", query.Get("asc"))
+	assert.Equal(t, "1", query.Get("show_dir"))
+
+	_, err = GetFiles(resty.New().R(), "0", WithApiURL(srv.URL), WithAsc(true), WithShowDirEnable(false))
+	require.NoError(t, err)
+	assert.Equal(t, "1", query.Get("asc"))
+	assert.Equal(t, "0", query.Get("show_dir"))
+}
@@ -0,0 +1,187 @@
+package driver
+
+import (
+	"context"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ResolvePath returns the ID of the file or directory at the slash separated
+// path p, relative to the root of the account, and whether it is a directory.
+// The resolved paths are cached, see WithPathCacheSize.
+func (c *Pan115Client) ResolvePath(p string) (string, bool, error) {
+	return c.ResolvePathCtx(context.Background(), p)
+}
+
+// ResolvePathCtx is like ResolvePath but uses ctx for the requests.
+func (c *Pan115Client) ResolvePathCtx(ctx context.Context, p string) (string, bool, error) {
+	e, err := c.resolve(ctx, cleanPath(p))
+	if err != nil {
+		return "", false, err
+	}
+	return e.id, e.isDir, nil
+}
+
+// StatPath returns the file or directory at the path p. Paths found in the
+// cache are served by a single GetFile of their ID.
+func (c *Pan115Client) StatPath(p string) (*File, error) {
+	return c.StatPathCtx(context.Background(), p)
+}
+
+// StatPathCtx is like StatPath but uses ctx for the requests.
+func (c *Pan115Client) StatPathCtx(ctx context.Context, p string) (*File, error) {
+	p = cleanPath(p)
+	if p == "/" {
+		return &File{IsDirectory: true, FileID: "0", Name: "/"}, nil
+	}
+	if f, err := c.statCached(ctx, p); f != nil || err != nil {
+		return f, err
+	}
+	return c.lookup(ctx, p)
+}
+
+// MkdirAll creates the directory at the path p, along with any missing
+// parent, like os.MkdirAll, and returns its ID.
+func (c *Pan115Client) MkdirAll(p string) (string, error) {
+	return c.MkdirAllCtx(context.Background(), p)
+}
+
+// MkdirAllCtx is like MkdirAll but uses ctx for the requests.
+func (c *Pan115Client) MkdirAllCtx(ctx context.Context, p string) (string, error) {
+	p = cleanPath(p)
+	if e, err := c.resolve(ctx, p); err == nil {
+		if !e.isDir {
+			return "", errors.Wrapf(ErrExist, "%s is not a directory", p)
+		}
+		return e.id, nil
+	} else if !errors.Is(err, ErrNotExist) {
+		return "", err
+	}
+
+	dirID, dirPath := "0", "/"
+	for _, name := range strings.Split(strings.TrimPrefix(p, "/"), "/") {
+		dirPath = path.Join(dirPath, name)
+		e, err := c.resolve(ctx, dirPath)
+		if err == nil {
+			if !e.isDir {
+				return "", errors.Wrapf(ErrExist, "%s is not a directory", dirPath)
+			}
+			dirID = e.id
+			continue
+		}
+		if !errors.Is(err, ErrNotExist) {
+			return "", err
+		}
+		id, err := c.MkdirCtx(ctx, dirID, name)
+		if errors.Is(err, ErrExist) {
+			// created concurrently
+			if e, err = c.resolve(ctx, dirPath); err == nil && e.isDir {
+				dirID = e.id
+				continue
+			}
+		}
+		if err != nil {
+			return "", err
+		}
+		c.paths.put(pathEntry{path: dirPath, id: id, isDir: true})
+		dirID = id
+	}
+	return dirID, nil
+}
+
+// RemovePath moves the file or directory at the path p to the recycle bin.
+func (c *Pan115Client) RemovePath(p string) error {
+	return c.RemovePathCtx(context.Background(), p)
+}
+
+// RemovePathCtx is like RemovePath but uses ctx for the requests.
+func (c *Pan115Client) RemovePathCtx(ctx context.Context, p string) error {
+	p = cleanPath(p)
+	if p == "/" {
+		return errors.Wrap(ErrWrongParams, "can not remove the root directory")
+	}
+	// the cached entry may be another file by now, which must not be removed
+	if _, err := c.statCached(ctx, p); err != nil {
+		return err
+	}
+	e, err := c.resolve(ctx, p)
+	if err != nil {
+		return err
+	}
+	return c.DeleteCtx(ctx, e.id)
+}
+
+// statCached returns the file of the cached entry of the clean path p, with a
+// single request for its ID rather than a listing of the parent. It returns
+// nil when p is not cached, or when the entry is stale, which is dropped.
+func (c *Pan115Client) statCached(ctx context.Context, p string) (*File, error) {
+	e, ok := c.paths.get(p)
+	if !ok {
+		return nil, nil
+	}
+	parentID := ""
+	if dir := path.Dir(p); dir == "/" {
+		parentID = "0"
+	} else if parent, ok := c.paths.get(dir); ok {
+		parentID = parent.id
+	}
+	f, err := c.GetFileCtx(ctx, e.id)
+	if err == nil && f.FileID == e.id && f.IsDirectory == e.isDir && f.Name == path.Base(p) &&
+		(parentID == "" || f.ParentID == parentID) {
+		return f, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c.paths.invalidate(e.id)
+	return nil, nil
+}
+
+// resolve returns the entry at the clean path p.
+func (c *Pan115Client) resolve(ctx context.Context, p string) (pathEntry, error) {
+	if p == "/" {
+		return pathEntry{path: p, id: "0", isDir: true}, nil
+	}
+	if e, ok := c.paths.get(p); ok {
+		return e, nil
+	}
+	// directories are resolved in a single call, which returns 0 for others
+	resp, err := c.DirName2CIDCtx(ctx, p)
+	if err != nil {
+		return pathEntry{}, err
+	}
+	if id := string(resp.CategoryID); id != "" && id != "0" {
+		e := pathEntry{path: p, id: id, isDir: true}
+		c.paths.put(e)
+		return e, nil
+	}
+	f, err := c.lookup(ctx, p)
+	if err != nil {
+		return pathEntry{}, err
+	}
+	return pathEntry{path: p, id: f.FileID, isDir: f.IsDirectory}, nil
+}
+
+// lookup finds the entry at the clean path p in the listing of its parent.
+func (c *Pan115Client) lookup(ctx context.Context, p string) (*File, error) {
+	parent, err := c.resolve(ctx, path.Dir(p))
+	if err != nil {
+		return nil, err
+	}
+	if !parent.isDir {
+		return nil, errors.Wrap(ErrNotExist, p)
+	}
+	name := path.Base(p)
+	for f, err := range c.ListIterCtx(ctx, parent.id) {
+		if err != nil {
+			return nil, err
+		}
+		if f.Name == name {
+			c.paths.put(pathEntry{path: p, id: f.FileID, isDir: f.IsDirectory})
+			return &f, nil
+		}
+	}
+	return nil, errors.Wrap(ErrNotExist, p)
+}
@@ -0,0 +1,202 @@
+package driver
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/SheltonZhu/115driver/pkg/driver/fake115"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPathCacheEvicts(t *testing.T) {
+	pc := newPathCache(2)
+	pc.put(pathEntry{path: "/a", id: "1", isDir: true})
+	pc.put(pathEntry{path: "/b", id: "2"})
+	_, ok := pc.get("/a")
+	require.True(t, ok)
+	pc.put(pathEntry{path: "/c", id: "3"})
+
+	_, ok = pc.get("/b")
+	assert.False(t, ok, "least recently used")
+	_, ok = pc.get("/a")
+	assert.True(t, ok)
+
+	pc.put(pathEntry{path: "/a/d", id: "4"})
+	pc.invalidate("1")
+	_, ok = pc.get("/a/d")
+	assert.False(t, ok, "below an invalidated directory")
+	assert.Nil(t, newPathCache(0))
+}
+
+func TestResolvePath(t *testing.T) {
+	srv, c, inst := newListerTestClient(t, 0)
+	dir := srv.MkdirAll("a/b")
+	f := srv.AddFile(dir.ID, "c.txt", []byte("c"))
+
+	id, isDir, err := c.ResolvePath("/a/b")
+	require.NoError(t, err)
+	assert.Equal(t, dir.ID, id)
+	assert.True(t, isDir)
+
+	id, isDir, err = c.ResolvePath("a/b/c.txt")
+	require.NoError(t, err)
+	assert.Equal(t, f.ID, id)
+	assert.False(t, isDir)
+
+	id, _, err = c.ResolvePath("/")
+	require.NoError(t, err)
+	assert.Equal(t, "0", id)
+
+	calls := inst.count("DirName2CID") + inst.count("FileList")
+	_, _, err = c.ResolvePath("/a/b/c.txt")
+	require.NoError(t, err)
+	assert.Equal(t, calls, inst.count("DirName2CID")+inst.count("FileList"), "served from the cache")
+
+	_, _, err = c.ResolvePath("/a/missing")
+	assert.ErrorIs(t, err, ErrNotExist)
+	_, _, err = c.ResolvePath("/a/b/c.txt/d")
+	assert.ErrorIs(t, err, ErrNotExist)
+}
+
+func TestPathCacheInvalidation(t *testing.T) {
+	srv, c, _ := newListerTestClient(t, 0)
+	a := srv.MkdirAll("a")
+	b := srv.MkdirAll("b")
+	f := srv.AddFile(a.ID, "f.txt", []byte("f"))
+
+	_, _, err := c.ResolvePath("/a/f.txt")
+	require.NoError(t, err)
+	require.NoError(t, c.Rename(f.ID, "g.txt"))
+	_, _, err = c.ResolvePath("/a/f.txt")
+	assert.ErrorIs(t, err, ErrNotExist)
+
+	_, _, err = c.ResolvePath("/a/g.txt")
+	require.NoError(t, err)
+	require.NoError(t, c.Move(b.ID, a.ID))
+	_, _, err = c.ResolvePath("/a/g.txt")
+	assert.ErrorIs(t, err, ErrNotExist)
+	id, _, err := c.ResolvePath("/b/a/g.txt")
+	require.NoError(t, err)
+	assert.Equal(t, f.ID, id)
+
+	require.NoError(t, c.Delete(f.ID))
+	_, _, err = c.ResolvePath("/b/a/g.txt")
+	assert.ErrorIs(t, err, ErrNotExist)
+
+	dirID, err := c.Mkdir(a.ID, "new")
+	require.NoError(t, err)
+	got, ok := c.paths.get("/b/a/new")
+	require.True(t, ok, "cached under the path of its parent")
+	assert.Equal(t, dirID, got.id)
+}
+
+func TestStatPath(t *testing.T) {
+	srv, c, inst := newListerTestClient(t, 0)
+	dir := srv.MkdirAll("docs")
+	f := srv.AddFile(dir.ID, "readme.md", []byte("hello"))
+
+	file, err := c.StatPath("/docs/readme.md")
+	require.NoError(t, err)
+	assert.Equal(t, f.ID, file.FileID)
+	assert.Equal(t, int64(5), file.GetSize())
+
+	file, err = c.StatPath("/docs/readme.md")
+	require.NoError(t, err, "from the cached ID")
+	assert.Equal(t, "readme.md", file.Name)
+
+	file, err = c.StatPath("/docs")
+	require.NoError(t, err)
+	assert.True(t, file.IsDir())
+	assert.Equal(t, dir.ID, file.FileID)
+
+	lists := inst.count("FileList")
+	file, err = c.StatPath("/docs")
+	require.NoError(t, err)
+	assert.True(t, file.IsDir())
+	file, err = c.StatPath("/docs/readme.md")
+	require.NoError(t, err)
+	assert.Equal(t, f.ID, file.FileID)
+	assert.Equal(t, lists, inst.count("FileList"), "cached paths are not listed")
+
+	require.NoError(t, c.Rename(dir.ID, "notes"))
+	_, err = c.StatPath("/docs")
+	assert.ErrorIs(t, err, ErrNotExist)
+
+	file, err = c.StatPath("/")
+	require.NoError(t, err)
+	assert.Equal(t, "0", file.FileID)
+
+	_, err = c.StatPath("/docs/missing")
+	assert.ErrorIs(t, err, ErrNotExist)
+}
+
+func TestMkdirAllAndRemovePath(t *testing.T) {
+	srv, c, _ := newListerTestClient(t, 0)
+	a := srv.MkdirAll("a")
+	srv.AddFile(a.ID, "file", nil)
+
+	id, err := c.MkdirAll("/a/b/c")
+	require.NoError(t, err)
+	got, ok := srv.Get(id)
+	require.True(t, ok)
+	assert.Equal(t, "c", got.Name)
+
+	again, err := c.MkdirAll("a/b/c/")
+	require.NoError(t, err)
+	assert.Equal(t, id, again)
+
+	_, err = c.MkdirAll("/a/file/d")
+	assert.ErrorIs(t, err, ErrExist)
+
+	require.NoError(t, c.RemovePath("/a/b"))
+	_, ok = srv.Get(id)
+	assert.False(t, ok)
+	_, _, err = c.ResolvePath("/a/b/c")
+	assert.ErrorIs(t, err, ErrNotExist)
+
+	assert.ErrorIs(t, c.RemovePath("/"), ErrWrongParams)
+	assert.ErrorIs(t, c.RemovePath("/missing"), ErrNotExist)
+}
+
+func TestRemovePathChangedBehindCache(t *testing.T) {
+	srv, c, _ := newListerTestClient(t, 0)
+	a := srv.MkdirAll("a")
+	b := srv.MkdirAll("b")
+	f := srv.AddFile(a.ID, "f.txt", []byte("f"))
+	g := srv.AddFile(a.ID, "g.txt", []byte("g"))
+	_, _, err := c.ResolvePath("/a/f.txt")
+	require.NoError(t, err)
+	_, _, err = c.ResolvePath("/a/g.txt")
+	require.NoError(t, err)
+
+	// another client replaces f.txt and moves g.txt away
+	other := New(UA(), WithBaseHost(srv.URL))
+	require.NoError(t, other.Rename(f.ID, "old.txt"))
+	replaced := srv.AddFile(a.ID, "f.txt", []byte("new"))
+	require.NoError(t, other.Move(b.ID, g.ID))
+
+	require.NoError(t, c.RemovePath("/a/f.txt"))
+	_, ok := srv.Get(replaced.ID)
+	assert.False(t, ok, "the file now at the path is removed")
+	_, ok = srv.Get(f.ID)
+	assert.True(t, ok, "the renamed file is kept")
+
+	assert.ErrorIs(t, c.RemovePath("/a/g.txt"), ErrNotExist)
+	_, ok = srv.Get(g.ID)
+	assert.True(t, ok, "the moved file is kept")
+}
+
+func TestPathCacheDisabled(t *testing.T) {
+	srv := fake115.NewServer()
+	t.Cleanup(srv.Close)
+	dir := srv.MkdirAll("x")
+	c := New(UA(), WithBaseHost(srv.URL), WithPathCacheSize(0))
+
+	for i := 0; i < 2; i++ {
+		id, _, err := c.ResolvePath("/x")
+		require.NoError(t, err, fmt.Sprint(i))
+		assert.Equal(t, dir.ID, id)
+	}
+	assert.Nil(t, c.paths)
+}
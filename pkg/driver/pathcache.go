@@ -0,0 +1,127 @@
+package driver
+
+import (
+	"container/list"
+	"path"
+	"strings"
+	"sync"
+)
+
+// DefaultPathCacheSize is the number of paths cached by a client, see WithPathCacheSize.
+const DefaultPathCacheSize = 1024
+
+// pathEntry is a cached path.
+type pathEntry struct {
+	path  string
+	id    string
+	isDir bool
+}
+
+// pathCache is a bounded LRU cache of the IDs of paths, safe for concurrent
+// use. A nil *pathCache caches nothing.
+type pathCache struct {
+	mu     sync.Mutex
+	size   int
+	ll     *list.List
+	byPath map[string]*list.Element
+}
+
+func newPathCache(size int) *pathCache {
+	if size <= 0 {
+		return nil
+	}
+	return &pathCache{
+		size:   size,
+		ll:     list.New(),
+		byPath: map[string]*list.Element{},
+	}
+}
+
+func (pc *pathCache) get(p string) (pathEntry, bool) {
+	if pc == nil {
+		return pathEntry{}, false
+	}
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	if el, ok := pc.byPath[p]; ok {
+		pc.ll.MoveToFront(el)
+		return el.Value.(pathEntry), true
+	}
+	return pathEntry{}, false
+}
+
+func (pc *pathCache) put(e pathEntry) {
+	if pc == nil {
+		return
+	}
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	if el, ok := pc.byPath[e.path]; ok {
+		el.Value = e
+		pc.ll.MoveToFront(el)
+		return
+	}
+	pc.byPath[e.path] = pc.ll.PushFront(e)
+	for pc.ll.Len() > pc.size {
+		el := pc.ll.Back()
+		pc.ll.Remove(el)
+		delete(pc.byPath, el.Value.(pathEntry).path)
+	}
+}
+
+// pathOf returns the cached path of the entry id.
+func (pc *pathCache) pathOf(id string) (string, bool) {
+	if pc == nil {
+		return "", false
+	}
+	if id == "0" {
+		return "/", true
+	}
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	for el := pc.ll.Front(); el != nil; el = el.Next() {
+		if e := el.Value.(pathEntry); e.id == id {
+			return e.path, true
+		}
+	}
+	return "", false
+}
+
+// invalidate removes the paths of the entries ids, and the paths below them.
+func (pc *pathCache) invalidate(ids ...string) {
+	if pc == nil {
+		return
+	}
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	remove := map[string]bool{}
+	for _, id := range ids {
+		remove[id] = true
+	}
+	var prefixes []string
+	for el := pc.ll.Front(); el != nil; el = el.Next() {
+		if e := el.Value.(pathEntry); remove[e.id] {
+			prefixes = append(prefixes, e.path)
+		}
+	}
+	if len(prefixes) == 0 {
+		return
+	}
+	for el := pc.ll.Front(); el != nil; {
+		next := el.Next()
+		e := el.Value.(pathEntry)
+		for _, prefix := range prefixes {
+			if e.path == prefix || strings.HasPrefix(e.path, prefix+"/") {
+				pc.ll.Remove(el)
+				delete(pc.byPath, e.path)
+				break
+			}
+		}
+		el = next
+	}
+}
+
+// cleanPath returns the canonical form of the remote path p, rooted at "/".
+func cleanPath(p string) string {
+	return path.Clean("/" + p)
+}
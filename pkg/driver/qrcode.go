@@ -1,6 +1,7 @@
 package driver
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 
@@ -24,17 +25,27 @@ func (s *QRCodeSession) QRCode() ([]byte, error) {
 
 // QRCodeByApi get QRCode matrix or image by api.
 func (s *QRCodeSession) QRCodeByApi() ([]byte, error) {
-	resp, err := resty.New().R().Get(fmt.Sprintf(ApiQrcodeImage, s.UID))
+	return s.QRCodeByApiCtx(context.Background())
+}
+
+// QRCodeByApiCtx is like QRCodeByApi but uses ctx for the request.
+func (s *QRCodeSession) QRCodeByApiCtx(ctx context.Context) ([]byte, error) {
+	resp, err := resty.New().R().SetContext(ctx).Get(fmt.Sprintf(ApiQrcodeImage, s.UID))
 	return resp.Body(), err
 }
 
 // QRCodeStart starts a QRCode login session.
 func (c *Pan115Client) QRCodeStart() (*QRCodeSession, error) {
+	return c.QRCodeStartCtx(context.Background())
+}
+
+// QRCodeStartCtx is like QRCodeStart but uses ctx for the request.
+func (c *Pan115Client) QRCodeStartCtx(ctx context.Context) (*QRCodeSession, error) {
 	result := QRCodeTokenResp{}
-	resp, err := c.NewRequest().
+	req := c.newRequest(ctx).
 		SetResult(&result).
-		ForceContentType("application/json;charset=UTF-8").
-		Get(ApiQrcodeToken)
+		ForceContentType("application/json;charset=UTF-8")
+	resp, err := c.get(req, c.endpoints().QrcodeToken)
 
 	if err = CheckErr(err, &result, resp); err != nil {
 		return nil, err
@@ -48,6 +59,11 @@ func (c *Pan115Client) QRCodeLogin(s *QRCodeSession) (*Credential, error) {
 	return c.QRCodeLoginWithApp(s, LoginAppWeb)
 }
 
+// QRCodeLoginCtx is like QRCodeLogin but uses ctx for the request.
+func (c *Pan115Client) QRCodeLoginCtx(ctx context.Context, s *QRCodeSession) (*Credential, error) {
+	return c.QRCodeLoginWithAppCtx(ctx, s, LoginAppWeb)
+}
+
 type LoginApp string
 
 const (
@@ -66,15 +82,20 @@ const (
 // QRCodeLoginWithApp logins user through QRCode with specified app.
 // You SHOULD call this method ONLY when `QRCodeStatus.IsAllowed()` is true.
 func (c *Pan115Client) QRCodeLoginWithApp(s *QRCodeSession, app LoginApp) (*Credential, error) {
+	return c.QRCodeLoginWithAppCtx(context.Background(), s, app)
+}
+
+// QRCodeLoginWithAppCtx is like QRCodeLoginWithApp but uses ctx for the request.
+func (c *Pan115Client) QRCodeLoginWithAppCtx(ctx context.Context, s *QRCodeSession, app LoginApp) (*Credential, error) {
 	result := QRCodeLoginResp{}
-	req := c.NewRequest().
+	req := c.newRequest(ctx).
 		SetFormData(map[string]string{
 			"account": s.UID,
 			"app":     string(app),
 		}).
 		ForceContentType("application/json;charset=UTF-8").
 		SetResult(&result)
-	resp, err := req.Post(fmt.Sprintf(ApiQrcodeLoginWithApp, app))
+	resp, err := c.post(req, fmt.Sprintf(c.endpoints().QrcodeLoginWithApp, app))
 	if err = CheckErr(err, &result, resp); err != nil {
 		return nil, err
 	}
@@ -118,8 +139,13 @@ There are 4 possible status values:
 - Canceled
 */
 func (c *Pan115Client) QRCodeStatus(s *QRCodeSession) (*QRCodeStatus, error) {
+	return c.QRCodeStatusCtx(context.Background(), s)
+}
+
+// QRCodeStatusCtx is like QRCodeStatus but uses ctx for the request.
+func (c *Pan115Client) QRCodeStatusCtx(ctx context.Context, s *QRCodeSession) (*QRCodeStatus, error) {
 	result := QRCodeStatusResp{}
-	req := c.NewRequest().
+	req := c.newRequest(ctx).
 		SetQueryParams(map[string]string{
 			"uid":  s.UID,
 			"time": strconv.FormatInt(s.Time, 10),
@@ -129,7 +155,7 @@ func (c *Pan115Client) QRCodeStatus(s *QRCodeSession) (*QRCodeStatus, error) {
 		ForceContentType("application/json;charset=UTF-8").
 		SetResult(&result)
 
-	resp, err := req.Get(ApiQrcodeStatus)
+	resp, err := c.get(req, c.endpoints().QrcodeStatus)
 	if err = CheckErr(err, &result, resp); err != nil {
 		return nil, err
 	}
@@ -0,0 +1,100 @@
+package driver
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// RateLimit is the rate of requests allowed to an API host.
+type RateLimit struct {
+	// Rate is the number of requests per second.
+	Rate float64
+	// Burst is the number of requests which can be sent at once.
+	Burst int
+}
+
+// DefaultRateLimits returns conservative limits for the 115 API hosts, low
+// enough for long running crawlers not to trip the anti-abuse protection.
+func DefaultRateLimits() map[string]RateLimit {
+	return map[string]RateLimit{
+		"webapi.115.com":  {Rate: 2, Burst: 5},
+		"web.api.115.com": {Rate: 2, Burst: 5},
+		"proapi.115.com":  {Rate: 2, Burst: 5},
+		"lixian.115.com":  {Rate: 1, Burst: 3},
+		"uplb.115.com":    {Rate: 2, Burst: 5},
+	}
+}
+
+// RateLimiter limits the rate of the API calls with a token bucket per host.
+// It is safe for concurrent use and may be shared by several clients.
+type RateLimiter struct {
+	mu       sync.Mutex
+	limiters map[string]*rate.Limiter
+}
+
+// NewRateLimiter creates a RateLimiter with limits keyed by host, as found in
+// the endpoint URLs, e.g. "webapi.115.com" or "127.0.0.1:8080". Requests to
+// other hosts are not limited. DefaultRateLimits is used when limits is nil.
+func NewRateLimiter(limits map[string]RateLimit) *RateLimiter {
+	if limits == nil {
+		limits = DefaultRateLimits()
+	}
+	l := &RateLimiter{limiters: make(map[string]*rate.Limiter, len(limits))}
+	for host, limit := range limits {
+		l.limiters[host] = rate.NewLimiter(rate.Limit(limit.Rate), limit.Burst)
+	}
+	return l
+}
+
+func (l *RateLimiter) limiter(host string) *rate.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limiters[host]
+}
+
+// Wait blocks until a request to host is allowed or ctx is done.
+func (l *RateLimiter) Wait(ctx context.Context, host string) error {
+	if lim := l.limiter(host); lim != nil {
+		return lim.Wait(ctx)
+	}
+	return nil
+}
+
+// Delay returns how long a request to host would currently wait.
+func (l *RateLimiter) Delay(host string) time.Duration {
+	lim := l.limiter(host)
+	if lim == nil {
+		return 0
+	}
+	r := lim.Reserve()
+	defer r.Cancel()
+	return r.Delay()
+}
+
+// SetLimit changes the limit of host, adding it if it was not limited.
+func (l *RateLimiter) SetLimit(host string, limit RateLimit) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if lim, ok := l.limiters[host]; ok {
+		lim.SetLimit(rate.Limit(limit.Rate))
+		lim.SetBurst(limit.Burst)
+		return
+	}
+	l.limiters[host] = rate.NewLimiter(rate.Limit(limit.Rate), limit.Burst)
+}
+
+// wait blocks until a request to rawURL is allowed by the client rate limiter.
+func (c *Pan115Client) wait(ctx context.Context, rawURL string) error {
+	if c.rateLimiter == nil {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil
+	}
+	return c.rateLimiter.Wait(ctx, u.Host)
+}
@@ -0,0 +1,63 @@
+package driver
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiterLimitsCalls(t *testing.T) {
+	srv, calls := failingServer(t, 0, nil)
+	u, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+	limiter := NewRateLimiter(map[string]RateLimit{u.Host: {Rate: 20, Burst: 1}})
+	c := New(UA(), WithBaseHost(srv.URL), WithRateLimiter(limiter))
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := c.List("0")
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(5), *calls)
+	assert.GreaterOrEqual(t, time.Since(start), 180*time.Millisecond)
+	assert.Positive(t, limiter.Delay(u.Host))
+}
+
+func TestRateLimiterUnknownHost(t *testing.T) {
+	limiter := NewRateLimiter(nil)
+	assert.Zero(t, limiter.Delay("example.com"))
+	for i := 0; i < 100; i++ {
+		require.NoError(t, limiter.Wait(context.Background(), "example.com"))
+	}
+	assert.Zero(t, limiter.Delay("webapi.115.com"))
+}
+
+func TestRateLimiterCanceled(t *testing.T) {
+	srv, calls := failingServer(t, 0, nil)
+	u, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+	limiter := NewRateLimiter(map[string]RateLimit{u.Host: {Rate: 0.1, Burst: 1}})
+	c := New(UA(), WithBaseHost(srv.URL), WithRateLimiter(limiter))
+
+	_, err = c.List("0")
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = c.ListCtx(ctx, "0")
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), *calls)
+
+	limiter.SetLimit(u.Host, RateLimit{Rate: 1000, Burst: 10})
+	assert.Less(t, limiter.Delay(u.Host), 10*time.Millisecond)
+}
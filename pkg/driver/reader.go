@@ -0,0 +1,356 @@
+package driver
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultReadAhead is the size of the read-ahead buffer of a DownloadReader.
+const DefaultReadAhead = 1 << 20
+
+// defaultDownloadClient streams the downloads of the DownloadInfo which were
+// not resolved by a client, reusing its connections.
+var defaultDownloadClient = &http.Client{}
+
+type ReaderOptions struct {
+	readAhead  int
+	httpClient *http.Client
+}
+
+func DefaultReaderOptions() *ReaderOptions {
+	return &ReaderOptions{readAhead: DefaultReadAhead}
+}
+
+type ReaderOption func(o *ReaderOptions)
+
+// WithReadAhead reads the content in chunks of size bytes, so small reads and
+// short forward seeks do not cost a request each. Zero disables the buffer.
+func WithReadAhead(size int) ReaderOption {
+	return func(o *ReaderOptions) {
+		o.readAhead = size
+	}
+}
+
+// WithReaderHTTPClient sends the download requests with client, instead of the
+// one of the Pan115Client which resolved the download URL.
+func WithReaderHTTPClient(client *http.Client) ReaderOption {
+	return func(o *ReaderOptions) {
+		o.httpClient = client
+	}
+}
+
+// DownloadReader streams the content of a download URL with Range requests.
+// It implements io.ReadSeekCloser, and io.ReaderAt which is safe to call
+// concurrently with the other methods.
+//
+// When the URL is refused because it expired, the reader of a DownloadInfo
+// returned by the client resolves a new one and retries once.
+type DownloadReader struct {
+	ctx       context.Context
+	client    *http.Client
+	resolve   func(ctx context.Context) (*DownloadInfo, error)
+	readAhead int
+
+	// infoMu guards info and size, shared with ReadAt.
+	infoMu sync.Mutex
+	info   *DownloadInfo
+	size   int64
+
+	off  int64
+	body io.ReadCloser
+	buf  *bufio.Reader
+}
+
+// Open returns a reader of the content of the file.
+func (info *DownloadInfo) Open(opts ...ReaderOption) (*DownloadReader, error) {
+	return info.OpenCtx(context.Background(), opts...)
+}
+
+// OpenCtx is like Open but uses ctx for the requests.
+func (info *DownloadInfo) OpenCtx(ctx context.Context, opts ...ReaderOption) (*DownloadReader, error) {
+	if info.Url.Url == "" {
+		return nil, ErrDownloadEmpty
+	}
+	o := DefaultReaderOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+	r := &DownloadReader{
+		ctx:       ctx,
+		client:    o.httpClient,
+		resolve:   info.resolve,
+		readAhead: o.readAhead,
+		info:      info,
+		size:      -1,
+	}
+	if info.FileSize > 0 {
+		r.size = int64(info.FileSize)
+	}
+	if r.client == nil {
+		r.client = info.httpClient
+	}
+	if r.client == nil {
+		r.client = defaultDownloadClient
+	}
+	return r, nil
+}
+
+// OpenFile returns a reader of the content of the file with pickCode.
+func (c *Pan115Client) OpenFile(pickCode string, opts ...ReaderOption) (*DownloadReader, error) {
+	return c.OpenFileCtx(context.Background(), pickCode, opts...)
+}
+
+// OpenFileCtx is like OpenFile but uses ctx for the requests.
+func (c *Pan115Client) OpenFileCtx(ctx context.Context, pickCode string, opts ...ReaderOption) (*DownloadReader, error) {
+	info, err := c.DownloadCtx(ctx, pickCode)
+	if err != nil {
+		return nil, err
+	}
+	return info.OpenCtx(ctx, opts...)
+}
+
+// Size returns the size of the content, requesting it when the download info
+// did not report it.
+func (r *DownloadReader) Size() (int64, error) {
+	if size := r.knownSize(); size >= 0 {
+		return size, nil
+	}
+	resp, err := r.request(r.ctx, 0, 0)
+	if errors.Is(err, io.EOF) {
+		return r.knownSize(), nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	_ = resp.Body.Close()
+	if size := r.knownSize(); size >= 0 {
+		return size, nil
+	}
+	return 0, errors.Wrap(ErrUnexpected, "download: unknown content length")
+}
+
+// Read implements io.Reader.
+func (r *DownloadReader) Read(p []byte) (int, error) {
+	if size := r.knownSize(); size >= 0 && r.off >= size {
+		return 0, io.EOF
+	}
+	if r.body == nil {
+		resp, err := r.request(r.ctx, r.off, -1)
+		if err != nil {
+			return 0, err
+		}
+		r.body = resp.Body
+		if r.readAhead > 0 {
+			r.buf = bufio.NewReaderSize(resp.Body, r.readAhead)
+		}
+	}
+	var (
+		n   int
+		err error
+	)
+	if r.buf != nil {
+		n, err = r.buf.Read(p)
+	} else {
+		n, err = r.body.Read(p)
+	}
+	r.off += int64(n)
+	if err != nil {
+		r.closeBody()
+	}
+	if size := r.knownSize(); err == io.EOF && size >= 0 && r.off < size {
+		// the connection was cut, the next read resumes from r.off
+		if n > 0 {
+			err = nil
+		} else {
+			err = io.ErrUnexpectedEOF
+		}
+	}
+	return n, err
+}
+
+// Seek implements io.Seeker. Seeking forward within the read-ahead buffer
+// keeps the current response, other seeks send a new Range request on the
+// next read.
+func (r *DownloadReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.off
+	case io.SeekEnd:
+		size, err := r.Size()
+		if err != nil {
+			return 0, err
+		}
+		offset += size
+	default:
+		return 0, errors.New("download: invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("download: negative position")
+	}
+	if offset == r.off {
+		return offset, nil
+	}
+	if skip := offset - r.off; r.buf != nil && skip > 0 && skip <= int64(r.buf.Buffered()) {
+		_, _ = r.buf.Discard(int(skip))
+	} else {
+		r.closeBody()
+	}
+	r.off = offset
+	return offset, nil
+}
+
+// ReadAt implements io.ReaderAt with a Range request of len(p) bytes. When
+// the connection is cut before the end of the range, the rest is requested
+// again as long as some content comes in. It returns io.EOF only at the end of
+// the content.
+func (r *DownloadReader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("download: negative offset")
+	}
+	n := 0
+	for n < len(p) {
+		resp, err := r.request(r.ctx, off+int64(n), off+int64(len(p))-1)
+		if err != nil {
+			return n, err
+		}
+		m, err := io.ReadFull(resp.Body, p[n:])
+		_ = resp.Body.Close()
+		n += m
+		if err == nil {
+			break
+		}
+		if err != io.EOF && err != io.ErrUnexpectedEOF {
+			return n, err
+		}
+		if size := r.knownSize(); size < 0 || off+int64(n) >= size {
+			return n, io.EOF
+		}
+		if m == 0 {
+			// cut before any content, requesting it again would not help
+			return n, io.ErrUnexpectedEOF
+		}
+	}
+	return n, nil
+}
+
+// Close implements io.Closer.
+func (r *DownloadReader) Close() error {
+	r.closeBody()
+	return nil
+}
+
+func (r *DownloadReader) closeBody() {
+	if r.body != nil {
+		_ = r.body.Close()
+		r.body, r.buf = nil, nil
+	}
+}
+
+func (r *DownloadReader) knownSize() int64 {
+	r.infoMu.Lock()
+	defer r.infoMu.Unlock()
+	return r.size
+}
+
+func (r *DownloadReader) current() *DownloadInfo {
+	r.infoMu.Lock()
+	defer r.infoMu.Unlock()
+	return r.info
+}
+
+// refresh resolves a new download URL to replace the one of stale, unless
+// another request already did.
+func (r *DownloadReader) refresh(ctx context.Context, stale *DownloadInfo) (*DownloadInfo, error) {
+	r.infoMu.Lock()
+	defer r.infoMu.Unlock()
+	if r.info != stale {
+		return r.info, nil
+	}
+	info, err := r.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	r.info = info
+	return info, nil
+}
+
+// learnSize records the size of the content found in resp.
+func (r *DownloadReader) learnSize(resp *http.Response) {
+	size := int64(-1)
+	if cr := resp.Header.Get("Content-Range"); cr != "" {
+		if i := strings.LastIndexByte(cr, '/'); i >= 0 {
+			if n, err := strconv.ParseInt(cr[i+1:], 10, 64); err == nil {
+				size = n
+			}
+		}
+	} else if resp.StatusCode == http.StatusOK {
+		size = resp.ContentLength
+	}
+	if size < 0 {
+		return
+	}
+	r.infoMu.Lock()
+	defer r.infoMu.Unlock()
+	if r.size < 0 {
+		r.size = size
+	}
+}
+
+// request requests the content from off to end, or to the end of the file
+// when end is negative. It returns io.EOF when off is past the end.
+func (r *DownloadReader) request(ctx context.Context, off, end int64) (*http.Response, error) {
+	info := r.current()
+	for refreshed := false; ; refreshed = true {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, info.Url.Url, nil)
+		if err != nil {
+			return nil, err
+		}
+		if info.Header != nil {
+			req.Header = info.Header.Clone()
+		}
+		rng := "bytes=" + strconv.FormatInt(off, 10) + "-"
+		if end >= 0 {
+			rng += strconv.FormatInt(end, 10)
+		}
+		req.Header.Set("Range", rng)
+		resp, err := r.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case resp.StatusCode == http.StatusPartialContent,
+			resp.StatusCode == http.StatusOK && off == 0:
+			r.learnSize(resp)
+			return resp, nil
+		case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+			r.learnSize(resp)
+			_ = resp.Body.Close()
+			return nil, io.EOF
+		}
+		_ = resp.Body.Close()
+		apiErr := &APIError{
+			Message:    resp.Status,
+			Endpoint:   redactURL(info.Url.Url),
+			StatusCode: resp.StatusCode,
+			err:        ErrUnexpected,
+		}
+		if resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusGone {
+			apiErr.err = ErrDownloadURLExpired
+			if r.resolve != nil && !refreshed {
+				if info, err = r.refresh(ctx, info); err != nil {
+					return nil, err
+				}
+				continue
+			}
+		}
+		return nil, apiErr
+	}
+}
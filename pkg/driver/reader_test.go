@@ -0,0 +1,193 @@
+package driver
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/SheltonZhu/115driver/pkg/driver/fake115"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingTransport counts the requests sent and records their Range headers.
+type countingTransport struct {
+	mu     sync.Mutex
+	ranges []string
+	n      atomic.Int32
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.n.Add(1)
+	t.mu.Lock()
+	t.ranges = append(t.ranges, req.Header.Get("Range"))
+	t.mu.Unlock()
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func newReaderTestClient(t *testing.T, size int) (*fake115.Server, *Pan115Client, fake115.File, []byte) {
+	srv := fake115.NewServer()
+	t.Cleanup(srv.Close)
+	content := make([]byte, size)
+	for i := range content {
+		content[i] = byte(i % 251)
+	}
+	f := srv.AddFile(fake115.RootID, "video.mp4", content)
+	return srv, New(UA(), WithBaseHost(srv.URL), WithM115PublicKey(srv.PublicKey())), f, content
+}
+
+func TestOpenFile(t *testing.T) {
+	_, c, f, content := newReaderTestClient(t, 100_000)
+	tr := &countingTransport{}
+	r, err := c.OpenFile(f.PickCode, WithReaderHTTPClient(&http.Client{Transport: tr}), WithReadAhead(4096))
+	require.NoError(t, err)
+	defer r.Close()
+
+	buf := make([]byte, 10)
+	for i := 0; i < 100; i++ {
+		_, err := io.ReadFull(r, buf)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, content[990:1000], buf)
+	assert.EqualValues(t, 1, tr.n.Load(), "small reads are served from the read-ahead buffer")
+
+	_, err = r.Seek(1000, io.SeekCurrent)
+	require.NoError(t, err)
+	_, err = io.ReadFull(r, buf)
+	require.NoError(t, err)
+	assert.Equal(t, content[2000:2010], buf)
+	assert.EqualValues(t, 1, tr.n.Load(), "short forward seeks keep the response")
+
+	_, err = r.Seek(-10, io.SeekEnd)
+	require.NoError(t, err)
+	rest, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, content[len(content)-10:], rest)
+	assert.Equal(t, "bytes=99990-", tr.ranges[len(tr.ranges)-1])
+}
+
+func TestDownloadReaderReadAt(t *testing.T) {
+	_, c, f, content := newReaderTestClient(t, 10_000)
+	info, err := c.DownloadWithUAByAndroidAPI(f.PickCode, "")
+	require.NoError(t, err)
+	r, err := info.Open()
+	require.NoError(t, err)
+	defer r.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(off int64) {
+			defer wg.Done()
+			buf := make([]byte, 100)
+			n, err := r.ReadAt(buf, off)
+			assert.NoError(t, err)
+			assert.Equal(t, content[off:off+int64(n)], buf[:n])
+		}(int64(i) * 1000)
+	}
+	wg.Wait()
+
+	buf := make([]byte, 100)
+	n, err := r.ReadAt(buf, 9950)
+	assert.Equal(t, 50, n)
+	assert.ErrorIs(t, err, io.EOF)
+	_, err = r.ReadAt(buf, 20_000)
+	assert.ErrorIs(t, err, io.EOF)
+
+	size, err := r.Size()
+	require.NoError(t, err)
+	assert.EqualValues(t, 10_000, size, "learned from Content-Range")
+}
+
+// cutTransport cuts the bodies of the first cuts responses after at bytes.
+type cutTransport struct {
+	at   int64
+	cuts atomic.Int32
+}
+
+func (t *cutTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err == nil && t.cuts.Add(-1) >= 0 {
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.LimitReader(resp.Body, t.at), resp.Body}
+	}
+	return resp, err
+}
+
+func TestDownloadReaderReadAtCut(t *testing.T) {
+	_, c, f, content := newReaderTestClient(t, 100_000)
+	info, err := c.Download(f.PickCode)
+	require.NoError(t, err)
+	tr := &cutTransport{at: 20_000}
+	r, err := info.Open(WithReaderHTTPClient(&http.Client{Transport: tr}))
+	require.NoError(t, err)
+	defer r.Close()
+
+	tr.cuts.Store(2)
+	buf := make([]byte, 50_000)
+	n, err := r.ReadAt(buf, 10_000)
+	require.NoError(t, err, "the rest of the range is requested again")
+	assert.Equal(t, 50_000, n)
+	assert.Equal(t, content[10_000:60_000], buf)
+
+	tr.at = 0
+	tr.cuts.Store(1)
+	n, err = r.ReadAt(buf, 10_000)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF, "not the end of the content")
+	assert.Zero(t, n)
+
+	n, err = r.ReadAt(buf, 90_000)
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, 10_000, n)
+	assert.Equal(t, content[90_000:], buf[:n])
+}
+
+func TestDownloadReaderReresolvesExpiredURL(t *testing.T) {
+	srv, c, f, content := newReaderTestClient(t, 1000)
+	r, err := c.OpenFile(f.PickCode, WithReadAhead(0))
+	require.NoError(t, err)
+	defer r.Close()
+
+	buf := make([]byte, 10)
+	_, err = io.ReadFull(r, buf)
+	require.NoError(t, err)
+
+	srv.ExpireDownloadURLs()
+	_, err = r.Seek(500, io.SeekStart)
+	require.NoError(t, err)
+	_, err = io.ReadFull(r, buf)
+	require.NoError(t, err)
+	assert.Equal(t, content[500:510], buf)
+
+	srv.ExpireDownloadURLs()
+	info := &DownloadInfo{Url: FileDownloadUrl{Url: r.current().Url.Url}, FileSize: 1000}
+	plain, err := info.Open()
+	require.NoError(t, err)
+	_, err = plain.Read(buf)
+	assert.ErrorIs(t, err, ErrDownloadURLExpired, "no client to resolve a new URL")
+}
+
+func TestDownloadInfoGetBuffers(t *testing.T) {
+	srv, c, f, content := newReaderTestClient(t, 5000)
+	info, err := c.Download(f.PickCode)
+	require.NoError(t, err)
+	rs, err := info.Get()
+	require.NoError(t, err)
+	assert.IsType(t, &bytes.Reader{}, rs, "nothing to close")
+
+	var out bytes.Buffer
+	_, err = io.Copy(&out, rs)
+	require.NoError(t, err)
+	assert.Equal(t, content, out.Bytes())
+
+	// the errors surface at Get
+	srv.ExpireDownloadURLs()
+	plain := &DownloadInfo{Url: info.Url, Header: info.Header}
+	_, err = plain.Get()
+	assert.ErrorIs(t, err, ErrDownloadURLExpired)
+}
@@ -1,6 +1,7 @@
 package driver
 
 import (
+	"context"
 	"fmt"
 	"net/url"
 	"strconv"
@@ -8,25 +9,35 @@ import (
 
 // CleanRecycleBin clean the recycle bin
 func (c *Pan115Client) CleanRecycleBin(password string, rIDs ...string) error {
+	return c.CleanRecycleBinCtx(context.Background(), password, rIDs...)
+}
+
+// CleanRecycleBinCtx is like CleanRecycleBin but uses ctx for the request.
+func (c *Pan115Client) CleanRecycleBinCtx(ctx context.Context, password string, rIDs ...string) error {
 	form := url.Values{}
 	form.Set("password", password)
 	for idx, rID := range rIDs {
 		form.Add(fmt.Sprintf("rid[%d]", idx), rID)
 	}
 	result := BasicResp{}
-	req := c.NewRequest().
+	req := c.newRequest(ctx).
 		SetFormDataFromValues(form).
 		SetResult(&result).
 		ForceContentType("application/json;charset=UTF-8")
 
-	resp, err := req.Post(ApiRecycleClean)
+	resp, err := c.post(req, c.endpoints().RecycleClean)
 	return CheckErr(err, &result, resp)
 }
 
 // ListRecycleBin list the recycle bin
 func (c *Pan115Client) ListRecycleBin(offset, limit int) ([]RecycleBinItem, error) {
+	return c.ListRecycleBinCtx(context.Background(), offset, limit)
+}
+
+// ListRecycleBinCtx is like ListRecycleBin but uses ctx for the request.
+func (c *Pan115Client) ListRecycleBinCtx(ctx context.Context, offset, limit int) ([]RecycleBinItem, error) {
 	result := RecycleListResponse{}
-	req := c.NewRequest().
+	req := c.newRequest(ctx).
 		SetQueryParams(map[string]string{
 			"aid":    "7",
 			"cid":    "0",
@@ -37,7 +48,7 @@ func (c *Pan115Client) ListRecycleBin(offset, limit int) ([]RecycleBinItem, erro
 		SetResult(&result).
 		ForceContentType("application/json;charset=UTF-8")
 
-	resp, err := req.Get(ApiRecycleList)
+	resp, err := c.get(req, c.endpoints().RecycleList)
 	err = CheckErr(err, &result, resp)
 	if err != nil {
 		return nil, err
@@ -61,16 +72,21 @@ type RecycleBinItem struct {
 
 // RevertRecycleBin revert the recycle bin
 func (c *Pan115Client) RevertRecycleBin(rIDs ...string) error {
+	return c.RevertRecycleBinCtx(context.Background(), rIDs...)
+}
+
+// RevertRecycleBinCtx is like RevertRecycleBin but uses ctx for the request.
+func (c *Pan115Client) RevertRecycleBinCtx(ctx context.Context, rIDs ...string) error {
 	form := url.Values{}
 	for idx, rID := range rIDs {
 		form.Add(fmt.Sprintf("rid[%d]", idx), rID)
 	}
 	result := BasicResp{}
-	req := c.NewRequest().
+	req := c.newRequest(ctx).
 		SetFormDataFromValues(form).
 		SetResult(&result).
 		ForceContentType("application/json;charset=UTF-8")
 
-	resp, err := req.Post(ApiRecycleRevert)
+	resp, err := c.post(req, c.endpoints().RecycleRevert)
 	return CheckErr(err, &result, resp)
 }
@@ -2,6 +2,7 @@ package driver
 
 import (
 	"encoding/json"
+	"strconv"
 	"time"
 )
 
@@ -24,10 +25,7 @@ func (resp *LoginResp) Err(respBody ...string) error {
 	if resp.State == 0 {
 		return nil
 	}
-	if len(respBody) > 0 {
-		return GetErr(resp.Code, respBody[0])
-	}
-	return GetErr(resp.Code)
+	return responseErr(resp.Code, findNonEmpty(resp.Message, resp.Error), respBody...)
 }
 
 type BasicResp struct {
@@ -44,10 +42,7 @@ func (resp *BasicResp) Err(respBody ...string) error {
 		return nil
 	}
 	nonZeroCode := findNonZero(int(resp.Errno), resp.ErrNo)
-	if len(respBody) > 0 {
-		return GetErr(nonZeroCode, respBody[0])
-	}
-	return GetErr(nonZeroCode)
+	return responseErr(nonZeroCode, findNonEmpty(resp.Error, resp.Msg), respBody...)
 }
 
 func findNonZero(code ...int) int {
@@ -59,6 +54,15 @@ func findNonZero(code ...int) int {
 	return 0
 }
 
+func findNonEmpty(s ...string) string {
+	for _, v := range s {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
 type MkdirResp struct {
 	BasicResp
 	AreaID IntString `json:"aid"`
@@ -181,7 +185,7 @@ func (r *UploadInitResp) Err(respBody ...string) error {
 	if r.ErrorCode == 0 || r.ErrorCode == 701 {
 		return nil
 	}
-	return GetErr(r.ErrorCode, r.ErrorMsg)
+	return responseErr(r.ErrorCode, r.ErrorMsg, respBody...)
 }
 
 // Ok if fastupload is successful will return true, otherwise return false
@@ -208,10 +212,10 @@ func (r *UploadOSSTokenResp) Err(respBody ...string) error {
 	if r.StatusCode == "200" {
 		return nil
 	}
-	if len(respBody) > 0 {
-		return GetErr(0, respBody[0])
-	}
-	return ErrUnexpected
+	// StatusCode is an HTTP status, not a 115 error code to map
+	e := newAPIError(0, "oss token status "+r.StatusCode, respBody...)
+	e.StatusCode, _ = strconv.Atoi(r.StatusCode)
+	return e
 }
 
 type DownloadResp struct {
@@ -301,10 +305,7 @@ func (resp *QRCodeBasicResp) Err(respBody ...string) error {
 	if resp.State == 1 {
 		return nil
 	}
-	if len(respBody) > 0 {
-		return GetErr(resp.Code, respBody[0])
-	}
-	return GetErr(resp.Code)
+	return responseErr(resp.Code, findNonEmpty(resp.Message, resp.Error), respBody...)
 }
 
 type QRCodeTokenResp struct {
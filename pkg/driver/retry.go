@@ -0,0 +1,298 @@
+package driver
+
+import (
+	"context"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"reflect"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+	"github.com/go-resty/resty/v2"
+	"github.com/pkg/errors"
+)
+
+// RetryPolicy controls how failed API calls are retried, see WithRetryPolicy.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts of a call, including the first one.
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry, doubled for every further retry.
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between two attempts, including one asked by Retry-After.
+	// Zero means no cap.
+	MaxDelay time.Duration
+	// Jitter randomises each delay by up to this fraction of it, between 0 and 1.
+	Jitter float64
+	// RetryNonIdempotent allows retrying calls which change state, like Delete,
+	// Move or Mkdir, which may then be applied twice.
+	RetryNonIdempotent bool
+	// Retryable classifies errors, IsRetryable is used when nil.
+	Retryable func(err error) bool
+	// OnAttempt is called after every failed attempt.
+	OnAttempt func(attempt RetryAttempt)
+}
+
+// RetryAttempt describes a failed attempt of an API call.
+type RetryAttempt struct {
+	// Method is the HTTP method of the call.
+	Method string
+	// Endpoint is the URL of the call.
+	Endpoint string
+	// Attempt is the number of the attempt, starting at 1.
+	Attempt int
+	// Err is the error of the attempt.
+	Err error
+	// Retry reports whether the call is attempted again after Delay.
+	Retry bool
+	// Delay is the wait before the next attempt.
+	Delay time.Duration
+}
+
+// DefaultRetryPolicy returns a policy making up to 3 attempts of idempotent
+// calls, waiting 500ms then 1s between them.
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   500 * time.Millisecond,
+		MaxDelay:    30 * time.Second,
+		Jitter:      0.2,
+	}
+}
+
+// retryableErrs are the sentinel errors of transient API failures.
+var retryableErrs = map[error]bool{
+	ErrTooManyRequests: true,
+}
+
+// IsRetryable reports whether err is a transient failure: a throttling or
+// server error response, an expired OSS token or a network error.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return retryableStatus(apiErr.StatusCode) || retryableErrs[apiErr.err]
+	}
+	var ossErr oss.ServiceError
+	if errors.As(err, &ossErr) {
+		return retryableStatus(ossErr.StatusCode) ||
+			ossErr.Code == "SecurityTokenExpired" || ossErr.Code == "InvalidAccessKeyId"
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF)
+}
+
+func retryableStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+}
+
+func (p *RetryPolicy) retryable(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return IsRetryable(err)
+}
+
+// maxBackoff bounds the exponential growth of the delay when MaxDelay is zero.
+const maxBackoff = 24 * time.Hour
+
+// backoff returns the delay before the attempt following attempt.
+func (p *RetryPolicy) backoff(attempt int, resp *resty.Response) time.Duration {
+	if resp != nil {
+		if d, ok := parseRetryAfter(resp.Header().Get("Retry-After")); ok {
+			return p.capDelay(d)
+		}
+	}
+	d := p.BaseDelay
+	for i := 1; i < attempt; i++ {
+		if (p.MaxDelay > 0 && d >= p.MaxDelay) || d >= maxBackoff {
+			break
+		}
+		d *= 2
+	}
+	if p.Jitter > 0 {
+		d += time.Duration(float64(d) * p.Jitter * (2*rand.Float64() - 1))
+	}
+	return p.capDelay(d)
+}
+
+func (p *RetryPolicy) capDelay(d time.Duration) time.Duration {
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		return p.MaxDelay
+	}
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+// parseRetryAfter parses a Retry-After header, in seconds or as an HTTP date.
+func parseRetryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return time.Until(t), true
+	}
+	return 0, false
+}
+
+// idempotent reports whether the call can be sent twice without side effects.
+func (c *Pan115Client) idempotent(method, url string) bool {
+	if method == resty.MethodGet || method == resty.MethodHead {
+		return true
+	}
+	// POST endpoints which only read
+	e := c.endpoints()
+	switch url {
+	case e.DownloadGetUrl, e.AndroidDownloadGetUrl, e.ListOfflineUrl, e.UploadInfo:
+		return true
+	}
+	return false
+}
+
+func (c *Pan115Client) get(req *resty.Request, url string) (*resty.Response, error) {
+	return c.execute(req, resty.MethodGet, url)
+}
+
+func (c *Pan115Client) post(req *resty.Request, url string) (*resty.Response, error) {
+	return c.execute(req, resty.MethodPost, url)
+}
+
+// execute sends req, retrying it according to the client retry policy, and
+// logs and instruments the call.
+func (c *Pan115Client) execute(req *resty.Request, method, url string) (*resty.Response, error) {
+	if !c.observed() {
+		resp, _, err := c.executeRetry(req, method, url)
+		return resp, err
+	}
+	ev := &RequestEvent{
+		Endpoint: c.endpointName(url),
+		Method:   method,
+		URL:      redactURL(url),
+		Start:    time.Now(),
+	}
+	if c.instrumentation != nil {
+		req.SetContext(c.instrumentation.RequestStart(req.Context(), ev))
+	}
+	resp, attempts, err := c.executeRetry(req, method, url)
+	ev.Duration = time.Since(ev.Start)
+	ev.Attempts = attempts
+	if resp != nil {
+		ev.StatusCode = resp.StatusCode()
+		ev.Bytes = resp.Size()
+	}
+	if ev.Err = callError(err, req, resp); ev.Err != nil {
+		var apiErr *APIError
+		if errors.As(ev.Err, &apiErr) {
+			ev.Errno = apiErr.Code
+		}
+	}
+	c.logCall(req.Context(), ev)
+	if c.instrumentation != nil {
+		c.instrumentation.RequestFinish(req.Context(), ev)
+	}
+	return resp, err
+}
+
+// executeRetry sends req according to the client retry policy and returns the
+// number of attempts made.
+func (c *Pan115Client) executeRetry(req *resty.Request, method, url string) (*resty.Response, int, error) {
+	p := c.retryPolicy
+	if p == nil || p.MaxAttempts <= 1 || !(p.RetryNonIdempotent || c.idempotent(method, url)) {
+		resp, err := c.send(req, method, url)
+		return resp, 1, err
+	}
+	for attempt := 1; ; attempt++ {
+		resetResult(req.Result)
+		resp, err := c.send(req, method, url)
+		callErr := callError(err, req, resp)
+		if callErr == nil {
+			return resp, attempt, err
+		}
+		a := RetryAttempt{
+			Method:   method,
+			Endpoint: url,
+			Attempt:  attempt,
+			Err:      callErr,
+			Retry:    attempt < p.MaxAttempts && p.retryable(callErr),
+		}
+		if a.Retry {
+			a.Delay = p.backoff(attempt, resp)
+		}
+		if p.OnAttempt != nil {
+			p.OnAttempt(a)
+		}
+		if !a.Retry {
+			return resp, attempt, err
+		}
+		if resp != nil && resp.RawBody() != nil {
+			_ = resp.RawBody().Close()
+		}
+		if err := sleepCtx(req.Context(), a.Delay); err != nil {
+			return resp, attempt, err
+		}
+	}
+}
+
+// send sends req once, when the client rate limiter allows it.
+func (c *Pan115Client) send(req *resty.Request, method, url string) (*resty.Response, error) {
+	if err := c.wait(req.Context(), url); err != nil {
+		return nil, err
+	}
+	return req.Execute(method, url)
+}
+
+// callError returns the error of a call, from the transport, the decoded
+// result or the HTTP status.
+func callError(err error, req *resty.Request, resp *resty.Response) error {
+	if err != nil {
+		return err
+	}
+	if result, ok := req.Result.(ResultWithErr); ok {
+		return CheckErr(nil, result, resp)
+	}
+	if retryableStatus(resp.StatusCode()) {
+		return &APIError{
+			Endpoint:   resp.Request.URL,
+			StatusCode: resp.StatusCode(),
+			err:        ErrUnexpected,
+		}
+	}
+	return nil
+}
+
+// resetResult zeroes the result struct of a request before it is sent again,
+// so fields of a failed attempt do not leak into the next one.
+func resetResult(result any) {
+	v := reflect.ValueOf(result)
+	if v.Kind() == reflect.Pointer && !v.IsNil() && v.Elem().Kind() == reflect.Struct {
+		v.Elem().Set(reflect.Zero(v.Elem().Type()))
+	}
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,137 @@
+package driver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testRetryPolicy(attempts *[]RetryAttempt) *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   time.Millisecond,
+		OnAttempt: func(a RetryAttempt) {
+			*attempts = append(*attempts, a)
+		},
+	}
+}
+
+// failingServer answers the first failures requests of every path with fail.
+func failingServer(t *testing.T, failures int32, fail http.HandlerFunc) (*httptest.Server, *int32) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) <= failures {
+			fail(w, r)
+			return
+		}
+		writeJSON(w, map[string]any{"state": true, "cid": r.URL.Query().Get("cid"), "count": 0})
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func serviceUnavailable(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusServiceUnavailable)
+}
+
+func TestRetryIdempotentCall(t *testing.T) {
+	var attempts []RetryAttempt
+	srv, calls := failingServer(t, 2, serviceUnavailable)
+	c := New(UA(), WithBaseHost(srv.URL), WithRetryPolicy(testRetryPolicy(&attempts)))
+
+	_, err := c.List("0")
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), *calls)
+	require.Len(t, attempts, 2)
+	assert.Equal(t, 1, attempts[0].Attempt)
+	assert.True(t, attempts[0].Retry)
+	assert.Equal(t, srv.URL+"/files", attempts[0].Endpoint)
+	assert.True(t, IsRetryable(attempts[0].Err))
+}
+
+func TestRetryGivesUpAfterMaxAttempts(t *testing.T) {
+	var attempts []RetryAttempt
+	srv, calls := failingServer(t, 10, serviceUnavailable)
+	c := New(UA(), WithBaseHost(srv.URL), WithRetryPolicy(testRetryPolicy(&attempts)))
+
+	_, err := c.List("0")
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusServiceUnavailable, apiErr.StatusCode)
+	assert.Equal(t, int32(3), *calls)
+	require.Len(t, attempts, 3)
+	assert.False(t, attempts[2].Retry)
+}
+
+func TestRetrySkipsNonIdempotentCall(t *testing.T) {
+	var attempts []RetryAttempt
+	srv, calls := failingServer(t, 1, serviceUnavailable)
+	policy := testRetryPolicy(&attempts)
+	c := New(UA(), WithBaseHost(srv.URL), WithRetryPolicy(policy))
+
+	assert.Error(t, c.Delete("1"))
+	assert.Equal(t, int32(1), *calls)
+
+	atomic.StoreInt32(calls, 0)
+	policy.RetryNonIdempotent = true
+	assert.NoError(t, c.Delete("1"))
+	assert.Equal(t, int32(2), *calls)
+}
+
+func TestRetryThrottledResponse(t *testing.T) {
+	var attempts []RetryAttempt
+	srv, calls := failingServer(t, 1, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "0")
+		writeJSON(w, map[string]any{"state": false, "errno": 0, "error": "请求过于频繁，请稍后再试"})
+	})
+	c := New(UA(), WithBaseHost(srv.URL), WithRetryPolicy(testRetryPolicy(&attempts)))
+
+	_, err := c.List("0")
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), *calls)
+	require.Len(t, attempts, 1)
+	assert.ErrorIs(t, attempts[0].Err, ErrTooManyRequests)
+	assert.Equal(t, time.Duration(0), attempts[0].Delay)
+}
+
+func TestRetryNotForPermanentErrors(t *testing.T) {
+	var attempts []RetryAttempt
+	srv, calls := failingServer(t, 1, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{"state": false, "errno": 50003, "error": "pickcode does not exist"})
+	})
+	c := New(UA(), WithBaseHost(srv.URL), WithRetryPolicy(testRetryPolicy(&attempts)))
+
+	_, err := c.Download("pickcode")
+	assert.ErrorIs(t, err, ErrPickCodeNotExist)
+	assert.Equal(t, int32(1), *calls)
+	require.Len(t, attempts, 1)
+	assert.False(t, attempts[0].Retry)
+}
+
+func TestRetryBackoff(t *testing.T) {
+	p := &RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: 300 * time.Millisecond}
+	assert.Equal(t, 100*time.Millisecond, p.backoff(1, nil))
+	assert.Equal(t, 200*time.Millisecond, p.backoff(2, nil))
+	assert.Equal(t, 300*time.Millisecond, p.backoff(3, nil))
+	assert.Equal(t, 300*time.Millisecond, p.backoff(60, nil))
+
+	resp := &resty.Response{RawResponse: &http.Response{Header: http.Header{"Retry-After": {"2"}}}}
+	assert.Equal(t, 300*time.Millisecond, p.backoff(1, resp))
+	p.MaxDelay = 0
+	assert.Equal(t, 2*time.Second, p.backoff(1, resp))
+	assert.Equal(t, 400*time.Millisecond, p.backoff(3, nil))
+	assert.Less(t, p.backoff(100, nil), 2*maxBackoff)
+
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		d := p.backoff(1, nil)
+		assert.GreaterOrEqual(t, d, 50*time.Millisecond)
+		assert.LessOrEqual(t, d, 150*time.Millisecond)
+	}
+}
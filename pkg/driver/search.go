@@ -1,6 +1,7 @@
 package driver
 
 import (
+	"context"
 	"strconv"
 )
 
@@ -90,6 +91,11 @@ type SearchResult struct {
 
 // Search searches for files using given options
 func (c *Pan115Client) Search(opts *SearchOption) (*SearchResult, error) {
+	return c.SearchCtx(context.Background(), opts)
+}
+
+// SearchCtx is like Search but uses ctx for the request.
+func (c *Pan115Client) SearchCtx(ctx context.Context, opts *SearchOption) (*SearchResult, error) {
 	result := FileListResp{}
 	params := map[string]string{
 		"aid":           "7",
@@ -148,12 +154,12 @@ func (c *Pan115Client) Search(opts *SearchOption) (*SearchResult, error) {
 		params["asc"] = strconv.Itoa(opts.Asc)
 	}
 
-	req := c.NewRequest().
+	req := c.newRequest(ctx).
 		SetQueryParams(params).
 		SetResult(&result).
 		ForceContentType("application/json;charset=UTF-8")
 
-	resp, err := req.Get(ApiFileSearch)
+	resp, err := c.get(req, c.endpoints().FileSearch)
 	if err = CheckErr(err, &result, resp); err != nil {
 		return nil, err
 	}
@@ -175,4 +181,4 @@ func (c *Pan115Client) Search(opts *SearchOption) (*SearchResult, error) {
 	}
 
 	return searchResult, nil
-}
\ No newline at end of file
+}
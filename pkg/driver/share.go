@@ -1,6 +1,7 @@
 package driver
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 )
@@ -23,6 +24,11 @@ func QueryOffset(offset int) Query {
 
 // GetShareSnapWithUA get share snap info with user agent
 func (c *Pan115Client) GetShareSnapWithUA(ua, shareCode, receiveCode, dirID string, Queries ...Query) (*ShareSnapResp, error) {
+	return c.GetShareSnapWithUACtx(context.Background(), ua, shareCode, receiveCode, dirID, Queries...)
+}
+
+// GetShareSnapWithUACtx is like GetShareSnapWithUA but uses ctx for the request.
+func (c *Pan115Client) GetShareSnapWithUACtx(ctx context.Context, ua, shareCode, receiveCode, dirID string, Queries ...Query) (*ShareSnapResp, error) {
 	result := ShareSnapResp{}
 	query := map[string]string{
 		"share_code":   shareCode,
@@ -38,7 +44,7 @@ func (c *Pan115Client) GetShareSnapWithUA(ua, shareCode, receiveCode, dirID stri
 		q(&query)
 	}
 
-	req := c.NewRequest().
+	req := c.newRequest(ctx).
 		SetQueryParams(query).
 		SetHeader("referer", BuildShareReferer(shareCode, receiveCode)).
 		ForceContentType("application/json;charset=UTF-8").
@@ -46,7 +52,7 @@ func (c *Pan115Client) GetShareSnapWithUA(ua, shareCode, receiveCode, dirID stri
 	if ua != "" {
 		req.SetHeader("User-Agent", ua)
 	}
-	resp, err := req.Get(ApiShareSnap)
+	resp, err := c.get(req, c.endpoints().ShareSnap)
 	if err := CheckErr(err, &result, resp); err != nil {
 		return nil, err
 	}
@@ -62,3 +68,8 @@ func BuildShareReferer(shareCode, receiveCode string) string {
 func (c *Pan115Client) GetShareSnap(shareCode, receiveCode, dirID string, Queries ...Query) (*ShareSnapResp, error) {
 	return c.GetShareSnapWithUA("", shareCode, receiveCode, dirID, Queries...)
 }
+
+// GetShareSnapCtx is like GetShareSnap but uses ctx for the request.
+func (c *Pan115Client) GetShareSnapCtx(ctx context.Context, shareCode, receiveCode, dirID string, Queries ...Query) (*ShareSnapResp, error) {
+	return c.GetShareSnapWithUACtx(ctx, "", shareCode, receiveCode, dirID, Queries...)
+}
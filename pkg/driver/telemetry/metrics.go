@@ -0,0 +1,216 @@
+package telemetry
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/SheltonZhu/115driver/pkg/driver"
+)
+
+// DefaultBuckets are the upper bounds of the latency histogram buckets.
+var DefaultBuckets = []time.Duration{
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// Histogram counts durations in buckets.
+type Histogram struct {
+	// Bounds are the inclusive upper bounds of the buckets.
+	Bounds []time.Duration `json:"bounds"`
+	// Counts has a count per bucket, plus one for durations above the last bound.
+	Counts []int64       `json:"counts"`
+	Count  int64         `json:"count"`
+	Sum    time.Duration `json:"sum"`
+}
+
+func newHistogram(bounds []time.Duration) Histogram {
+	return Histogram{Bounds: bounds, Counts: make([]int64, len(bounds)+1)}
+}
+
+func (h *Histogram) observe(d time.Duration) {
+	i := sort.Search(len(h.Bounds), func(i int) bool { return d <= h.Bounds[i] })
+	h.Counts[i]++
+	h.Count++
+	h.Sum += d
+}
+
+func (h Histogram) clone() Histogram {
+	h.Counts = append([]int64(nil), h.Counts...)
+	return h
+}
+
+// EndpointStats are the metrics of a 115 endpoint.
+type EndpointStats struct {
+	Requests int64 `json:"requests"`
+	// Errors counts the failed calls.
+	Errors int64 `json:"errors"`
+	// Errnos counts the failed calls by 115 error code.
+	Errnos map[int]int64 `json:"errnos,omitempty"`
+	// Retries counts the attempts made after the first ones.
+	Retries int64     `json:"retries"`
+	Bytes   int64     `json:"bytes"`
+	Latency Histogram `json:"latency"`
+}
+
+// UploadStats are the metrics of an upload phase.
+type UploadStats struct {
+	Count  int64 `json:"count"`
+	Errors int64 `json:"errors"`
+	// Rapid counts the files stored by the rapid phase.
+	Rapid   int64     `json:"rapid,omitempty"`
+	Latency Histogram `json:"latency"`
+}
+
+// Snapshot is a copy of Metrics.
+type Snapshot struct {
+	Endpoints  map[string]EndpointStats           `json:"endpoints"`
+	Uploads    map[driver.UploadPhase]UploadStats `json:"uploads"`
+	Parts      int64                              `json:"parts"`
+	PartErrors int64                              `json:"part_errors"`
+	PartBytes  int64                              `json:"part_bytes"`
+	// Downloads counts the resolved download URLs by API.
+	Downloads map[string]int64 `json:"downloads"`
+}
+
+// Metrics aggregates the events of a client. It is safe for concurrent use
+// and implements expvar.Var.
+type Metrics struct {
+	buckets []time.Duration
+
+	mu   sync.Mutex
+	snap Snapshot
+}
+
+// NewMetrics creates Metrics with latency histograms of DefaultBuckets.
+func NewMetrics() *Metrics {
+	return NewMetricsWithBuckets(DefaultBuckets)
+}
+
+// NewMetricsWithBuckets creates Metrics with latency histograms of the sorted
+// bucket upper bounds.
+func NewMetricsWithBuckets(buckets []time.Duration) *Metrics {
+	return &Metrics{
+		buckets: buckets,
+		snap: Snapshot{
+			Endpoints: map[string]EndpointStats{},
+			Uploads:   map[driver.UploadPhase]UploadStats{},
+			Downloads: map[string]int64{},
+		},
+	}
+}
+
+// Snapshot returns a copy of the current metrics.
+func (m *Metrics) Snapshot() Snapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.snap
+	s.Endpoints = make(map[string]EndpointStats, len(m.snap.Endpoints))
+	for k, v := range m.snap.Endpoints {
+		errnos := make(map[int]int64, len(v.Errnos))
+		for code, n := range v.Errnos {
+			errnos[code] = n
+		}
+		v.Errnos = errnos
+		v.Latency = v.Latency.clone()
+		s.Endpoints[k] = v
+	}
+	s.Uploads = make(map[driver.UploadPhase]UploadStats, len(m.snap.Uploads))
+	for k, v := range m.snap.Uploads {
+		v.Latency = v.Latency.clone()
+		s.Uploads[k] = v
+	}
+	s.Downloads = make(map[string]int64, len(m.snap.Downloads))
+	for k, v := range m.snap.Downloads {
+		s.Downloads[k] = v
+	}
+	return s
+}
+
+// String implements expvar.Var, returning the metrics as JSON.
+func (m *Metrics) String() string {
+	b, err := json.Marshal(m.Snapshot())
+	if err != nil {
+		return "{}"
+	}
+	return string(b)
+}
+
+func (m *Metrics) request(ev *driver.RequestEvent) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.snap.Endpoints[ev.Endpoint]
+	if !ok {
+		s.Latency = newHistogram(m.buckets)
+	}
+	s.Requests++
+	if ev.Err != nil {
+		s.Errors++
+		if ev.Errno != 0 {
+			if s.Errnos == nil {
+				s.Errnos = map[int]int64{}
+			}
+			s.Errnos[ev.Errno]++
+		}
+	}
+	if ev.Attempts > 1 {
+		s.Retries += int64(ev.Attempts - 1)
+	}
+	s.Bytes += ev.Bytes
+	s.Latency.observe(ev.Duration)
+	m.snap.Endpoints[ev.Endpoint] = s
+}
+
+func (m *Metrics) upload(ev *driver.UploadEvent) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.snap.Uploads[ev.Phase]
+	if !ok {
+		s.Latency = newHistogram(m.buckets)
+	}
+	s.Count++
+	if ev.Err != nil {
+		s.Errors++
+	}
+	if ev.Rapid {
+		s.Rapid++
+	}
+	s.Latency.observe(ev.Duration)
+	m.snap.Uploads[ev.Phase] = s
+}
+
+func (m *Metrics) part(ev *driver.PartEvent) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if ev.Err != nil {
+		m.snap.PartErrors++
+		return
+	}
+	m.snap.Parts++
+	m.snap.PartBytes += ev.Size
+}
+
+func (m *Metrics) download(ev *driver.DownloadEvent) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.snap.Downloads[ev.API]++
+}
@@ -0,0 +1,151 @@
+// Package telemetry records the spans and metrics of a Pan115Client.
+//
+// The Tracer and Span interfaces follow the OpenTelemetry API, so a tracer of
+// any tracing library can be plugged in with a thin wrapper, and Metrics keeps
+// request counts, error counts and latency histograms per 115 endpoint:
+//
+//	metrics := telemetry.NewMetrics()
+//	expvar.Publish("115driver", metrics)
+//	client := driver.New(driver.UA(),
+//		driver.WithInstrumentation(telemetry.New(tracer, metrics)),
+//	)
+package telemetry
+
+import (
+	"context"
+
+	"github.com/SheltonZhu/115driver/pkg/driver"
+)
+
+// Attr is a key-value attribute of a span.
+type Attr struct {
+	Key   string
+	Value any
+}
+
+// Span is an operation being traced.
+type Span interface {
+	// SetAttributes sets attributes of the span.
+	SetAttributes(attrs ...Attr)
+	// AddEvent records an event which occurred during the span.
+	AddEvent(name string, attrs ...Attr)
+	// RecordError marks the span as failed with err.
+	RecordError(err error)
+	// End completes the span.
+	End()
+}
+
+// Tracer starts spans.
+type Tracer interface {
+	// Start starts a span named name, child of the span in ctx if any, and
+	// returns a context holding it.
+	Start(ctx context.Context, name string, attrs ...Attr) (context.Context, Span)
+}
+
+// Span names.
+const (
+	SpanRequest = "115.request"
+	SpanUpload  = "115.upload."
+)
+
+// Instrumentation records the events of a client as spans of a Tracer and in
+// Metrics, either of which may be nil.
+type Instrumentation struct {
+	tracer  Tracer
+	metrics *Metrics
+}
+
+var _ driver.Instrumentation = (*Instrumentation)(nil)
+
+// New creates an Instrumentation for driver.WithInstrumentation.
+func New(tracer Tracer, metrics *Metrics) *Instrumentation {
+	return &Instrumentation{tracer: tracer, metrics: metrics}
+}
+
+type spanKey struct{}
+
+func (i *Instrumentation) start(ctx context.Context, name string, attrs ...Attr) context.Context {
+	if i.tracer == nil {
+		return ctx
+	}
+	ctx, span := i.tracer.Start(ctx, name, attrs...)
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// span returns the span started by the instrumentation in ctx.
+func span(ctx context.Context) Span {
+	s, _ := ctx.Value(spanKey{}).(Span)
+	return s
+}
+
+func end(span Span, err error, attrs ...Attr) {
+	if span == nil {
+		return
+	}
+	span.SetAttributes(attrs...)
+	if err != nil {
+		span.RecordError(err)
+	}
+	span.End()
+}
+
+// RequestStart implements driver.Instrumentation.
+func (i *Instrumentation) RequestStart(ctx context.Context, ev *driver.RequestEvent) context.Context {
+	return i.start(ctx, SpanRequest,
+		Attr{"115.endpoint", ev.Endpoint},
+		Attr{"http.method", ev.Method},
+		Attr{"http.url", ev.URL},
+	)
+}
+
+// RequestFinish implements driver.Instrumentation.
+func (i *Instrumentation) RequestFinish(ctx context.Context, ev *driver.RequestEvent) {
+	i.metrics.request(ev)
+	end(span(ctx), ev.Err,
+		Attr{"http.status_code", ev.StatusCode},
+		Attr{"115.errno", ev.Errno},
+		Attr{"115.attempts", ev.Attempts},
+		Attr{"http.response_content_length", ev.Bytes},
+	)
+}
+
+// UploadPhaseStart implements driver.Instrumentation.
+func (i *Instrumentation) UploadPhaseStart(ctx context.Context, ev *driver.UploadEvent) context.Context {
+	return i.start(ctx, SpanUpload+string(ev.Phase),
+		Attr{"115.file_name", ev.FileName},
+		Attr{"115.object", ev.Object},
+		Attr{"115.file_size", ev.FileSize},
+		Attr{"115.dir_id", ev.DirID},
+	)
+}
+
+// UploadPhaseFinish implements driver.Instrumentation.
+func (i *Instrumentation) UploadPhaseFinish(ctx context.Context, ev *driver.UploadEvent) {
+	i.metrics.upload(ev)
+	var attrs []Attr
+	if ev.Phase == driver.UploadPhaseRapid {
+		attrs = append(attrs, Attr{"115.rapid", ev.Rapid})
+	}
+	end(span(ctx), ev.Err, attrs...)
+}
+
+// PartUploaded implements driver.Instrumentation.
+func (i *Instrumentation) PartUploaded(ctx context.Context, ev *driver.PartEvent) {
+	i.metrics.part(ev)
+	if s := span(ctx); s != nil {
+		attrs := []Attr{
+			{"115.part_number", ev.PartNumber},
+			{"115.part_size", ev.Size},
+			{"115.attempts", ev.Attempts},
+		}
+		if ev.Err != nil {
+			attrs = append(attrs, Attr{"error", ev.Err.Error()})
+		}
+		s.AddEvent("part uploaded", attrs...)
+	}
+}
+
+// DownloadURLResolved implements driver.Instrumentation.
+func (i *Instrumentation) DownloadURLResolved(_ context.Context, ev *driver.DownloadEvent) {
+	i.metrics.download(ev)
+}
@@ -0,0 +1,142 @@
+package telemetry_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SheltonZhu/115driver/pkg/driver"
+	"github.com/SheltonZhu/115driver/pkg/driver/fake115"
+	"github.com/SheltonZhu/115driver/pkg/driver/telemetry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type span struct {
+	name   string
+	parent *span
+	attrs  map[string]any
+	events []string
+	err    error
+	ended  bool
+}
+
+func (s *span) SetAttributes(attrs ...telemetry.Attr) {
+	for _, a := range attrs {
+		s.attrs[a.Key] = a.Value
+	}
+}
+
+func (s *span) AddEvent(name string, _ ...telemetry.Attr) { s.events = append(s.events, name) }
+func (s *span) RecordError(err error)                     { s.err = err }
+func (s *span) End()                                      { s.ended = true }
+
+type spanCtxKey struct{}
+
+type tracer struct {
+	mu    sync.Mutex
+	spans []*span
+}
+
+func (t *tracer) Start(ctx context.Context, name string, attrs ...telemetry.Attr) (context.Context, telemetry.Span) {
+	parent, _ := ctx.Value(spanCtxKey{}).(*span)
+	s := &span{name: name, parent: parent, attrs: map[string]any{}}
+	s.SetAttributes(attrs...)
+	t.mu.Lock()
+	t.spans = append(t.spans, s)
+	t.mu.Unlock()
+	return context.WithValue(ctx, spanCtxKey{}, s), s
+}
+
+func TestInstrumentationRecordsRequests(t *testing.T) {
+	srv := fake115.NewServer()
+	defer srv.Close()
+	tr := &tracer{}
+	metrics := telemetry.NewMetrics()
+	c := driver.New(driver.UA(),
+		driver.WithBaseHost(srv.URL),
+		driver.WithM115PublicKey(srv.PublicKey()),
+		driver.WithInstrumentation(telemetry.New(tr, metrics)),
+	)
+	f := srv.AddFile("0", "a.txt", []byte("a"))
+
+	ctx, root := tr.Start(context.Background(), "root")
+	_, err := c.ListWithLimitCtx(ctx, "0", 10)
+	require.NoError(t, err)
+	_, err = c.DownloadCtx(ctx, f.PickCode)
+	require.NoError(t, err)
+	_, err = c.DownloadCtx(ctx, "missing")
+	require.Error(t, err)
+
+	require.Len(t, tr.spans, 4)
+	list := tr.spans[1]
+	assert.Equal(t, telemetry.SpanRequest, list.name)
+	assert.Same(t, root, list.parent)
+	assert.True(t, list.ended)
+	assert.Equal(t, "FileList", list.attrs["115.endpoint"])
+	assert.Equal(t, 200, list.attrs["http.status_code"])
+	assert.NoError(t, list.err)
+	failed := tr.spans[3]
+	assert.ErrorIs(t, failed.err, driver.ErrPickCodeNotExist)
+	assert.Equal(t, 50003, failed.attrs["115.errno"])
+
+	snap := metrics.Snapshot()
+	assert.Equal(t, int64(1), snap.Endpoints["FileList"].Requests)
+	assert.Equal(t, int64(1), snap.Endpoints["FileList"].Latency.Count)
+	download := snap.Endpoints["DownloadGetUrl"]
+	assert.Equal(t, int64(2), download.Requests)
+	assert.Equal(t, int64(1), download.Errors)
+	assert.Equal(t, int64(1), download.Errnos[50003])
+	assert.Equal(t, int64(1), snap.Downloads["web"])
+
+	var decoded telemetry.Snapshot
+	require.NoError(t, json.Unmarshal([]byte(metrics.String()), &decoded))
+	assert.Equal(t, int64(2), decoded.Endpoints["DownloadGetUrl"].Requests)
+}
+
+func TestInstrumentationRecordsUploads(t *testing.T) {
+	tr := &tracer{}
+	metrics := telemetry.NewMetricsWithBuckets([]time.Duration{time.Second})
+	inst := telemetry.New(tr, metrics)
+
+	ev := &driver.UploadEvent{Phase: driver.UploadPhaseRapid, FileName: "a.bin", FileSize: 10, Rapid: true}
+	inst.UploadPhaseFinish(inst.UploadPhaseStart(context.Background(), ev), ev)
+
+	ev = &driver.UploadEvent{Phase: driver.UploadPhaseMultipart, Object: "obj", FileSize: 10, Duration: 2 * time.Second}
+	ctx := inst.UploadPhaseStart(context.Background(), ev)
+	inst.PartUploaded(ctx, &driver.PartEvent{PartNumber: 1, Size: 6, Attempts: 1})
+	inst.PartUploaded(ctx, &driver.PartEvent{PartNumber: 2, Size: 4, Attempts: 3, Err: errors.New("boom")})
+	ev.Err = errors.New("boom")
+	inst.UploadPhaseFinish(ctx, ev)
+
+	require.Len(t, tr.spans, 2)
+	assert.Equal(t, "115.upload.rapid", tr.spans[0].name)
+	assert.Equal(t, true, tr.spans[0].attrs["115.rapid"])
+	multipart := tr.spans[1]
+	assert.Equal(t, "115.upload.multipart", multipart.name)
+	assert.Equal(t, []string{"part uploaded", "part uploaded"}, multipart.events)
+	assert.Error(t, multipart.err)
+
+	snap := metrics.Snapshot()
+	assert.Equal(t, int64(1), snap.Uploads[driver.UploadPhaseRapid].Rapid)
+	assert.Equal(t, int64(1), snap.Uploads[driver.UploadPhaseMultipart].Errors)
+	assert.Equal(t, []int64{0, 1}, snap.Uploads[driver.UploadPhaseMultipart].Latency.Counts)
+	assert.Equal(t, int64(1), snap.Parts)
+	assert.Equal(t, int64(6), snap.PartBytes)
+	assert.Equal(t, int64(1), snap.PartErrors)
+}
+
+func TestInstrumentationWithoutTracer(t *testing.T) {
+	srv := fake115.NewServer()
+	defer srv.Close()
+	metrics := telemetry.NewMetrics()
+	c := driver.New(driver.UA(), driver.WithBaseHost(srv.URL),
+		driver.WithInstrumentation(telemetry.New(nil, metrics)))
+
+	_, err := c.List("0")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), metrics.Snapshot().Endpoints["FileList"].Requests)
+}
@@ -1,7 +1,7 @@
 package driver
 
 import (
-	"bytes"
+	"context"
 	"crypto/md5"
 	"crypto/sha1"
 	"encoding/base64"
@@ -9,17 +9,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/url"
-	"os"
 	"strconv"
 	"strings"
-	"sync"
-	"time"
 
 	hash "github.com/SheltonZhu/115driver/pkg/crypto"
 	cipher "github.com/SheltonZhu/115driver/pkg/crypto/ec115"
 	"github.com/aliyun/aliyun-oss-go-sdk/oss"
-	"github.com/pkg/errors"
 )
 
 // GetDigestResult get digest of file or stream
@@ -30,10 +27,15 @@ func (c *Pan115Client) GetDigestResult(r io.Reader) (*hash.DigestResult, error)
 
 // GetUploadEndpoint get upload endPoint
 func (c *Pan115Client) GetUploadEndpoint(endpoint *UploadEndpointResp) error {
-	req := c.NewRequest().
+	return c.GetUploadEndpointCtx(context.Background(), endpoint)
+}
+
+// GetUploadEndpointCtx is like GetUploadEndpoint but uses ctx for the request.
+func (c *Pan115Client) GetUploadEndpointCtx(ctx context.Context, endpoint *UploadEndpointResp) error {
+	req := c.newRequest(ctx).
 		ForceContentType("application/json;charset=UTF-8").
 		SetResult(&endpoint)
-	_, err := req.Get(ApiGetUploadEndpoint)
+	_, err := c.get(req, c.endpoints().GetUploadEndpoint)
 	if err != nil {
 		return err
 	}
@@ -42,14 +44,21 @@ func (c *Pan115Client) GetUploadEndpoint(endpoint *UploadEndpointResp) error {
 
 // GetUploadInfo get some info for upload
 func (c *Pan115Client) GetUploadInfo() error {
+	return c.GetUploadInfoCtx(context.Background())
+}
+
+// GetUploadInfoCtx is like GetUploadInfo but uses ctx for the request.
+func (c *Pan115Client) GetUploadInfoCtx(ctx context.Context) error {
 	result := UploadInfoResp{}
-	req := c.NewRequest().
+	req := c.newRequest(ctx).
 		ForceContentType("application/json;charset=UTF-8").
 		SetResult(&result)
-	resp, err := req.Post(ApiUploadInfo)
+	resp, err := c.post(req, c.endpoints().UploadInfo)
 	if err = CheckErr(err, &result, resp); err != nil {
 		return err
 	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.Userkey = result.Userkey
 	c.UserID = result.UserID
 	c.UploadMetaInfo = &result.UploadMetaInfo
@@ -58,40 +67,79 @@ func (c *Pan115Client) GetUploadInfo() error {
 
 // UploadAvailable check and prepare to upload
 func (c *Pan115Client) UploadAvailable() (bool, error) {
-	if c.UserID != 0 && len(c.Userkey) > 0 {
+	return c.UploadAvailableCtx(context.Background())
+}
+
+// UploadAvailableCtx is like UploadAvailable but uses ctx for the request.
+// Concurrent callers share a single fetch of the upload metadata.
+func (c *Pan115Client) UploadAvailableCtx(ctx context.Context) (bool, error) {
+	if c.uploadInfoReady() {
 		return true, nil
 	}
-	if err := c.GetUploadInfo(); err != nil {
+	c.uploadInfoMu.Lock()
+	defer c.uploadInfoMu.Unlock()
+	if c.uploadInfoReady() {
+		return true, nil
+	}
+	if err := c.GetUploadInfoCtx(ctx); err != nil {
 		return false, err
 	}
 	return true, nil
 }
 
+func (c *Pan115Client) uploadInfoReady() bool {
+	userID, userkey, meta := c.uploadMeta()
+	return userID != 0 && len(userkey) > 0 && meta != nil
+}
+
+// uploadSizeLimit returns the max size of a single upload, 0 means unknown.
+func (c *Pan115Client) uploadSizeLimit() int64 {
+	if _, _, meta := c.uploadMeta(); meta != nil {
+		return meta.SizeLimit
+	}
+	return 0
+}
+
 // UploadFastOrByOSS Upload By OSS when unable to rapid upload file
 // Deprecated: As of v1.0.22, this function simply calls [RapidUploadOrByOSS].
-func (c *Pan115Client) UploadFastOrByOSS(dirID, fileName string, fileSize int64, r io.ReadSeeker) error {
-	return c.RapidUploadOrByOSS(dirID, fileName, fileSize, r)
+func (c *Pan115Client) UploadFastOrByOSS(dirID, fileName string, fileSize int64, r io.ReadSeeker, opts ...UploadOption) error {
+	return c.RapidUploadOrByOSS(dirID, fileName, fileSize, r, opts...)
 }
 
 // RapidUploadOrByOSS Upload By OSS when unable to rapid upload file
-func (c *Pan115Client) RapidUploadOrByOSS(dirID, fileName string, fileSize int64, r io.ReadSeeker) error {
+func (c *Pan115Client) RapidUploadOrByOSS(dirID, fileName string, fileSize int64, r io.ReadSeeker, opts ...UploadOption) error {
+	return c.RapidUploadOrByOSSCtx(context.Background(), dirID, fileName, fileSize, r, opts...)
+}
+
+// RapidUploadOrByOSSCtx is like RapidUploadOrByOSS but uses ctx for the requests and the OSS upload.
+func (c *Pan115Client) RapidUploadOrByOSSCtx(ctx context.Context, dirID, fileName string, fileSize int64, r io.ReadSeeker, opts ...UploadOption) error {
 	var (
 		err      error
 		digest   *hash.DigestResult
 		fastInfo *UploadInitResp
+		options  = DefaultUploadOptions()
 	)
+	for _, opt := range opts {
+		opt(options)
+	}
+	progress := options.tracker
+	if progress == nil {
+		progress = newUploadProgress(options.Progress, fileSize)
+	}
 
-	if ok, err := c.UploadAvailable(); err != nil || !ok {
+	if ok, err := c.UploadAvailableCtx(ctx); err != nil || !ok {
 		return err
 	}
-	if fileSize > c.UploadMetaInfo.SizeLimit {
+	if limit := c.uploadSizeLimit(); limit > 0 && fileSize > limit {
 		return ErrUploadTooLarge
 	}
-	if digest, err = c.GetDigestResult(r); err != nil {
+	progress.phase(UploadPhaseHashing)
+	if digest, err = c.GetDigestResult(progress.hashing(r)); err != nil {
 		return err
 	}
 	// 闪传
-	if fastInfo, err = c.RapidUpload(
+	progress.phase(UploadPhaseRapid)
+	if fastInfo, err = c.RapidUploadCtx(ctx,
 		digest.Size, fileName, dirID, digest.PreID, digest.QuickID, r,
 	); err != nil {
 		return err
@@ -105,16 +153,16 @@ func (c *Pan115Client) RapidUploadOrByOSS(dirID, fileName string, fileSize int64
 		return err
 	}
 	// 闪传失败，普通上传
-	return c.UploadByOSS(&fastInfo.UploadOSSParams, r, dirID)
+	return c.UploadByOSSCtx(ctx, &fastInfo.UploadOSSParams, r, dirID, uploadWithTracker(progress))
 }
 
 // getOSSEndpoint get oss endpoint 利用阿里云内网上传文件，需要在阿里云服务器上运行本程序，同时也需要115在服务器的所在地域开通了阿里云OSS
-func (c *Pan115Client) getOSSEndpoint(enableInternalUpload bool) string {
+func (c *Pan115Client) getOSSEndpoint(ctx context.Context, enableInternalUpload bool) string {
 	if enableInternalUpload {
 		uploadEndpoint := UploadEndpointResp{}
-		if err := c.GetUploadEndpoint(&uploadEndpoint); err != nil {
-			// TODO warn error log
-			return OSSEndpoint
+		if err := c.GetUploadEndpointCtx(ctx, &uploadEndpoint); err != nil {
+			c.logWarn(ctx, "get upload endpoint failed, fallback to public endpoint", err)
+			return c.endpoints().OSS
 		}
 		i := strings.Index(uploadEndpoint.Endpoint, ".aliyuncs.com")
 		if i > -1 {
@@ -122,46 +170,81 @@ func (c *Pan115Client) getOSSEndpoint(enableInternalUpload bool) string {
 			return endpoint
 		}
 	}
-	return OSSEndpoint
+	return c.endpoints().OSS
 }
 
 // GetOSSEndpoint get oss endpoint 利用阿里云内网上传文件，需要在阿里云服务器上运行本程序，同时也需要115在服务器的所在地域开通了阿里云OSS
 func (c *Pan115Client) GetOSSEndpoint(enableInternalUpload bool) string {
-	return c.getOSSEndpoint(enableInternalUpload)
+	return c.getOSSEndpoint(context.Background(), enableInternalUpload)
+}
+
+// GetOSSEndpointCtx is like GetOSSEndpoint but uses ctx for the request.
+func (c *Pan115Client) GetOSSEndpointCtx(ctx context.Context, enableInternalUpload bool) string {
+	return c.getOSSEndpoint(ctx, enableInternalUpload)
 }
 
 // UploadByOSS use aliyun sdk to upload
-func (c *Pan115Client) UploadByOSS(params *UploadOSSParams, r io.Reader, dirID string) error {
-	ossToken, err := c.GetOSSToken()
-	if err != nil {
-		return err
+func (c *Pan115Client) UploadByOSS(params *UploadOSSParams, r io.Reader, dirID string, opts ...UploadOption) error {
+	return c.UploadByOSSCtx(context.Background(), params, r, dirID, opts...)
+}
+
+// UploadByOSSCtx is like UploadByOSS but uses ctx for the requests and the OSS upload.
+func (c *Pan115Client) UploadByOSSCtx(ctx context.Context, params *UploadOSSParams, r io.Reader, dirID string, opts ...UploadOption) error {
+	options := DefaultUploadOptions()
+	for _, opt := range opts {
+		opt(options)
+	}
+	progress := options.tracker
+	if progress == nil {
+		progress = newUploadProgress(options.Progress, -1)
 	}
-	ossClient, err := oss.New(c.getOSSEndpoint(c.UseInternalUpload), ossToken.AccessKeyID, ossToken.AccessKeySecret)
+	ev := &UploadEvent{Phase: UploadPhaseOSS, Object: params.Object, FileSize: -1, DirID: dirID}
+	return c.uploadPhase(ctx, ev, func(ctx context.Context) error {
+		return c.uploadByOSS(ctx, params, r, dirID, progress)
+	})
+}
+
+func (c *Pan115Client) uploadByOSS(ctx context.Context, params *UploadOSSParams, r io.Reader, dirID string, progress *uploadProgress) error {
+	progress.phase(UploadPhaseOSS)
+	ossToken, err := c.GetOSSTokenCtx(ctx)
 	if err != nil {
 		return err
 	}
-	bucket, err := ossClient.Bucket(params.Bucket)
+	bucket, err := c.ossBucket(ctx, params.Bucket, ossToken)
 	if err != nil {
 		return err
 	}
 
-	if err = bucket.PutObject(params.Object, r, OssOption(params, ossToken)...); err != nil {
+	progressOpts, rollback := progress.ossOptions()
+	if err = bucket.PutObject(params.Object, r,
+		append(append(OssOption(params, ossToken), oss.WithContext(ctx)), progressOpts...)...); err != nil {
+		rollback()
 		return err
 	}
 
-	return c.checkUploadStatus(dirID, params.SHA1)
+	progress.phase(UploadPhaseVerify)
+	return c.checkUploadStatus(ctx, dirID, params.SHA1)
 }
 
-func (c *Pan115Client) checkUploadStatus(dirID, sha1 string) error {
+// ossBucket returns the OSS bucket name, signed in with ossToken.
+func (c *Pan115Client) ossBucket(ctx context.Context, name string, ossToken *UploadOSSTokenResp, opts ...oss.ClientOption) (*oss.Bucket, error) {
+	ossClient, err := oss.New(c.getOSSEndpoint(ctx, c.UseInternalUpload), ossToken.AccessKeyID, ossToken.AccessKeySecret, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return ossClient.Bucket(name)
+}
+
+func (c *Pan115Client) checkUploadStatus(ctx context.Context, dirID, sha1 string) error {
 	// 验证上传是否成功
-	req := c.NewRequest().ForceContentType("application/json;charset=UTF-8")
 	opts := []GetFileOptions{
+		WithApiURL(c.endpoints().FileList),
 		WithOrder(FileOrderByTime),
 		WithShowDirEnable(false),
 		WithAsc(false),
 		WithLimit(500),
 	}
-	fResp, err := GetFiles(req, dirID, opts...)
+	fResp, err := c.getFiles(ctx, dirID, opts...)
 	if err != nil {
 		return err
 	}
@@ -175,12 +258,17 @@ func (c *Pan115Client) checkUploadStatus(dirID, sha1 string) error {
 
 // GetOSSToken get oss token for oss upload
 func (c *Pan115Client) GetOSSToken() (*UploadOSSTokenResp, error) {
+	return c.GetOSSTokenCtx(context.Background())
+}
+
+// GetOSSTokenCtx is like GetOSSToken but uses ctx for the request.
+func (c *Pan115Client) GetOSSTokenCtx(ctx context.Context) (*UploadOSSTokenResp, error) {
 	result := UploadOSSTokenResp{}
-	req := c.NewRequest().
+	req := c.newRequest(ctx).
 		ForceContentType("application/json;charset=UTF-8").
 		SetResult(&result)
 
-	resp, err := req.Get(ApiUploadOSSToken)
+	resp, err := c.get(req, c.endpoints().UploadOSSToken)
 	return &result, CheckErr(err, &result, resp)
 }
 
@@ -192,6 +280,23 @@ func (c *Pan115Client) UploadSHA1(fileSize int64, fileName, dirID, preID, fileID
 
 // RapidUpload rapid upload
 func (c *Pan115Client) RapidUpload(fileSize int64, fileName, dirID, preID, fileID string, r io.ReadSeeker) (*UploadInitResp, error) {
+	return c.RapidUploadCtx(context.Background(), fileSize, fileName, dirID, preID, fileID, r)
+}
+
+// RapidUploadCtx is like RapidUpload but uses ctx for the requests.
+func (c *Pan115Client) RapidUploadCtx(ctx context.Context, fileSize int64, fileName, dirID, preID, fileID string, r io.ReadSeeker) (*UploadInitResp, error) {
+	var result *UploadInitResp
+	ev := &UploadEvent{Phase: UploadPhaseRapid, FileName: fileName, FileSize: fileSize, DirID: dirID}
+	err := c.uploadPhase(ctx, ev, func(ctx context.Context) (err error) {
+		if result, err = c.rapidUpload(ctx, fileSize, fileName, dirID, preID, fileID, r); err == nil {
+			ev.Rapid, _ = result.Ok()
+		}
+		return err
+	})
+	return result, err
+}
+
+func (c *Pan115Client) rapidUpload(ctx context.Context, fileSize int64, fileName, dirID, preID, fileID string, r io.ReadSeeker) (*UploadInitResp, error) {
 	var (
 		ecdhCipher   *cipher.EcdhCipher
 		encrypted    []byte
@@ -203,15 +308,15 @@ func (c *Pan115Client) RapidUpload(fileSize int64, fileName, dirID, preID, fileI
 		result       = UploadInitResp{}
 		fileSizeStr  = strconv.FormatInt(fileSize, 10)
 	)
-	if ecdhCipher, err = cipher.NewEcdhCipher(); err != nil {
+	if ecdhCipher, err = c.ecdhCipher(); err != nil {
 		return nil, err
 	}
 
-	if ok, err := c.UploadAvailable(); !ok || err != nil {
+	if ok, err := c.UploadAvailableCtx(ctx); !ok || err != nil {
 		return nil, err
 	}
 
-	userID := strconv.FormatInt(c.UserID, 10)
+	userID := strconv.FormatInt(c.getUserID(), 10)
 	form := url.Values{}
 	form.Set("appid", "0")
 	form.Set("appversion", appVer)
@@ -245,12 +350,12 @@ func (c *Pan115Client) RapidUpload(fileSize int64, fileName, dirID, preID, fileI
 			return nil, err
 		}
 
-		req := c.NewRequest().
+		req := c.newRequest(ctx).
 			SetQueryParams(params).
 			SetBody(encrypted).
 			SetHeaderVerbatim("Content-Type", "application/x-www-form-urlencoded").
 			SetDoNotParseResponse(true)
-		resp, err := req.Post(ApiUploadInit)
+		resp, err := c.post(req, c.endpoints().UploadInit)
 		if err != nil {
 			return nil, err
 		}
@@ -268,7 +373,9 @@ func (c *Pan115Client) RapidUpload(fileSize int64, fileName, dirID, preID, fileI
 		if result.Status == 7 {
 			// Update signKey & signVal
 			signKey = result.SignKey
-			signVal, _ = c.UploadDigestRange(r, result.SignCheck)
+			if signVal, err = c.UploadDigestRange(r, result.SignCheck); err != nil {
+				c.logWarn(ctx, "digest upload sign range failed", err, slog.String("range", result.SignCheck))
+			}
 		} else {
 			retry = false
 		}
@@ -301,14 +408,15 @@ func (c *Pan115Client) UploadDigestRange(r io.ReadSeeker, rangeSpec string) (res
 }
 
 func (c *Pan115Client) GenerateSignature(fileID, target string) string {
-	sh1hash := sha1.Sum([]byte(strconv.FormatInt(c.UserID, 10) + fileID + target + "0"))
-	sigStr := c.Userkey + hex.EncodeToString(sh1hash[:]) + "000000"
+	userID, userkey, _ := c.uploadMeta()
+	sh1hash := sha1.Sum([]byte(strconv.FormatInt(userID, 10) + fileID + target + "0"))
+	sigStr := userkey + hex.EncodeToString(sh1hash[:]) + "000000"
 	sh1Sig := sha1.Sum([]byte(sigStr))
 	return strings.ToUpper(hex.EncodeToString(sh1Sig[:]))
 }
 
 func (c *Pan115Client) GenerateToken(fileID, preID, timeStamp, fileSize, signKey, signVal string) string {
-	userID := strconv.FormatInt(c.UserID, 10)
+	userID := strconv.FormatInt(c.getUserID(), 10)
 	userIDMd5 := md5.Sum([]byte(userID))
 	tokenMd5 := md5.Sum([]byte(md5Salt + fileID + fileSize + signKey + signVal + userID + timeStamp + hex.EncodeToString(userIDMd5[:]) + appVer))
 	return hex.EncodeToString(tokenMd5[:])
@@ -316,30 +424,47 @@ func (c *Pan115Client) GenerateToken(fileID, preID, timeStamp, fileSize, signKey
 
 // UploadFastOrByMultipart upload by mutipart blocks when unable to rapid upload
 // Deprecated: As of v1.0.22, this function simply calls [RapidUploadOrByMultipart].
-func (c *Pan115Client) UploadFastOrByMultipart(dirID, fileName string, fileSize int64, r *os.File, opts ...UploadMultipartOption) error {
+func (c *Pan115Client) UploadFastOrByMultipart(dirID, fileName string, fileSize int64, r io.ReaderAt, opts ...UploadMultipartOption) error {
 	return c.RapidUploadOrByMultipart(dirID, fileName, fileSize, r, opts...)
 }
 
-// RapidUploadOrByMultipart upload by mutipart blocks when unable to rapid upload
-func (c *Pan115Client) RapidUploadOrByMultipart(dirID, fileName string, fileSize int64, r *os.File, opts ...UploadMultipartOption) error {
+// RapidUploadOrByMultipart upload by mutipart blocks when unable to rapid upload,
+// r holds the fileSize bytes of the file from offset 0.
+func (c *Pan115Client) RapidUploadOrByMultipart(dirID, fileName string, fileSize int64, r io.ReaderAt, opts ...UploadMultipartOption) error {
+	return c.RapidUploadOrByMultipartCtx(context.Background(), dirID, fileName, fileSize, r, opts...)
+}
+
+// RapidUploadOrByMultipartCtx is like RapidUploadOrByMultipart but uses ctx for the requests and the OSS upload.
+func (c *Pan115Client) RapidUploadOrByMultipartCtx(ctx context.Context, dirID, fileName string, fileSize int64, r io.ReaderAt, opts ...UploadMultipartOption) error {
 	var (
 		err      error
 		digest   *hash.DigestResult
 		fastInfo *UploadInitResp
+		rs       = io.NewSectionReader(r, 0, fileSize)
+		options  = DefaultUploadMultipartOptions()
 	)
+	for _, opt := range opts {
+		opt(options)
+	}
+	progress := options.tracker
+	if progress == nil {
+		progress = newUploadProgress(options.Progress, fileSize)
+	}
 
-	if ok, err := c.UploadAvailable(); err != nil || !ok {
+	if ok, err := c.UploadAvailableCtx(ctx); err != nil || !ok {
 		return err
 	}
-	if fileSize > c.UploadMetaInfo.SizeLimit {
+	if limit := c.uploadSizeLimit(); limit > 0 && fileSize > limit {
 		return ErrUploadTooLarge
 	}
-	if digest, err = c.GetDigestResult(r); err != nil {
+	progress.phase(UploadPhaseHashing)
+	if digest, err = c.GetDigestResult(progress.hashing(rs)); err != nil {
 		return err
 	}
 	// 闪传
-	if fastInfo, err = c.RapidUpload(
-		digest.Size, fileName, dirID, digest.PreID, digest.QuickID, r,
+	progress.phase(UploadPhaseRapid)
+	if fastInfo, err = c.RapidUploadCtx(ctx,
+		digest.Size, fileName, dirID, digest.PreID, digest.QuickID, rs,
 	); err != nil {
 		return err
 	}
@@ -348,198 +473,234 @@ func (c *Pan115Client) RapidUploadOrByMultipart(dirID, fileName string, fileSize
 	} else if ok {
 		return nil
 	}
-	if _, err = r.Seek(0, io.SeekStart); err != nil {
+	if _, err = rs.Seek(0, io.SeekStart); err != nil {
 		return err
 	}
 
 	// 闪传失败，上传
 	if digest.Size <= KB { // 文件大小小于1KB，改用普通模式上传
-		return c.UploadByOSS(&fastInfo.UploadOSSParams, r, dirID)
+		return c.UploadByOSSCtx(ctx, &fastInfo.UploadOSSParams, rs, dirID, uploadWithTracker(progress))
 	}
 	// 分片上传
-	return c.UploadByMultipart(&fastInfo.UploadOSSParams, digest.Size, r, dirID, opts...)
+	return c.UploadByMultipartCtx(ctx, &fastInfo.UploadOSSParams, digest.Size, r, dirID,
+		append(opts[:len(opts):len(opts)], uploadMultipartWithTracker(progress))...)
+}
+
+// UploadByMultipart upload by mutipart blocks, read from the fileSize bytes of
+// r from offset 0, a file, a section of it or any other io.ReaderAt.
+func (c *Pan115Client) UploadByMultipart(params *UploadOSSParams, fileSize int64, r io.ReaderAt, dirID string, opts ...UploadMultipartOption) error {
+	return c.UploadByMultipartCtx(context.Background(), params, fileSize, r, dirID, opts...)
+}
+
+// UploadByMultipartCtx is like UploadByMultipart but uses ctx for the requests,
+// the OSS calls and the part upload workers.
+func (c *Pan115Client) UploadByMultipartCtx(ctx context.Context, params *UploadOSSParams, fileSize int64, r io.ReaderAt, dirID string, opts ...UploadMultipartOption) error {
+	ev := &UploadEvent{Phase: UploadPhaseMultipart, Object: params.Object, FileSize: fileSize, DirID: dirID}
+	return c.uploadPhase(ctx, ev, func(ctx context.Context) error {
+		return c.uploadByMultipart(ctx, params, fileSize, r, dirID, opts...)
+	})
 }
 
-// UploadByMultipart upload by mutipart blocks
-func (c *Pan115Client) UploadByMultipart(params *UploadOSSParams, fileSize int64, f *os.File, dirID string, opts ...UploadMultipartOption) error {
+func (c *Pan115Client) uploadByMultipart(ctx context.Context, params *UploadOSSParams, fileSize int64, r io.ReaderAt, dirID string, opts ...UploadMultipartOption) (err error) {
 	var (
-		chunks    []oss.FileChunk
-		parts     []oss.UploadPart
-		imur      oss.InitiateMultipartUploadResult
-		ossClient *oss.Client
-		bucket    *oss.Bucket
-		ossToken  *UploadOSSTokenResp
-		bodyBytes []byte
-		err       error
+		chunks   []oss.FileChunk
+		bucket   *oss.Bucket
+		cp       *uploadCheckpoint
+		ossToken *UploadOSSTokenResp
 	)
 
 	options := DefaultUploadMultipartOptions()
-	if len(opts) > 0 {
-		for _, f := range opts {
-			f(options)
-		}
+	for _, opt := range opts {
+		opt(options)
+	}
+	progress := options.tracker
+	if progress == nil {
+		progress = newUploadProgress(options.Progress, fileSize)
 	}
 
-	options.ThreadsNum = 1
-	if ossToken, err = c.GetOSSToken(); err != nil {
-		return err
+	sequential := options.Sequential || options.ThreadsNum <= 1
+	if sequential {
+		options.ThreadsNum = 1 // oss 启用Sequential必须按顺序上传
 	}
+	// 设置超时
+	ctx, cancel := context.WithTimeout(ctx, options.Timeout)
+	defer cancel()
 
-	if ossClient, err = oss.New(
-		c.getOSSEndpoint(c.UseInternalUpload),
-		ossToken.AccessKeyID,
-		ossToken.AccessKeySecret,
-		oss.EnableMD5(true),
-		oss.EnableCRC(true),
-	); err != nil {
+	if ossToken, err = c.GetOSSTokenCtx(ctx); err != nil {
 		return err
 	}
 
-	if bucket, err = ossClient.Bucket(params.Bucket); err != nil {
+	if chunks, err = SplitSize(fileSize); err != nil {
 		return err
 	}
 
-	// ossToken一小时后就会失效，所以每50分钟重新获取一次
-	ticker := time.NewTicker(options.TokenRefreshTime)
-	defer ticker.Stop()
-	// 设置超时
-	timeout := time.NewTimer(options.Timeout)
-
-	if chunks, err = SplitFile(f.Name(), fileSize); err != nil {
-		return err
+	// the callback of parallel uploads carries the SHA1, and a checkpoint is
+	// matched with it, so it is computed first when the caller has none
+	sha1 := params.SHA1
+	if sha1 == "" && (!sequential || options.Checkpoint != "") {
+		progress.phase(UploadPhaseHashing)
+		if sha1, err = fileSHA1(progress.hashing(io.NewSectionReader(r, 0, fileSize))); err != nil {
+			return err
+		}
 	}
 
-	if imur, err = bucket.InitiateMultipartUpload(params.Object,
-		oss.SetHeader(OssSecurityTokenHeaderName, ossToken.SecurityToken),
-		oss.UserAgentHeader(OSSUserAgent),
-		oss.EnableSha1(),
-		oss.Sequential(), // oss 启用Sequential必须按顺序上传, options.ThreadsNum = 1
-	); err != nil {
-		return err
+	if options.Checkpoint != "" {
+		if cp, err = c.resumeCheckpoint(ctx, options.Checkpoint, ossToken, sha1, fileSize, chunks[0].Size, sequential); err != nil {
+			return err
+		}
+		if cp != nil {
+			// the upload goes on with the object and the callback it was started with
+			params = cp.params()
+		}
 	}
 
-	wg := sync.WaitGroup{}
-	wg.Add(len(chunks))
-
-	chunksCh := make(chan oss.FileChunk)
-	errCh := make(chan error)
-	UploadedPartsCh := make(chan oss.UploadPart)
-	quit := make(chan struct{})
-
-	// producter
-	go chunksProducer(chunksCh, chunks)
-	go func() {
-		wg.Wait()
-		quit <- struct{}{}
-	}()
-
-	// consumers
-	for i := 0; i < options.ThreadsNum; i++ {
-		go func(threadId int) {
-			defer func() {
-				if r := recover(); r != nil {
-					errCh <- fmt.Errorf("recovered in %v", r)
-				}
-			}()
-			for chunk := range chunksCh {
-				var part oss.UploadPart // 出现错误就继续尝试，共尝试3次
-				for retry := 0; retry < 3; retry++ {
-					select {
-					case <-ticker.C:
-						if ossToken, err = c.GetOSSToken(); err != nil { // 到时重新获取ossToken
-							errCh <- errors.Wrap(err, "刷新token时出现错误")
-						}
-					default:
-					}
-
-					buf := make([]byte, chunk.Size)
-					if _, err = f.ReadAt(buf, chunk.Offset); err != nil && !errors.Is(err, io.EOF) {
-						continue
-					}
-
-					if part, err = bucket.UploadPart(
-						imur,
-						bytes.NewBuffer(buf),
-						chunk.Size,
-						chunk.Number,
-						OssOption(params, ossToken)...); err == nil {
-						break
-					}
-				}
-				if err != nil {
-					errCh <- errors.Wrap(err, fmt.Sprintf("上传 %s 的第%d个分片时出现错误：%v", f.Name(), chunk.Number, err))
-				}
-				UploadedPartsCh <- part
-			}
-		}(i)
+	if bucket, err = c.ossBucket(ctx, params.Bucket, ossToken, oss.EnableMD5(true), oss.EnableCRC(true)); err != nil {
+		return err
 	}
 
-	go func() {
-		for part := range UploadedPartsCh {
-			parts = append(parts, part)
-			wg.Done()
+	// OSS computes the SHA1 the callback checks only when the parts come in
+	// order, otherwise the client provides it
+	callbackParams := params
+	if !sequential {
+		callbackParams = callbackWithSHA1(params, sha1)
+	}
+
+	progress.phase(UploadPhaseMultipart)
+	u := &multipartUpload{c: c, bucket: bucket, params: params, r: r, cp: cp, token: ossToken, progress: progress}
+	if cp != nil {
+		u.imur = cp.imur()
+		u.parts = cp.uploaded()
+		pending := pendingChunks(chunks, cp)
+		progress.setParts(len(chunks), len(chunks)-len(pending), fileSize-chunksSize(pending))
+		chunks = pending
+	} else {
+		progress.setParts(len(chunks), 0, 0)
+		initOpts := []oss.Option{
+			oss.SetHeader(OssSecurityTokenHeaderName, ossToken.SecurityToken),
+			oss.UserAgentHeader(OSSUserAgent),
+			oss.WithContext(ctx),
 		}
-	}()
-LOOP:
-	for {
-		select {
-		case <-ticker.C:
-			// 到时重新获取ossToken
-			if ossToken, err = c.GetOSSToken(); err != nil {
+		if sequential {
+			initOpts = append(initOpts, oss.EnableSha1(), oss.Sequential())
+		}
+		if u.imur, err = bucket.InitiateMultipartUpload(params.Object, initOpts...); err != nil {
+			return err
+		}
+		// without a checkpoint to resume from, a failed upload is of no use
+		defer func() {
+			if err != nil && u.cp == nil {
+				u.abort(ctx)
+			}
+		}()
+		if options.Checkpoint != "" {
+			cp = &uploadCheckpoint{
+				UploadID:    u.imur.UploadID,
+				Bucket:      params.Bucket,
+				Object:      u.imur.Key,
+				Callback:    params.Callback.Callback,
+				CallbackVar: params.Callback.CallbackVar,
+				SHA1:        sha1,
+				FileSize:    fileSize,
+				PartSize:    chunks[0].Size,
+				Sequential:  sequential,
+				Parts:       map[int]string{},
+				path:        options.Checkpoint,
+			}
+			if err = cp.save(); err != nil {
 				return err
 			}
-		case <-quit:
-			break LOOP
-		case <-errCh:
-			return err
-		case <-timeout.C:
-			return fmt.Errorf("time out")
+			u.cp = cp
 		}
 	}
 
-	if _, err := bucket.CompleteMultipartUpload(imur, parts,
-		append(
-			OssOption(params, ossToken),
-			oss.CallbackResult(&bodyBytes),
-		)...); err != nil {
+	if err = u.run(ctx, chunks, options.ThreadsNum, options.TokenRefreshTime); err != nil {
 		return err
 	}
+	progress.phase(UploadPhaseVerify)
+	return u.complete(ctx, callbackParams)
+}
 
-	var uploadResult UploadResult
-	if err = json.Unmarshal(bodyBytes, &uploadResult); err != nil {
-		return err
+// fileSHA1 returns the upper case hex SHA1 of the content of r.
+func fileSHA1(r io.Reader) (string, error) {
+	h := sha1.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return strings.ToUpper(hex.EncodeToString(h.Sum(nil))), nil
+}
+
+// callbackWithSHA1 returns a copy of params whose callback carries sha1 in
+// place of the ${sha1} variable, which OSS only sets for sequential uploads.
+func callbackWithSHA1(params *UploadOSSParams, sha1 string) *UploadOSSParams {
+	p := *params
+	p.Callback.Callback = strings.ReplaceAll(p.Callback.Callback, "${sha1}", strings.ToUpper(sha1))
+	return &p
+}
+
+// pendingChunks returns the chunks which are not uploaded according to cp.
+func pendingChunks(chunks []oss.FileChunk, cp *uploadCheckpoint) []oss.FileChunk {
+	var pending []oss.FileChunk
+	for _, chunk := range chunks {
+		if _, ok := cp.Parts[chunk.Number]; !ok {
+			pending = append(pending, chunk)
+		}
 	}
-	return uploadResult.Err(string(bodyBytes))
+	return pending
 }
 
-func chunksProducer(ch chan oss.FileChunk, chunks []oss.FileChunk) {
+// chunksSize returns the number of bytes of chunks.
+func chunksSize(chunks []oss.FileChunk) int64 {
+	var size int64
 	for _, chunk := range chunks {
-		ch <- chunk
+		size += chunk.Size
 	}
+	return size
 }
 
 // SplitFile pplitFile
-func SplitFile(filePath string, fileSize int64) (chunks []oss.FileChunk, err error) {
+// Deprecated: this function simply calls [SplitSize], the parts only depend on fileSize.
+func SplitFile(filePath string, fileSize int64) ([]oss.FileChunk, error) {
+	return SplitSize(fileSize)
+}
+
+// SplitSize splits fileSize bytes into the parts of a multipart upload.
+func SplitSize(fileSize int64) ([]oss.FileChunk, error) {
+	if fileSize <= 0 {
+		return nil, fmt.Errorf("cannot split %d bytes into parts", fileSize)
+	}
+	partNum := int64(10000) // 文件大小大于9GB时分为10000片
 	for i := int64(1); i < 10; i++ {
 		if fileSize < i*GB { // 文件大小小于iGB时分为i*1000片
-			if chunks, err = oss.SplitFileByPartNum(filePath, int(i*1000)); err != nil {
-				return
-			}
+			partNum = i * 1000
 			break
 		}
 	}
-	if fileSize > 9*GB { // 文件大小大于9GB时分为10000片
-		if chunks, err = oss.SplitFileByPartNum(filePath, 10000); err != nil {
-			return
-		}
-	}
 	// 单个分片大小不能小于100KB
-	if chunks[0].Size < 100*KB {
-		if chunks, err = oss.SplitFileByPartSize(filePath, 100*KB); err != nil {
-			return
-		}
+	if fileSize/partNum < 100*KB {
+		return splitByPartSize(fileSize, 100*KB), nil
 	}
-	return
+	return splitByPartNum(fileSize, partNum), nil
+}
+
+// splitByPartNum splits fileSize bytes into n parts, the last one holding
+// the remainder, like oss.SplitFileByPartNum.
+func splitByPartNum(fileSize, n int64) []oss.FileChunk {
+	chunks := make([]oss.FileChunk, n)
+	for i := range chunks {
+		chunks[i] = oss.FileChunk{Number: i + 1, Offset: int64(i) * (fileSize / n), Size: fileSize / n}
+	}
+	chunks[n-1].Size += fileSize % n
+	return chunks
+}
+
+// splitByPartSize splits fileSize bytes into parts of partSize, the last one
+// smaller, like oss.SplitFileByPartSize.
+func splitByPartSize(fileSize, partSize int64) []oss.FileChunk {
+	var chunks []oss.FileChunk
+	for offset := int64(0); offset < fileSize; offset += partSize {
+		chunks = append(chunks, oss.FileChunk{Number: len(chunks) + 1, Offset: offset, Size: min(partSize, fileSize-offset)})
+	}
+	return chunks
 }
 
 // OssOption get options
@@ -0,0 +1,556 @@
+package driver
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/SheltonZhu/115driver/pkg/driver/fake115"
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newUploadTestClient returns a client uploading to the OSS stand-in of srv,
+// and a local file of size random bytes.
+func newUploadTestClient(t testing.TB, size int) (*fake115.Server, *Pan115Client, *os.File, []byte) {
+	srv := fake115.NewServer()
+	t.Cleanup(srv.Close)
+	c := New(UA(),
+		WithBaseHost(srv.URL),
+		WithHost(OSSEndpoint, srv.OSSURL),
+		WithM115PublicKey(srv.PublicKey()),
+		WithEC115PublicKey(srv.EC115PublicKey()),
+	)
+
+	content := make([]byte, size)
+	r := rand.New(rand.NewPCG(1, 2))
+	for i := range content {
+		content[i] = byte(r.Uint32())
+	}
+	local := filepath.Join(t.TempDir(), "video.mp4")
+	require.NoError(t, os.WriteFile(local, content, 0o644))
+	f, err := os.Open(local)
+	require.NoError(t, err)
+	t.Cleanup(func() { f.Close() })
+	return srv, c, f, content
+}
+
+// uploadParams returns the OSS parameters of srv for an upload of content.
+func uploadParams(srv *fake115.Server, dirID, name string, content []byte) *UploadOSSParams {
+	sum := sha1.Sum(content)
+	p := srv.UploadParams(dirID, name, hex.EncodeToString(sum[:]))
+	params := &UploadOSSParams{SHA1: p.SHA1, Bucket: p.Bucket, Object: p.Object}
+	params.Callback.Callback = p.Callback
+	params.Callback.CallbackVar = p.CallbackVar
+	return params
+}
+
+// failParts makes the uploads of the parts from part number n fail, and
+// returns the number of part uploads served.
+func failParts(srv *fake115.Server, n *atomic.Int64) *atomic.Int64 {
+	var served atomic.Int64
+	srv.SetOSSFault(func(r *http.Request) int {
+		number, err := strconv.Atoi(r.URL.Query().Get("partNumber"))
+		if err != nil {
+			return 0
+		}
+		if from := n.Load(); from > 0 && int64(number) >= from {
+			return http.StatusForbidden
+		}
+		served.Add(1)
+		return 0
+	})
+	return &served
+}
+
+func TestUploadByMultipart(t *testing.T) {
+	srv, c, f, content := newUploadTestClient(t, 250_000)
+	dir := srv.MkdirAll("videos")
+
+	require.NoError(t, c.UploadByMultipart(uploadParams(srv, dir.ID, "video.mp4", content), int64(len(content)), f, dir.ID))
+
+	files := srv.Children(dir.ID)
+	require.Len(t, files, 1)
+	assert.Equal(t, "video.mp4", files[0].Name)
+	got, _ := srv.Content(files[0].ID)
+	assert.Equal(t, content, got)
+	assert.Empty(t, srv.MultipartUploads())
+}
+
+// slowParts delays the part uploads of srv by latency, like a remote OSS
+// would, and records the highest number of parts uploaded at once.
+func slowParts(srv *fake115.Server, latency time.Duration) *atomic.Int64 {
+	var running, highest atomic.Int64
+	srv.SetOSSFault(func(r *http.Request) int {
+		if r.URL.Query().Get("partNumber") == "" {
+			return 0
+		}
+		n := running.Add(1)
+		defer running.Add(-1)
+		for h := highest.Load(); n > h && !highest.CompareAndSwap(h, n); h = highest.Load() {
+		}
+		time.Sleep(latency)
+		return 0
+	})
+	return &highest
+}
+
+func TestUploadByMultipartParallel(t *testing.T) {
+	srv, c, f, content := newUploadTestClient(t, 1_000_000)
+	highest := slowParts(srv, 10*time.Millisecond)
+
+	params := uploadParams(srv, "0", "video.mp4", content)
+	params.SHA1 = "" // computed by the client
+	require.NoError(t, c.UploadByMultipart(params, int64(len(content)), f, "0", UploadMultipartWithThreadsNum(4)))
+	assert.Equal(t, int64(4), highest.Load())
+	files := srv.Children("0")
+	require.Len(t, files, 1)
+	got, _ := srv.Content(files[0].ID)
+	assert.Equal(t, content, got)
+
+	highest.Store(0)
+	require.NoError(t, c.UploadByMultipart(uploadParams(srv, "0", "video2.mp4", content), int64(len(content)), f, "0",
+		UploadMultipartWithThreadsNum(4), UploadMultipartWithSequential()))
+	assert.Equal(t, int64(1), highest.Load())
+	assert.Len(t, srv.Children("0"), 2)
+}
+
+// partsInFlight counts the parts being uploaded: a part is read once when its
+// upload starts, and reported to the instrumentation once it is done. The
+// reads from holdFrom on are held until failed is closed, and a while after,
+// so that their workers are busy when the upload fails.
+type partsInFlight struct {
+	NopInstrumentation
+	r        io.ReaderAt
+	n        atomic.Int64
+	holdFrom int64
+	failed   chan struct{}
+}
+
+func (p *partsInFlight) ReadAt(b []byte, off int64) (int, error) {
+	p.n.Add(1)
+	if off >= p.holdFrom {
+		<-p.failed
+		time.Sleep(50 * time.Millisecond)
+	}
+	return p.r.ReadAt(b, off)
+}
+
+func (p *partsInFlight) PartUploaded(context.Context, *PartEvent) {
+	p.n.Add(-1)
+}
+
+func TestUploadByMultipartFailure(t *testing.T) {
+	srv, c, f, content := newUploadTestClient(t, 1_000_000)
+	chunks, err := SplitSize(int64(len(content)))
+	require.NoError(t, err)
+	inFlight := &partsInFlight{r: f, holdFrom: chunks[3].Offset, failed: make(chan struct{})}
+	WithInstrumentation(inFlight)(c)
+	var requested atomic.Int64
+	srv.SetOSSFault(func(r *http.Request) int {
+		number, err := strconv.Atoi(r.URL.Query().Get("partNumber"))
+		if err != nil {
+			return 0
+		}
+		requested.Add(1)
+		if number == 3 {
+			defer close(inFlight.failed)
+			return http.StatusForbidden
+		}
+		return 0
+	})
+
+	err = c.UploadByMultipart(uploadParams(srv, "0", "video.mp4", content), int64(len(content)), inFlight, "0",
+		UploadMultipartWithThreadsNum(4))
+	assert.Zero(t, inFlight.n.Load(), "the workers are done when the upload returns")
+	var ossErr oss.ServiceError
+	require.ErrorAs(t, err, &ossErr)
+	assert.Equal(t, http.StatusForbidden, ossErr.StatusCode)
+	assert.Contains(t, err.Error(), "第3个分片")
+	assert.Empty(t, srv.MultipartUploads(), "the upload is aborted")
+	assert.Empty(t, srv.Children("0"))
+	assert.LessOrEqual(t, requested.Load(), int64(4), "the other workers are canceled")
+}
+
+func TestUploadByMultipartRetriesParts(t *testing.T) {
+	srv, c, f, content := newUploadTestClient(t, 450_000)
+	var attempts atomic.Int64
+	srv.SetOSSFault(func(r *http.Request) int {
+		if r.URL.Query().Get("partNumber") == "2" && attempts.Add(1) == 1 {
+			return http.StatusServiceUnavailable
+		}
+		return 0
+	})
+
+	require.NoError(t, c.UploadByMultipart(uploadParams(srv, "0", "video.mp4", content), int64(len(content)), f, "0"))
+	assert.Equal(t, int64(2), attempts.Load())
+	files := srv.Children("0")
+	require.Len(t, files, 1)
+	got, _ := srv.Content(files[0].ID)
+	assert.Equal(t, content, got)
+}
+
+func TestUploadByMultipartCanceled(t *testing.T) {
+	srv, c, f, content := newUploadTestClient(t, 1_000_000)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	srv.SetOSSFault(func(r *http.Request) int {
+		if r.URL.Query().Get("partNumber") == "5" {
+			cancel()
+		}
+		return 0
+	})
+
+	err := c.UploadByMultipartCtx(ctx, uploadParams(srv, "0", "video.mp4", content), int64(len(content)), f, "0",
+		UploadMultipartWithThreadsNum(2))
+	require.ErrorIs(t, err, context.Canceled)
+	assert.Empty(t, srv.MultipartUploads(), "the upload is aborted despite the canceled context")
+}
+
+func BenchmarkUploadByMultipart(b *testing.B) {
+	const size = 2_000_000 // 20 parts
+	for _, threads := range []int{1, 4, 8} {
+		b.Run(fmt.Sprintf("threads=%d", threads), func(b *testing.B) {
+			srv, c, f, content := newUploadTestClient(b, size)
+			slowParts(srv, 5*time.Millisecond)
+			b.SetBytes(size)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				params := uploadParams(srv, "0", strconv.Itoa(i), content)
+				b.StartTimer()
+				if err := c.UploadByMultipart(params, size, f, "0", UploadMultipartWithThreadsNum(threads)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestUploadMultipartCheckpoint(t *testing.T) {
+	srv, c, f, content := newUploadTestClient(t, 450_000)
+	dir := srv.MkdirAll("videos")
+	checkpoint := filepath.Join(t.TempDir(), "video.mp4.upload")
+	size := int64(len(content))
+
+	var from atomic.Int64
+	from.Store(3)
+	served := failParts(srv, &from)
+	err := c.UploadByMultipart(uploadParams(srv, dir.ID, "video.mp4", content), size, f, dir.ID,
+		UploadMultipartWithCheckpoint(checkpoint))
+	require.Error(t, err)
+	assert.FileExists(t, checkpoint)
+	uploads := srv.MultipartUploads()
+	require.Len(t, uploads, 1)
+	assert.Equal(t, []int{1, 2}, srv.UploadedParts(uploads[0]))
+
+	// the init API hands out a new object, the checkpoint resumes the first one
+	from.Store(0)
+	served.Store(0)
+	require.NoError(t, c.UploadByMultipart(uploadParams(srv, dir.ID, "video.mp4", content), size, f, dir.ID,
+		UploadMultipartWithCheckpoint(checkpoint)))
+	assert.Equal(t, int64(3), served.Load(), "only the missing parts are uploaded")
+	assert.NoFileExists(t, checkpoint)
+	assert.Empty(t, srv.MultipartUploads())
+
+	files := srv.Children(dir.ID)
+	require.Len(t, files, 1)
+	got, _ := srv.Content(files[0].ID)
+	assert.Equal(t, content, got)
+}
+
+func TestUploadMultipartCheckpointParallel(t *testing.T) {
+	srv, c, f, content := newUploadTestClient(t, 450_000)
+	checkpoint := filepath.Join(t.TempDir(), "video.mp4.upload")
+	size := int64(len(content))
+	// without the SHA1 of the caller, computed before matching the checkpoint
+	params := func() *UploadOSSParams {
+		p := uploadParams(srv, "0", "video.mp4", content)
+		p.SHA1 = ""
+		return p
+	}
+
+	var from atomic.Int64
+	from.Store(3)
+	served := failParts(srv, &from)
+	require.Error(t, c.UploadByMultipart(params(), size, f, "0",
+		UploadMultipartWithThreadsNum(2), UploadMultipartWithCheckpoint(checkpoint)))
+	uploads := srv.MultipartUploads()
+	require.Len(t, uploads, 1)
+	stored := srv.UploadedParts(uploads[0])
+	require.NotEmpty(t, stored)
+
+	from.Store(0)
+	served.Store(0)
+	require.NoError(t, c.UploadByMultipart(params(), size, f, "0",
+		UploadMultipartWithThreadsNum(2), UploadMultipartWithCheckpoint(checkpoint)))
+	assert.Equal(t, int64(5-len(stored)), served.Load(), "only the missing parts are uploaded")
+	assert.NoFileExists(t, checkpoint)
+
+	files := srv.Children("0")
+	require.Len(t, files, 1)
+	got, _ := srv.Content(files[0].ID)
+	assert.Equal(t, content, got)
+}
+
+func TestDiscardUploadCheckpoint(t *testing.T) {
+	srv, c, f, content := newUploadTestClient(t, 450_000)
+	checkpoint := filepath.Join(t.TempDir(), "video.mp4.upload")
+	size := int64(len(content))
+
+	var from atomic.Int64
+	from.Store(2)
+	failParts(srv, &from)
+	require.Error(t, c.UploadByMultipart(uploadParams(srv, "0", "video.mp4", content), size, f, "0",
+		UploadMultipartWithCheckpoint(checkpoint)))
+	require.Len(t, srv.MultipartUploads(), 1)
+
+	require.NoError(t, c.DiscardUploadCheckpoint(checkpoint))
+	assert.NoFileExists(t, checkpoint)
+	assert.Empty(t, srv.MultipartUploads())
+	require.NoError(t, c.DiscardUploadCheckpoint(checkpoint), "nothing to discard")
+
+	// a checkpoint of another file is discarded when uploading
+	require.Error(t, c.UploadByMultipart(uploadParams(srv, "0", "video.mp4", content), size, f, "0",
+		UploadMultipartWithCheckpoint(checkpoint)))
+	stale := srv.MultipartUploads()
+	require.Len(t, stale, 1)
+	from.Store(0)
+	other := append([]byte(nil), content[:300_000]...)
+	other[0]++
+	otherFile := filepath.Join(t.TempDir(), "other.bin")
+	require.NoError(t, os.WriteFile(otherFile, other, 0o644))
+	of, err := os.Open(otherFile)
+	require.NoError(t, err)
+	defer of.Close()
+	require.NoError(t, c.UploadByMultipart(uploadParams(srv, "0", "other.bin", other), int64(len(other)), of, "0",
+		UploadMultipartWithCheckpoint(checkpoint)))
+	assert.Empty(t, srv.MultipartUploads())
+	assert.NoFileExists(t, checkpoint)
+}
+
+func TestUploadStream(t *testing.T) {
+	srv, c, _, content := newUploadTestClient(t, 450_000)
+	dir := srv.MkdirAll("videos")
+	tempDir := t.TempDir()
+	var objects atomic.Int64
+	srv.SetOSSFault(func(r *http.Request) int {
+		if r.Method == http.MethodPut && r.URL.Query().Get("partNumber") == "" ||
+			r.Method == http.MethodPost && r.URL.Query().Has("uploads") {
+			objects.Add(1)
+		}
+		return 0
+	})
+
+	// in memory, uploaded in a single request
+	require.NoError(t, c.UploadStream(dir.ID, "small.txt", strings.NewReader("hello"), UploadStreamWithTempDir(tempDir)))
+	assert.Equal(t, int64(1), objects.Load())
+
+	// spooled to a temp file, uploaded by multipart
+	require.NoError(t, c.UploadStream(dir.ID, "video.mp4", bytes.NewReader(content),
+		UploadStreamWithMemoryLimit(100_000),
+		UploadStreamWithTempDir(tempDir),
+		UploadStreamWithMultipartOptions(UploadMultipartWithThreadsNum(4)),
+	))
+	assert.Equal(t, int64(2), objects.Load())
+	assert.Empty(t, srv.MultipartUploads())
+	entries, err := os.ReadDir(tempDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "the temp file is removed")
+
+	// already stored, rapid uploaded
+	require.NoError(t, c.UploadStream("0", "copy.mp4", bytes.NewReader(content), UploadStreamWithTempDir(tempDir)))
+	assert.Equal(t, int64(2), objects.Load())
+
+	files := srv.Children(dir.ID)
+	require.Len(t, files, 2)
+	got := map[string][]byte{}
+	for _, f := range append(files, srv.Children("0")...) {
+		got[f.Name], _ = srv.Content(f.ID)
+	}
+	assert.Equal(t, []byte("hello"), got["small.txt"])
+	assert.Equal(t, content, got["video.mp4"])
+	assert.Equal(t, content, got["copy.mp4"])
+}
+
+func TestUploadStreamTooLarge(t *testing.T) {
+	srv, c, _, content := newUploadTestClient(t, 450_000)
+	tempDir := t.TempDir()
+
+	err := c.UploadStream("0", "video.mp4", bytes.NewReader(content),
+		UploadStreamWithMemoryLimit(100_000),
+		UploadStreamWithTempDir(tempDir),
+		UploadStreamWithMaxSize(400_000),
+	)
+	require.ErrorIs(t, err, ErrUploadTooLarge)
+	entries, err := os.ReadDir(tempDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+	assert.Empty(t, srv.Children("0"))
+}
+
+func TestUploadStreamCanceled(t *testing.T) {
+	_, c, _, _ := newUploadTestClient(t, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	r, w := io.Pipe()
+	defer r.Close()
+	go func() {
+		w.Write(make([]byte, 1000))
+		cancel()
+		w.Write(make([]byte, 1000))
+		w.Close()
+	}()
+
+	require.ErrorIs(t, c.UploadStreamCtx(ctx, "0", "video.mp4", r), context.Canceled)
+}
+
+func TestSplitSize(t *testing.T) {
+	for _, tt := range []struct {
+		size     int64
+		parts    int
+		partSize int64
+	}{
+		{size: 1, parts: 1, partSize: 1},
+		{size: 250_000, parts: 3, partSize: 100 * KB},
+		{size: 100 * KB * 1000, parts: 1000, partSize: 100 * KB},
+		{size: 3*GB - 1, parts: 3000, partSize: (3*GB - 1) / 3000},
+		{size: 9 * GB, parts: 10000, partSize: 9 * GB / 10000},
+		{size: 100 * GB, parts: 10000, partSize: 100 * GB / 10000},
+	} {
+		chunks, err := SplitSize(tt.size)
+		require.NoError(t, err, tt.size)
+		require.Len(t, chunks, tt.parts, tt.size)
+		assert.Equal(t, tt.partSize, chunks[0].Size, tt.size)
+		var offset int64
+		for i, chunk := range chunks {
+			assert.Equal(t, i+1, chunk.Number)
+			assert.Equal(t, offset, chunk.Offset)
+			offset += chunk.Size
+		}
+		assert.Equal(t, tt.size, offset, "the parts cover the %d bytes", tt.size)
+	}
+
+	_, err := SplitSize(0)
+	assert.Error(t, err)
+}
+
+func TestUploadByMultipartReaderAt(t *testing.T) {
+	srv, c, f, content := newUploadTestClient(t, 250_000)
+	size := int64(len(content))
+
+	// from memory
+	require.NoError(t, c.UploadByMultipart(uploadParams(srv, "0", "memory.bin", content), size, bytes.NewReader(content), "0",
+		UploadMultipartWithThreadsNum(2)))
+
+	// from a section of a file, its last 150000 bytes
+	section := content[100_000:]
+	require.NoError(t, c.UploadByMultipart(uploadParams(srv, "0", "section.bin", section), int64(len(section)),
+		io.NewSectionReader(f, 100_000, int64(len(section))), "0"))
+
+	got := map[string][]byte{}
+	for _, n := range srv.Children("0") {
+		got[n.Name], _ = srv.Content(n.ID)
+	}
+	assert.Equal(t, content, got["memory.bin"])
+	assert.Equal(t, section, got["section.bin"])
+}
+
+// recordProgress returns a progress func recording the reports, and the
+// phases reported in order.
+func recordProgress() (func(p UploadProgress), func() ([]UploadProgress, []UploadPhase)) {
+	var (
+		mu      sync.Mutex
+		reports []UploadProgress
+	)
+	fn := func(p UploadProgress) {
+		mu.Lock()
+		defer mu.Unlock()
+		reports = append(reports, p)
+	}
+	return fn, func() ([]UploadProgress, []UploadPhase) {
+		mu.Lock()
+		defer mu.Unlock()
+		var phases []UploadPhase
+		for _, p := range reports {
+			if len(phases) == 0 || phases[len(phases)-1] != p.Phase {
+				phases = append(phases, p.Phase)
+			}
+		}
+		return reports, phases
+	}
+}
+
+func TestUploadProgress(t *testing.T) {
+	srv, c, f, content := newUploadTestClient(t, 450_000)
+	size := int64(len(content))
+	var attempts atomic.Int64
+	srv.SetOSSFault(func(r *http.Request) int {
+		if r.URL.Query().Get("partNumber") == "2" && attempts.Add(1) == 1 {
+			return http.StatusServiceUnavailable
+		}
+		return 0
+	})
+
+	fn, recorded := recordProgress()
+	require.NoError(t, c.RapidUploadOrByMultipart("0", "video.mp4", size, f,
+		UploadMultipartWithThreadsNum(2), UploadMultipartWithProgress(fn)))
+	require.Equal(t, int64(2), attempts.Load())
+	reports, phases := recorded()
+	assert.Equal(t, []UploadPhase{UploadPhaseHashing, UploadPhaseRapid, UploadPhaseMultipart, UploadPhaseVerify}, phases)
+	last := reports[len(reports)-1]
+	assert.Equal(t, UploadProgress{Phase: UploadPhaseVerify, Total: size, Hashed: size, Sent: size, Parts: 5, PartsDone: 5}, last,
+		"the bytes of the failed attempt are not counted")
+	for _, p := range reports {
+		assert.LessOrEqual(t, p.Sent, size)
+	}
+
+	// already stored
+	fn, recorded = recordProgress()
+	require.NoError(t, c.RapidUploadOrByOSS("0", "copy.mp4", size, bytes.NewReader(content), UploadWithProgress(fn)))
+	reports, phases = recorded()
+	assert.Equal(t, []UploadPhase{UploadPhaseHashing, UploadPhaseRapid}, phases)
+	assert.Equal(t, int64(0), reports[len(reports)-1].Sent)
+
+	// a stream of unknown size
+	fn, recorded = recordProgress()
+	require.NoError(t, c.UploadStream("0", "small.txt", strings.NewReader("hello"), UploadStreamWithProgress(fn)))
+	reports, phases = recorded()
+	assert.Equal(t, []UploadPhase{UploadPhaseHashing, UploadPhaseRapid, UploadPhaseOSS, UploadPhaseVerify}, phases)
+	assert.Equal(t, int64(-1), reports[0].Total)
+	assert.Equal(t, UploadProgress{Phase: UploadPhaseVerify, Total: 5, Hashed: 5, Sent: 5}, reports[len(reports)-1])
+}
+
+func TestUploadProgressResumed(t *testing.T) {
+	srv, c, f, content := newUploadTestClient(t, 450_000)
+	checkpoint := filepath.Join(t.TempDir(), "video.mp4.upload")
+	size := int64(len(content))
+
+	var from atomic.Int64
+	from.Store(3)
+	failParts(srv, &from)
+	require.Error(t, c.UploadByMultipart(uploadParams(srv, "0", "video.mp4", content), size, f, "0",
+		UploadMultipartWithCheckpoint(checkpoint)))
+
+	from.Store(0)
+	fn, recorded := recordProgress()
+	require.NoError(t, c.UploadByMultipart(uploadParams(srv, "0", "video.mp4", content), size, f, "0",
+		UploadMultipartWithCheckpoint(checkpoint), UploadMultipartWithProgress(fn)))
+	reports, _ := recorded()
+	assert.Equal(t, UploadProgress{Phase: UploadPhaseMultipart, Total: size, Sent: 200 * KB, Parts: 5, PartsDone: 2}, reports[1],
+		"the parts stored by OSS are reported first")
+	assert.Equal(t, UploadProgress{Phase: UploadPhaseVerify, Total: size, Sent: size, Parts: 5, PartsDone: 5}, reports[len(reports)-1])
+}
@@ -0,0 +1,210 @@
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+	"github.com/pkg/errors"
+)
+
+// uploadCheckpoint is the state of a multipart upload saved to the file set
+// by UploadMultipartWithCheckpoint, to resume it after a failure or a restart.
+type uploadCheckpoint struct {
+	UploadID    string `json:"upload_id"`
+	Bucket      string `json:"bucket"`
+	Object      string `json:"object"`
+	Callback    string `json:"callback"`
+	CallbackVar string `json:"callback_var"`
+	SHA1        string `json:"sha1"`
+	FileSize    int64  `json:"file_size"`
+	PartSize    int64  `json:"part_size"`
+	// Sequential tells whether OSS computes the SHA1 of the upload.
+	Sequential bool `json:"sequential"`
+	// Parts are the parts known to be uploaded, by part number.
+	Parts map[int]string `json:"parts"`
+
+	path string
+	// mu guards Parts and the writes of the checkpoint file.
+	mu sync.Mutex
+}
+
+// loadUploadCheckpoint reads the checkpoint at path, it returns nil when
+// there is none or it cannot be decoded.
+func loadUploadCheckpoint(path string) *uploadCheckpoint {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	cp := &uploadCheckpoint{}
+	if json.Unmarshal(data, cp) != nil || cp.UploadID == "" {
+		return nil
+	}
+	cp.path = path
+	if cp.Parts == nil {
+		cp.Parts = map[int]string{}
+	}
+	return cp
+}
+
+// matches reports whether the checkpoint belongs to the upload of a file of
+// sha1 and fileSize split in parts of partSize, in the same mode.
+func (cp *uploadCheckpoint) matches(sha1 string, fileSize, partSize int64, sequential bool) bool {
+	return sha1 != "" && strings.EqualFold(cp.SHA1, sha1) && cp.FileSize == fileSize && cp.PartSize == partSize &&
+		cp.Sequential == sequential
+}
+
+// params returns the OSS parameters the upload was started with.
+func (cp *uploadCheckpoint) params() *UploadOSSParams {
+	params := &UploadOSSParams{SHA1: cp.SHA1, Bucket: cp.Bucket, Object: cp.Object}
+	params.Callback.Callback = cp.Callback
+	params.Callback.CallbackVar = cp.CallbackVar
+	return params
+}
+
+func (cp *uploadCheckpoint) imur() oss.InitiateMultipartUploadResult {
+	return oss.InitiateMultipartUploadResult{Bucket: cp.Bucket, Key: cp.Object, UploadID: cp.UploadID}
+}
+
+// uploaded returns the parts of the checkpoint.
+func (cp *uploadCheckpoint) uploaded() []oss.UploadPart {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	parts := make([]oss.UploadPart, 0, len(cp.Parts))
+	for number, etag := range cp.Parts {
+		parts = append(parts, oss.UploadPart{PartNumber: number, ETag: etag})
+	}
+	return parts
+}
+
+// add records part and saves the checkpoint.
+func (cp *uploadCheckpoint) add(part oss.UploadPart) error {
+	cp.mu.Lock()
+	cp.Parts[part.PartNumber] = part.ETag
+	cp.mu.Unlock()
+	return cp.save()
+}
+
+func (cp *uploadCheckpoint) save() error {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	tmp := cp.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, cp.path)
+}
+
+func (cp *uploadCheckpoint) remove() error {
+	if err := os.Remove(cp.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// resumeCheckpoint returns the checkpoint at path when it can resume the
+// upload of a file of sha1 and fileSize split in parts of partSize, after
+// syncing its parts with the ones stored by OSS. A checkpoint of another
+// upload is discarded.
+func (c *Pan115Client) resumeCheckpoint(ctx context.Context, path string, ossToken *UploadOSSTokenResp, sha1 string, fileSize, partSize int64, sequential bool) (*uploadCheckpoint, error) {
+	cp := loadUploadCheckpoint(path)
+	if cp == nil {
+		return nil, nil
+	}
+	if !cp.matches(sha1, fileSize, partSize, sequential) {
+		return nil, c.discardCheckpoint(ctx, cp, ossToken)
+	}
+	bucket, err := c.ossBucket(ctx, cp.Bucket, ossToken)
+	if err != nil {
+		return nil, err
+	}
+	parts, err := listUploadedParts(ctx, bucket, cp.imur(), ossToken)
+	var ossErr oss.ServiceError
+	if errors.As(err, &ossErr) && ossErr.Code == "NoSuchUpload" {
+		// completed, aborted or expired, start over
+		c.logWarn(ctx, "multipart upload of checkpoint is gone, restarting", err)
+		return nil, cp.remove()
+	}
+	if err != nil {
+		return nil, err
+	}
+	// OSS has the final say on which parts are stored
+	cp.Parts = make(map[int]string, len(parts))
+	for _, part := range parts {
+		cp.Parts[part.PartNumber] = part.ETag
+	}
+	return cp, cp.save()
+}
+
+// listUploadedParts lists all the parts of the multipart upload imur.
+func listUploadedParts(ctx context.Context, bucket *oss.Bucket, imur oss.InitiateMultipartUploadResult, ossToken *UploadOSSTokenResp) ([]oss.UploadedPart, error) {
+	var (
+		parts  []oss.UploadedPart
+		marker int
+	)
+	for {
+		result, err := bucket.ListUploadedParts(imur,
+			oss.SetHeader(OssSecurityTokenHeaderName, ossToken.SecurityToken),
+			oss.UserAgentHeader(OSSUserAgent),
+			oss.PartNumberMarker(marker),
+			oss.WithContext(ctx),
+		)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, result.UploadedParts...)
+		next, _ := strconv.Atoi(result.NextPartNumberMarker)
+		if !result.IsTruncated || next <= marker {
+			return parts, nil
+		}
+		marker = next
+	}
+}
+
+// DiscardUploadCheckpoint aborts the multipart upload saved to the checkpoint
+// file at path, see UploadMultipartWithCheckpoint, and removes the file.
+func (c *Pan115Client) DiscardUploadCheckpoint(path string) error {
+	return c.DiscardUploadCheckpointCtx(context.Background(), path)
+}
+
+// DiscardUploadCheckpointCtx is like DiscardUploadCheckpoint but uses ctx for the requests.
+func (c *Pan115Client) DiscardUploadCheckpointCtx(ctx context.Context, path string) error {
+	cp := loadUploadCheckpoint(path)
+	if cp == nil {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	ossToken, err := c.GetOSSTokenCtx(ctx)
+	if err != nil {
+		return err
+	}
+	return c.discardCheckpoint(ctx, cp, ossToken)
+}
+
+// discardCheckpoint aborts the multipart upload of cp and removes it.
+func (c *Pan115Client) discardCheckpoint(ctx context.Context, cp *uploadCheckpoint, ossToken *UploadOSSTokenResp) error {
+	bucket, err := c.ossBucket(ctx, cp.Bucket, ossToken)
+	if err != nil {
+		return err
+	}
+	err = bucket.AbortMultipartUpload(cp.imur(),
+		oss.SetHeader(OssSecurityTokenHeaderName, ossToken.SecurityToken),
+		oss.UserAgentHeader(OSSUserAgent),
+		oss.WithContext(ctx),
+	)
+	var ossErr oss.ServiceError
+	if err != nil && !(errors.As(err, &ossErr) && ossErr.Code == "NoSuchUpload") {
+		return err
+	}
+	return cp.remove()
+}
@@ -0,0 +1,136 @@
+package driver
+
+import (
+	"io"
+	"sync"
+
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+)
+
+// UploadProgress is reported while a file is uploaded, see
+// UploadWithProgress, UploadMultipartWithProgress and UploadStreamWithProgress.
+type UploadProgress struct {
+	// Phase is the current step of the upload.
+	Phase UploadPhase
+	// Total is the size of the file, -1 while a stream is being hashed.
+	Total int64
+	// Hashed is the number of bytes hashed.
+	Hashed int64
+	// Sent is the number of bytes stored by OSS, including the parts of a
+	// resumed upload.
+	Sent int64
+	// Parts is the number of parts of a multipart upload.
+	Parts int
+	// PartsDone is the number of parts stored by OSS.
+	PartsDone int
+}
+
+// uploadProgress tracks the progress of an upload and reports it to fn. Its
+// methods do nothing on a nil *uploadProgress.
+type uploadProgress struct {
+	fn func(p UploadProgress)
+	// mu guards p and serializes the calls of fn.
+	mu sync.Mutex
+	p  UploadProgress
+}
+
+// newUploadProgress returns a tracker of the upload of total bytes, nil when
+// fn is nil.
+func newUploadProgress(fn func(p UploadProgress), total int64) *uploadProgress {
+	if fn == nil {
+		return nil
+	}
+	return &uploadProgress{fn: fn, p: UploadProgress{Total: total}}
+}
+
+func (t *uploadProgress) update(f func(p *UploadProgress)) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	f(&t.p)
+	t.fn(t.p)
+}
+
+func (t *uploadProgress) phase(phase UploadPhase) {
+	t.update(func(p *UploadProgress) { p.Phase = phase })
+}
+
+func (t *uploadProgress) setTotal(total int64) {
+	t.update(func(p *UploadProgress) { p.Total = total })
+}
+
+func (t *uploadProgress) addSent(n int64) {
+	t.update(func(p *UploadProgress) { p.Sent += n })
+}
+
+// setParts sets the number of parts of the upload, done of them, of doneBytes,
+// being already stored.
+func (t *uploadProgress) setParts(parts, done int, doneBytes int64) {
+	t.update(func(p *UploadProgress) {
+		p.Parts, p.PartsDone = parts, done
+		p.Sent += doneBytes
+	})
+}
+
+func (t *uploadProgress) partDone() {
+	t.update(func(p *UploadProgress) { p.PartsDone++ })
+}
+
+// hashing returns a reader of r reporting the bytes read as hashed.
+func (t *uploadProgress) hashing(r io.Reader) io.Reader {
+	if t == nil {
+		return r
+	}
+	return &hashingReader{r: r, t: t}
+}
+
+type hashingReader struct {
+	r io.Reader
+	t *uploadProgress
+}
+
+func (h *hashingReader) Read(p []byte) (int, error) {
+	n, err := h.r.Read(p)
+	if n > 0 {
+		h.t.update(func(p *UploadProgress) { p.Hashed += int64(n) })
+	}
+	return n, err
+}
+
+// ossOptions returns the options reporting the bytes sent by an OSS request,
+// and a func to take them back when the request fails.
+func (t *uploadProgress) ossOptions() ([]oss.Option, func()) {
+	if t == nil {
+		return nil, func() {}
+	}
+	l := &ossProgressListener{t: t}
+	return []oss.Option{oss.Progress(l)}, l.rollback
+}
+
+// ossProgressListener reports the bytes of an OSS request as they are sent.
+type ossProgressListener struct {
+	t    *uploadProgress
+	mu   sync.Mutex
+	sent int64
+}
+
+func (l *ossProgressListener) ProgressChanged(ev *oss.ProgressEvent) {
+	if ev.EventType != oss.TransferDataEvent || ev.RwBytes == 0 {
+		return
+	}
+	l.mu.Lock()
+	l.sent += ev.RwBytes
+	l.mu.Unlock()
+	l.t.addSent(ev.RwBytes)
+}
+
+// rollback takes back the bytes reported, OSS did not store them.
+func (l *ossProgressListener) rollback() {
+	l.mu.Lock()
+	sent := l.sent
+	l.sent = 0
+	l.mu.Unlock()
+	l.t.addSent(-sent)
+}
@@ -0,0 +1,119 @@
+package driver
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+
+	hash "github.com/SheltonZhu/115driver/pkg/crypto"
+)
+
+// UploadStream uploads the content of r, of unknown size, to dirID as
+// fileName. The stream is hashed while spooled to memory, or to a temp file
+// when larger than the memory limit, then rapid uploaded when 115 already has
+// it, uploaded in a single request when it is in memory, or by multipart.
+func (c *Pan115Client) UploadStream(dirID, fileName string, r io.Reader, opts ...UploadStreamOption) error {
+	return c.UploadStreamCtx(context.Background(), dirID, fileName, r, opts...)
+}
+
+// UploadStreamCtx is like UploadStream but uses ctx for the requests, the
+// spooling and the OSS upload.
+func (c *Pan115Client) UploadStreamCtx(ctx context.Context, dirID, fileName string, r io.Reader, opts ...UploadStreamOption) error {
+	options := DefaultUploadStreamOptions()
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	progress := newUploadProgress(options.Progress, -1)
+
+	if ok, err := c.UploadAvailableCtx(ctx); err != nil || !ok {
+		return err
+	}
+	maxSize := options.MaxSize
+	if limit := c.uploadSizeLimit(); limit > 0 && (maxSize <= 0 || limit < maxSize) {
+		maxSize = limit
+	}
+
+	s := &streamSpool{ctx: ctx, memoryLimit: options.MemoryLimit, tempDir: options.TempDir}
+	defer s.close()
+	if maxSize > 0 {
+		r = io.LimitReader(r, maxSize+1)
+	}
+	digest := hash.DigestResult{}
+	progress.phase(UploadPhaseHashing)
+	if err := hash.Digest(progress.hashing(io.TeeReader(r, s)), &digest); err != nil {
+		return err
+	}
+	if maxSize > 0 && digest.Size > maxSize {
+		return ErrUploadTooLarge
+	}
+	progress.setTotal(digest.Size)
+
+	// 闪传
+	progress.phase(UploadPhaseRapid)
+	fastInfo, err := c.RapidUploadCtx(ctx, digest.Size, fileName, dirID, digest.PreID, digest.QuickID, s.reader(digest.Size))
+	if err != nil {
+		return err
+	}
+	if ok, err := fastInfo.Ok(); err != nil {
+		return err
+	} else if ok {
+		return nil
+	}
+
+	// 闪传失败，上传
+	if s.file == nil {
+		return c.UploadByOSSCtx(ctx, &fastInfo.UploadOSSParams, s.reader(digest.Size), dirID, uploadWithTracker(progress))
+	}
+	return c.UploadByMultipartCtx(ctx, &fastInfo.UploadOSSParams, digest.Size, s.file, dirID,
+		append(options.MultipartOptions[:len(options.MultipartOptions):len(options.MultipartOptions)], uploadMultipartWithTracker(progress))...)
+}
+
+// streamSpool stores a stream in memory, moving it to a temp file once it
+// grows over memoryLimit.
+type streamSpool struct {
+	ctx         context.Context
+	memoryLimit int64
+	tempDir     string
+
+	buf  bytes.Buffer
+	file *os.File
+}
+
+func (s *streamSpool) Write(p []byte) (int, error) {
+	if err := s.ctx.Err(); err != nil {
+		return 0, err
+	}
+	if s.file == nil && int64(s.buf.Len()+len(p)) > s.memoryLimit {
+		f, err := os.CreateTemp(s.tempDir, "115driver-upload-*")
+		if err != nil {
+			return 0, err
+		}
+		s.file = f
+		if _, err := f.Write(s.buf.Bytes()); err != nil {
+			return 0, err
+		}
+		s.buf = bytes.Buffer{}
+	}
+	if s.file != nil {
+		return s.file.Write(p)
+	}
+	return s.buf.Write(p)
+}
+
+// reader returns a reader of the size bytes spooled.
+func (s *streamSpool) reader(size int64) io.ReadSeeker {
+	if s.file != nil {
+		return io.NewSectionReader(s.file, 0, size)
+	}
+	return bytes.NewReader(s.buf.Bytes())
+}
+
+// close removes the temp file.
+func (s *streamSpool) close() {
+	if s.file != nil {
+		s.file.Close()
+		os.Remove(s.file.Name())
+	}
+}
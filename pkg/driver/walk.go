@@ -0,0 +1,299 @@
+package driver
+
+import (
+	"context"
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+)
+
+// SkipDir and SkipAll are returned by a WalkFunc to skip a directory or the
+// rest of the walk, like with filepath.WalkDir.
+var (
+	SkipDir = fs.SkipDir
+	SkipAll = fs.SkipAll
+)
+
+// WalkFunc is called by Walk for every entry of the tree, with its full
+// remote path, e.g. "/movies/2024/a.mp4".
+//
+// When the listing of a directory fails, the function is called a second
+// time for that directory with the error. Returning nil then continues the
+// walk with the next directory, while returning the error stops it.
+//
+// Returning SkipDir for a directory skips its entries, for a file it skips the
+// remaining entries of its directory. Returning SkipAll stops the walk, and any
+// other error stops the walk and is returned by Walk.
+type WalkFunc func(path string, file *File, err error) error
+
+type WalkOptions struct {
+	breadthFirst bool
+	maxDepth     int
+	concurrency  int
+	rootPath     string
+	listOpts     []ListOption
+}
+
+func DefaultWalkOptions() *WalkOptions {
+	return &WalkOptions{}
+}
+
+type WalkOption func(o *WalkOptions)
+
+// WithWalkBreadthFirst visits all the entries of a depth before the next one,
+// instead of descending into each directory when it is visited.
+func WithWalkBreadthFirst() WalkOption {
+	return func(o *WalkOptions) {
+		o.breadthFirst = true
+	}
+}
+
+// WithWalkMaxDepth visits the entries up to depth n, the entries of the walked
+// directory being at depth 1. Zero means no limit.
+func WithWalkMaxDepth(n int) WalkOption {
+	return func(o *WalkOptions) {
+		o.maxDepth = n
+	}
+}
+
+// WithWalkConcurrency lists up to n directories in parallel. The WalkFunc is
+// still called from one goroutine at a time, with the entries of a directory
+// in a row, but the directories are visited in no particular order.
+func WithWalkConcurrency(n int) WalkOption {
+	return func(o *WalkOptions) {
+		o.concurrency = n
+	}
+}
+
+// WithWalkRootPath sets the path of the walked directory, which is otherwise
+// looked up with Stat.
+func WithWalkRootPath(p string) WalkOption {
+	return func(o *WalkOptions) {
+		o.rootPath = p
+	}
+}
+
+// WithWalkListOptions lists the directories with opts.
+func WithWalkListOptions(opts ...ListOption) WalkOption {
+	return func(o *WalkOptions) {
+		o.listOpts = append(o.listOpts, opts...)
+	}
+}
+
+// walkDir is a directory to walk.
+type walkDir struct {
+	path  string
+	file  *File
+	depth int
+}
+
+// Walk walks the tree rooted at the directory dirID, calling fn for every
+// entry but the root, in depth-first order by default.
+func (c *Pan115Client) Walk(dirID string, fn WalkFunc, opts ...WalkOption) error {
+	return c.WalkCtx(context.Background(), dirID, fn, opts...)
+}
+
+// WalkCtx is like Walk but uses ctx for the requests.
+func (c *Pan115Client) WalkCtx(ctx context.Context, dirID string, fn WalkFunc, opts ...WalkOption) error {
+	o := DefaultWalkOptions()
+	if len(opts) > 0 {
+		for _, opt := range opts {
+			opt(o)
+		}
+	}
+	if dirID == "" {
+		dirID = "0"
+	}
+	rootPath := o.rootPath
+	if rootPath == "" {
+		var err error
+		if rootPath, err = c.dirPath(ctx, dirID); err != nil {
+			return err
+		}
+	}
+	root := &walkDir{
+		path: rootPath,
+		file: &File{IsDirectory: true, FileID: dirID, Name: path.Base(rootPath)},
+	}
+	w := &walker{c: c, ctx: ctx, fn: fn, o: o}
+
+	var err error
+	switch {
+	case o.concurrency > 1:
+		err = w.walkConcurrent(root)
+	case o.breadthFirst:
+		err = w.walkBreadthFirst(root)
+	default:
+		err = w.walkDepthFirst(root)
+	}
+	if err == SkipDir || err == SkipAll {
+		return nil
+	}
+	return err
+}
+
+// dirPath returns the full path of the directory dirID.
+func (c *Pan115Client) dirPath(ctx context.Context, dirID string) (string, error) {
+	if dirID == "0" {
+		return "/", nil
+	}
+	stat, err := c.StatCtx(ctx, dirID)
+	if err != nil {
+		return "", err
+	}
+	names := make([]string, 0, len(stat.Parents)+1)
+	for _, p := range stat.Parents {
+		if p.ID != "0" {
+			names = append(names, p.Name)
+		}
+	}
+	return "/" + strings.Join(append(names, stat.Name), "/"), nil
+}
+
+type walker struct {
+	c   *Pan115Client
+	ctx context.Context
+	fn  WalkFunc
+	o   *WalkOptions
+}
+
+// descend reports whether the entries of the directory at depth are walked.
+func (w *walker) descend(depth int) bool {
+	return w.o.maxDepth <= 0 || depth < w.o.maxDepth
+}
+
+// visit calls fn for the entries of dir, passing the directories to walk to
+// sub. It returns SkipAll or the error stopping the walk.
+func (w *walker) visit(dir *walkDir, entries func(yield func(File, error) bool), sub func(*walkDir) error) error {
+	var listErr error
+	for f, err := range entries {
+		if err != nil {
+			listErr = err
+			break
+		}
+		p := path.Join(dir.path, f.Name)
+		err := w.fn(p, &f, nil)
+		if !f.IsDirectory {
+			if err == SkipDir {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if err == SkipDir {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if w.descend(dir.depth + 1) {
+			if err := sub(&walkDir{path: p, file: &f, depth: dir.depth + 1}); err != nil {
+				return err
+			}
+		}
+	}
+	if listErr != nil {
+		if err := w.ctx.Err(); err != nil {
+			return err
+		}
+		if err := w.fn(dir.path, dir.file, listErr); err != nil && err != SkipDir {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *walker) list(dir *walkDir) func(yield func(File, error) bool) {
+	return w.c.ListIterCtx(w.ctx, dir.file.FileID, w.o.listOpts...)
+}
+
+func (w *walker) walkDepthFirst(dir *walkDir) error {
+	return w.visit(dir, w.list(dir), w.walkDepthFirst)
+}
+
+func (w *walker) walkBreadthFirst(root *walkDir) error {
+	queue := []*walkDir{root}
+	for len(queue) > 0 {
+		dir := queue[0]
+		queue = queue[1:]
+		if err := w.visit(dir, w.list(dir), func(d *walkDir) error {
+			queue = append(queue, d)
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkConcurrent lists up to concurrency directories at once, calling fn
+// under a lock once a directory is fully listed.
+func (w *walker) walkConcurrent(root *walkDir) error {
+	parent := w.ctx
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+	w.ctx = ctx
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+		sem      = make(chan struct{}, w.o.concurrency)
+	)
+	var walk func(dir *walkDir)
+	walk = func(dir *walkDir) {
+		defer wg.Done()
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		var files []File
+		var listErr error
+		for f, err := range w.list(dir) {
+			if err != nil {
+				listErr = err
+				break
+			}
+			files = append(files, f)
+		}
+		<-sem
+
+		mu.Lock()
+		defer mu.Unlock()
+		if ctx.Err() != nil {
+			return
+		}
+		entries := func(yield func(File, error) bool) {
+			for _, f := range files {
+				if !yield(f, nil) {
+					return
+				}
+			}
+			if listErr != nil {
+				yield(File{}, listErr)
+			}
+		}
+		if err := w.visit(dir, entries, func(d *walkDir) error {
+			wg.Add(1)
+			go walk(d)
+			return nil
+		}); err != nil {
+			if err != SkipAll {
+				firstErr = err
+			}
+			cancel()
+		}
+	}
+	wg.Add(1)
+	walk(root)
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+	// SkipAll cancels ctx but not parent
+	return parent.Err()
+}
@@ -0,0 +1,156 @@
+package driver
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+
+	"github.com/SheltonZhu/115driver/pkg/driver/fake115"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newWalkTestClient serves the tree
+//
+//	/a/b/c/z.txt
+//	/a/b/y.txt
+//	/a/x.txt
+//	/d/w.txt
+//	/top.txt
+func newWalkTestClient(t *testing.T) (*fake115.Server, *Pan115Client) {
+	srv := fake115.NewServer()
+	t.Cleanup(srv.Close)
+	a := srv.AddDir("0", "a")
+	b := srv.AddDir(a.ID, "b")
+	c := srv.AddDir(b.ID, "c")
+	d := srv.AddDir("0", "d")
+	srv.AddFile(c.ID, "z.txt", nil)
+	srv.AddFile(b.ID, "y.txt", nil)
+	srv.AddFile(a.ID, "x.txt", nil)
+	srv.AddFile(d.ID, "w.txt", nil)
+	srv.AddFile("0", "top.txt", nil)
+	return srv, New(UA(), WithBaseHost(srv.URL))
+}
+
+func collect(t *testing.T, c *Pan115Client, dirID string, decide func(p string, f *File) error, opts ...WalkOption) []string {
+	var paths []string
+	err := c.Walk(dirID, func(p string, f *File, err error) error {
+		require.NoError(t, err)
+		paths = append(paths, p)
+		if decide != nil {
+			return decide(p, f)
+		}
+		return nil
+	}, opts...)
+	require.NoError(t, err)
+	return paths
+}
+
+func TestWalkDepthFirst(t *testing.T) {
+	_, c := newWalkTestClient(t)
+
+	assert.Equal(t, []string{
+		"/a", "/a/b", "/a/b/c", "/a/b/c/z.txt", "/a/b/y.txt", "/a/x.txt", "/d", "/d/w.txt", "/top.txt",
+	}, collect(t, c, "0", nil))
+}
+
+func TestWalkBreadthFirst(t *testing.T) {
+	_, c := newWalkTestClient(t)
+
+	assert.Equal(t, []string{
+		"/a", "/d", "/top.txt", "/a/b", "/a/x.txt", "/d/w.txt", "/a/b/c", "/a/b/y.txt", "/a/b/c/z.txt",
+	}, collect(t, c, "0", nil, WithWalkBreadthFirst()))
+}
+
+func TestWalkSkip(t *testing.T) {
+	_, c := newWalkTestClient(t)
+
+	paths := collect(t, c, "0", func(p string, f *File) error {
+		switch p {
+		case "/a/b":
+			return SkipDir
+		case "/d/w.txt":
+			return SkipDir
+		}
+		return nil
+	})
+	assert.Equal(t, []string{"/a", "/a/b", "/a/x.txt", "/d", "/d/w.txt", "/top.txt"}, paths)
+
+	paths = collect(t, c, "0", func(p string, f *File) error {
+		if p == "/a/b" {
+			return SkipAll
+		}
+		return nil
+	})
+	assert.Equal(t, []string{"/a", "/a/b"}, paths)
+
+	stop := errors.New("stop")
+	err := c.Walk("0", func(p string, f *File, err error) error { return stop })
+	assert.Equal(t, stop, err)
+}
+
+func TestWalkMaxDepthAndRootPath(t *testing.T) {
+	srv, c := newWalkTestClient(t)
+
+	assert.Equal(t, []string{"/a", "/d", "/top.txt"}, collect(t, c, "0", nil, WithWalkMaxDepth(1)))
+
+	a := srv.Children("0")[0]
+	assert.Equal(t, []string{"/a/b", "/a/b/c", "/a/b/y.txt", "/a/x.txt"},
+		collect(t, c, a.ID, nil, WithWalkMaxDepth(2)))
+	assert.Equal(t, []string{"a/b", "a/x.txt"},
+		collect(t, c, a.ID, nil, WithWalkMaxDepth(1), WithWalkRootPath("a")))
+}
+
+func TestWalkConcurrent(t *testing.T) {
+	_, c := newWalkTestClient(t)
+
+	paths := collect(t, c, "0", nil, WithWalkConcurrency(4))
+	sort.Strings(paths)
+	assert.Equal(t, []string{
+		"/a", "/a/b", "/a/b/c", "/a/b/c/z.txt", "/a/b/y.txt", "/a/x.txt", "/d", "/d/w.txt", "/top.txt",
+	}, paths)
+
+	paths = collect(t, c, "0", func(p string, f *File) error {
+		if f.IsDirectory {
+			return SkipAll
+		}
+		return nil
+	}, WithWalkConcurrency(4))
+	assert.Len(t, paths, 1)
+}
+
+func TestWalkListError(t *testing.T) {
+	srv, _ := newWalkTestClient(t)
+	a := srv.Children("0")[0]
+	b := srv.Children(a.ID)[0]
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("cid") == b.ID {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		srv.ServeHTTP(w, r)
+	}))
+	defer failing.Close()
+	c := New(UA(), WithBaseHost(failing.URL))
+
+	for _, opts := range [][]WalkOption{nil, {WithWalkBreadthFirst()}, {WithWalkConcurrency(3)}} {
+		var paths, failed []string
+		err := c.Walk("0", func(p string, f *File, err error) error {
+			if err != nil {
+				failed = append(failed, p)
+				assert.Equal(t, b.ID, f.FileID)
+				return nil
+			}
+			paths = append(paths, p)
+			return nil
+		}, opts...)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"/a/b"}, failed)
+		assert.Len(t, paths, 6)
+
+		err = c.Walk("0", func(p string, f *File, err error) error { return err }, opts...)
+		assert.Error(t, err)
+	}
+}